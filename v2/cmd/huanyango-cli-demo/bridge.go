@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/bridge"
+	"github.com/jacobsa/go-serial/serial"
+	"io"
+)
+
+// bridgeBaudRate is the default baud rate of GRBL and Smoothieware.
+const bridgeBaudRate = 115200
+
+// runBridge opens the serial ports of the G-code sender and the motion controller and
+// relays between them until one of them fails.
+func runBridge(senderDevice, controllerDevice string, hyInv *vfdio.HyInverter) error {
+	sender, err := openBridgePort(senderDevice)
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	controller, err := openBridgePort(controllerDevice)
+	if err != nil {
+		return err
+	}
+	defer controller.Close()
+	return bridge.New(hyInv).Run(sender, controller)
+}
+
+func openBridgePort(device string) (io.ReadWriteCloser, error) {
+	return serial.Open(serial.OpenOptions{
+		PortName:        device,
+		BaudRate:        bridgeBaudRate,
+		DataBits:        8,
+		StopBits:        1,
+		ParityMode:      serial.PARITY_NONE,
+		MinimumReadSize: 1,
+	})
+}
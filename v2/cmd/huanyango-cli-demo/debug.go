@@ -0,0 +1,31 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+)
+
+// debugState is served as JSON by /debug/vfdio.
+type debugState struct {
+	Goroutines int
+	Vfd        vfdio.Diagnostics
+}
+
+// serveDebug serves the pprof handlers under /debug/pprof/ and the queue and connection
+// state under /debug/vfdio. It blocks until the listener fails.
+func serveDebug(addr string, hyInv *vfdio.HyInverter) error {
+	http.HandleFunc("/debug/vfdio", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(debugState{Goroutines: runtime.NumGoroutine(), Vfd: hyInv.Diagnostics()})
+	})
+	return http.ListenAndServe(addr, nil)
+}
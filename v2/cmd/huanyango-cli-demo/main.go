@@ -0,0 +1,418 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This is a demo app that uses the Huanyango library to control a Huanyang VFD.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/gateway"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "huanyango-cli-demo -port=/dev/ttyUSB0")
+		fmt.Fprintln(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "Use G-Codes M3, M4, M4 and Snnnn.")
+		fmt.Fprintln(flag.CommandLine.Output(), "G4 Pn waits n seconds before the following commands.")
+		fmt.Fprintln(flag.CommandLine.Output(), "? prints the current RPM.")
+		fmt.Fprintln(flag.CommandLine.Output(), "$ outputs if connected.")
+		fmt.Fprintln(flag.CommandLine.Output(), "trace prints the latest frames sent and received.")
+		fmt.Fprintln(flag.CommandLine.Output(), "audit prints the latest commands with their enqueue, transmit and acknowledge times.")
+		fmt.Fprintln(flag.CommandLine.Output(), "stats prints transaction counters and the latency histogram.")
+		fmt.Fprintln(flag.CommandLine.Output(), "fault prints the fault code (requires -fault-param), reset clears a trip.")
+		fmt.Fprintln(flag.CommandLine.Output(), "identify prints the ratings of the drive and motor.")
+		fmt.Fprintln(flag.CommandLine.Output(), "accel n and decel n set the ramp times in seconds (PD014, PD015).")
+		fmt.Fprintln(flag.CommandLine.Output(), "hz n sets the frequency in Hz without the RPM conversion.")
+		fmt.Fprintln(flag.CommandLine.Output(), "preset name runs the spindle at a speed defined by -presets.")
+		fmt.Fprintln(flag.CommandLine.Output(), "brake stops the spindle with the decel ramp and DC braking (PD026, PD028-PD030).")
+		fmt.Fprintln(flag.CommandLine.Output(), "estop stops the spindle at once and rejects commands until release.")
+		fmt.Fprintln(flag.CommandLine.Output(), "pause holds back the queued commands until resume, pause n also lowers the speed to n RPM.")
+		fmt.Fprintln(flag.CommandLine.Output(), "run file queues the spindle commands of a G-code file and stops the spindle at its end.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	var serialDevice *string = flag.String("port", "/dev/ttyMotorspindel", "USB Port. Linux default: /dev/ttyUSB0. On Windows use COMx, e.g. COM3. On Linux a symbolic link can be created using udev rules, see https://unix.stackexchange.com/a/183492.")
+	var usbID *string = flag.String("usb", "", "Open the USB adapter with this serial number or VID:PID in hex, e.g. 0403:6001, instead of -port. Linux only.")
+	var pollRate *int64 = flag.Int64("interval", 750, "RPM status readout interval in milliseconds. Default: 750.")
+	var rpmHertzConversation *float64 = flag.Float64("rpm2hz", 3.47222, "Unit conversation from RPM to the set frequency in 0.01 Hz. May be determined experimentally. 0 calculates it from PD144 and PD176 of the VFD.")
+	var maxRpm *int64 = flag.Int64("maxrpm", 11520, "Maximum allowed RPM for your spindle.")
+	var presets *string = flag.String("presets", "", "Named speeds for the preset command, e.g. rough=18000,finish=24000.")
+	var minRpm *uint = flag.Uint("minrpm", 0, "Minimum RPM for your spindle. Lower S commands are raised to it. 0 disables the limit.")
+	var minRpmReject *bool = flag.Bool("minrpm-reject", false, "Reject S commands below -minrpm instead of raising them.")
+	var maxRpmReject *bool = flag.Bool("maxrpm-reject", false, "Reject S commands above -maxrpm instead of lowering them.")
+	var deferSpeed *bool = flag.Bool("defer-speed", false, "Send S commands given while the spindle is stopped with the next M3 or M4.")
+	var strictWords *bool = flag.Bool("strict-words", false, "Reject lines holding words without a spindle function, e.g. G1 or typos.")
+	var speedFirst *bool = flag.Bool("speed-first", false, "Execute the S word of a line before its M3, M4 or M5 like CNC controllers.")
+	var stallReset *bool = flag.Bool("stall-reset", false, "Reopen the serial port if queued commands are not sent for 5 seconds.")
+	var usageFile *string = flag.String("usage", "", "File keeping the run time and energy counters across runs. Disabled if empty.")
+	var noReverse *bool = flag.Bool("no-reverse", false, "Reject M4 and reverse jogs, e.g. for spindles with ER collets.")
+	var invertDirection *bool = flag.Bool("invert-direction", false, "Swap the directions sent to the VFD if M3 turns the tool counter-clockwise.")
+	var baudRate *uint = flag.Uint("baud", 9600, "Baud rate, see PD164.")
+	var slaveAddress *uint = flag.Uint("address", 1, "RS485 slave address, see PD163.")
+	var maxTemperature *float64 = flag.Float64("max-temp", 0, "Warn if the drive temperature exceeds this value in °C. 0 disables the warning.")
+	var faultParameter *uint = flag.Uint("fault-param", 0, "Number of the PDxxx parameter holding the fault code, see the VFD manual. 0 disables fault polling.")
+	var pollJitter *int64 = flag.Int64("jitter", 0, "Random delay of up to this many milliseconds added to the readout interval. Use it if several spindles share a bus or gateway.")
+	var ramp *float64 = flag.Float64("ramp", 0, "Software ramp for speed changes of a running spindle in RPM per second. 0 leaves the ramp to the VFD.")
+	var registerMap *string = flag.String("register-map", "classic", "Protocol of the drive: classic (HY02D223B and similar) or modbus (newer series, requires -rpm2hz other than 0).")
+	var maxFrequency *float64 = flag.Float64("max-frequency", 400, "Maximum frequency of the drive in Hz, 100 % of the frequency register with -register-map=modbus.")
+	var monitor *bool = flag.Bool("monitor", false, "Read-only mode: only read the VFD status, e.g. while another controller commands it.")
+	var runState *bool = flag.Bool("run-state", false, "Read the run state and direction of the VFD in every readout interval.")
+	var strictTiming *bool = flag.Bool("strict-timing", false, "Reject received frames which violate the Modbus RTU timing and report them. Use it to find flaky adapters.")
+	var stopOnOpen *bool = flag.Bool("stop-on-open", false, "Stop the spindle and set speed 0 when connecting. Otherwise the VFD state is left as is.")
+	var discover *bool = flag.Bool("discover", false, "Search all USB serial ports for VFDs and exit.")
+	var modbusTCP *string = flag.String("modbus-tcp", "", "Expose the VFD as Modbus TCP slave on this address, e.g. :502. Disabled if empty.")
+	var bridgeSender *string = flag.String("bridge-sender", "", "Serial port of a G-code sender. With -bridge-controller, spindle words are sent to the VFD and all other lines to the motion controller.")
+	var bridgeController *string = flag.String("bridge-controller", "", "Serial port of the motion controller (GRBL, Smoothieware) used with -bridge-sender.")
+	var debugHTTP *string = flag.String("debug-http", "", "Serve pprof (/debug/pprof/) and the queue state (/debug/vfdio) on this address, e.g. localhost:6060. Disabled if empty.")
+	var logLevel *string = flag.String("log", "", "Write structured logs to stderr at this level: debug (includes all frames), info or warn. Disabled if empty.")
+	var printVersion *bool = flag.Bool("version", false, "Print the library version and exit.")
+	flag.Parse()
+
+	if *printVersion {
+		fmt.Println(vfdio.Version())
+		return
+	}
+
+	fmt.Println("Huanyango Command Line Interface Demo, library version", vfdio.Version())
+	if *discover {
+		found, err := vfdio.Discover(vfdio.DiscoverOptions{})
+		if err != nil {
+			fmt.Println(err)
+		}
+		for _, vfd := range found {
+			fmt.Printf("Found VFD: -port=%s -baud=%d -address=%d\n", vfd.PortName, vfd.BaudRate, vfd.SlaveAddress)
+		}
+		return
+	}
+	fmt.Println("Commands: M3, M4, M5, Snnnn, ?, $, trace, audit, stats, fault, reset, identify, accel n, decel n, hz n, preset name, brake, estop, release, pause [n], resume, run file, exit, help")
+
+	hyInv, err := vfdio.NewVfdFromConfig(vfdio.Config{
+		MaxRpm:       uint16(*maxRpm),
+		RpmToHertz:   *rpmHertzConversation,
+		PollInterval: time.Duration(*pollRate) * time.Millisecond,
+		BaudRate:     *baudRate,
+		SlaveAddress: byte(*slaveAddress),
+		Logger:       newLogger(*logLevel),
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	hyInv.SetFrameLog(100)
+	hyInv.SetCommandLog(100)
+	if *stopOnOpen {
+		hyInv.SetOpenState(vfdio.StopOnOpen)
+	}
+	hyInv.SetTemperatureLimit(*maxTemperature)
+	hyInv.SetPollJitter(time.Duration(*pollJitter) * time.Millisecond)
+	hyInv.SetFaultParameter(byte(*faultParameter))
+	hyInv.SetStrictTiming(*strictTiming)
+	hyInv.SetRunStatePolling(*runState)
+	for _, preset := range strings.Split(*presets, ",") {
+		if name, rpm, found := strings.Cut(preset, "="); found {
+			if value, err := strconv.ParseUint(rpm, 10, 16); err == nil {
+				hyInv.DefinePreset(strings.TrimSpace(name), uint16(value))
+			} else {
+				fmt.Printf("Invalid preset %q: %v\n", preset, err)
+			}
+		}
+	}
+	if *minRpmReject {
+		hyInv.SetMinRpm(uint16(*minRpm), vfdio.RejectBelowMinimum)
+	} else {
+		hyInv.SetMinRpm(uint16(*minRpm), vfdio.ClampToMinimum)
+	}
+	if *maxRpmReject {
+		hyInv.SetAboveMaximum(vfdio.RejectAboveMaximum)
+	}
+	hyInv.SetSpeedFirst(*speedFirst)
+	hyInv.SetDeferSpeed(*deferSpeed)
+	hyInv.SetStrictWords(*strictWords)
+	if *registerMap == "modbus" {
+		hyInv.SetRegisterMap(vfdio.NewModbusMap(uint16(*maxFrequency * 100)))
+	}
+	hyInv.SetReadOnly(*monitor)
+	hyInv.SetReverseLockout(*noReverse)
+	hyInv.SetInvertDirection(*invertDirection)
+	hyInv.SetStallDetection(5*time.Second, *stallReset)
+	hyInv.SetRamp(vfdio.RampProfile{Acceleration: *ramp})
+	hyInv.SetPollValues(vfdio.StatusOutputFrequency, vfdio.StatusSetFrequency, vfdio.StatusOutputCurrent, vfdio.StatusACVoltage, vfdio.StatusDCVoltage, vfdio.StatusTemperature)
+	hyInv.Subscribe(func(e vfdio.Event) {
+		switch e.Type {
+		case vfdio.ExternalChange:
+			fmt.Printf("\nWarning: spindle state changed from the front panel (set speed now %d 1/min).\n> ", e.Rpm)
+		case vfdio.Clamped:
+			fmt.Printf("\nWarning: VFD limited the speed to %d 1/min (requested %d 1/min), check PD005/PD011.\n> ", e.Rpm, e.RequestedRpm)
+		case vfdio.Disconnected:
+			fmt.Printf("\nWarning: serial port failed (%v), reconnecting...\n> ", e.Err)
+		case vfdio.Reconnected:
+			fmt.Print("\nSerial port reconnected, last speed and direction restored.\n> ")
+		case vfdio.Offline:
+			fmt.Printf("\nWarning: VFD offline: %v\n> ", e.Err)
+		case vfdio.Online:
+			fmt.Print("\nVFD online again.\n> ")
+		case vfdio.CircuitOpen:
+			fmt.Print("\nWarning: VFD does not answer, requests are held back until it does.\n> ")
+		case vfdio.HighTemperature:
+			fmt.Printf("\nWarning: drive temperature %v °C.\n> ", e.Temperature)
+		case vfdio.Fault:
+			fmt.Printf("\nWarning: VFD tripped with fault code %d, use reset after removing the cause.\n> ", e.FaultCode)
+		case vfdio.FaultCleared:
+			fmt.Print("\nVFD fault cleared.\n> ")
+		case vfdio.StopFailed:
+			fmt.Print("\nDANGER: VFD did not acknowledge the stop command, the spindle may still be running!\n> ")
+		case vfdio.CircuitClosed:
+			fmt.Print("\nVFD answers again, sending held back requests.\n> ")
+		case vfdio.TimingViolation:
+			fmt.Printf("\nWarning: frame rejected: %v\n> ", e.Err)
+		case vfdio.QueueStalled:
+			fmt.Print("\nDANGER: commands are not sent to the VFD, the serial port may be blocked!\n> ")
+		case vfdio.QueueResumed:
+			fmt.Print("\nCommands are sent again.\n> ")
+		case vfdio.Panicked:
+			fmt.Printf("\nError: %v\n> ", e.Err)
+		case vfdio.CommandRejected:
+			fmt.Printf("\nError: %v\n> ", e.Err)
+		case vfdio.WordIgnored:
+			fmt.Printf("\nWarning: %v, ignored.\n> ", e.Err)
+		case vfdio.Started:
+			fmt.Print("\nSpindle started.\n> ")
+		case vfdio.Stopped:
+			fmt.Print("\nSpindle stopped.\n> ")
+		case vfdio.DirectionChanged:
+			fmt.Print("\nSpindle direction changed.\n> ")
+		case vfdio.SpeedReached:
+			fmt.Printf("\nSpeed reached: %d RPM\n> ", e.Rpm)
+		}
+	})
+	if *usageFile != "" {
+		if file, err := os.Open(*usageFile); err == nil {
+			if err := hyInv.ReadUsage(file); err != nil {
+				fmt.Println("Invalid usage file:", err)
+			}
+			file.Close()
+		}
+	}
+	if *usbID != "" {
+		err = hyInv.OpenUSB(*usbID)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = hyInv.OpenContext(ctx, *serialDevice)
+		cancel()
+	}
+	if err != nil {
+		fmt.Println(openErrorHint(err))
+		os.Exit(1)
+	}
+	defer hyInv.Close()
+	if *usageFile != "" {
+		defer func() {
+			file, err := os.Create(*usageFile)
+			if err == nil {
+				err = hyInv.WriteUsage(file)
+				file.Close()
+			}
+			if err != nil {
+				fmt.Println("Usage counters not saved:", err)
+			}
+		}()
+	}
+	if err := hyInv.ReadRatedCurrent(); err != nil {
+		fmt.Println("Rated current (PD142) not read, load unknown:", err)
+	}
+	if *modbusTCP != "" {
+		hyInv.SetPollValues(vfdio.StatusOutputFrequency, vfdio.StatusSetFrequency, vfdio.StatusOutputCurrent, vfdio.StatusRpm, vfdio.StatusACVoltage, vfdio.StatusDCVoltage, vfdio.StatusTemperature)
+		go func() {
+			fmt.Println("Modbus TCP gateway stopped:", gateway.NewServer(hyInv).ListenAndServe(*modbusTCP))
+		}()
+	}
+	if *bridgeSender != "" && *bridgeController != "" {
+		go func() {
+			fmt.Println("Bridge stopped:", runBridge(*bridgeSender, *bridgeController, hyInv))
+		}()
+	}
+	if *debugHTTP != "" {
+		go func() {
+			fmt.Println("Debug HTTP server stopped:", serveDebug(*debugHTTP, hyInv))
+		}()
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	continueScanning := true
+	fmt.Print("> ")
+	for continueScanning && scanner.Scan() {
+		cmd := scanner.Text()
+		if cmd == "?" {
+			status := hyInv.Status()
+			fmt.Println("Target RPM 1/min: ", status.TargetRpm)
+			fmt.Println("Output RPM 1/min: ", status.OutputRpm)
+			fmt.Println("Output Hz:        ", vfdio.FrequencyToHertz(status.OutputFrequency))
+			fmt.Println("Output current A: ", hyInv.OutputCurrentAmps())
+			fmt.Println("Output voltage V: ", hyInv.OutputVoltage())
+			fmt.Println("DC bus voltage V: ", hyInv.DCBusVoltage())
+			fmt.Println("Temperature °C:   ", hyInv.Temperature())
+			fmt.Println("Load %:           ", hyInv.Load())
+			fmt.Println("Queued commands:  ", status.QueueDepth)
+			fmt.Println("Modal state:      ", hyInv.ModalState())
+			if _, ok := hyInv.ControlStatus(); ok {
+				fmt.Println("Running:          ", hyInv.Running(), "cw:", hyInv.Direction(), "braking:", hyInv.Braking())
+			}
+		} else if cmd == "trace" {
+			hyInv.WriteFrameLog(os.Stdout)
+		} else if cmd == "audit" {
+			hyInv.WriteCommandLog(os.Stdout)
+		} else if cmd == "fault" {
+			code, active := hyInv.FaultCode()
+			fmt.Println("Fault code:", code, "active:", active)
+		} else if cmd == "reset" {
+			hyInv.ResetFault()
+		} else if cmd == "identify" {
+			if id, err := hyInv.Identify(); err != nil {
+				fmt.Println("Error:", err)
+			} else {
+				fmt.Println(id)
+			}
+		} else if strings.HasPrefix(cmd, "accel ") || strings.HasPrefix(cmd, "decel ") {
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(cmd[6:]), 64)
+			if err == nil && cmd[0] == 'a' {
+				err = hyInv.SetAccelTime(seconds)
+			} else if err == nil {
+				err = hyInv.SetDecelTime(seconds)
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if strings.HasPrefix(cmd, "hz ") {
+			hz, err := strconv.ParseFloat(strings.TrimSpace(cmd[3:]), 64)
+			if err == nil {
+				err = hyInv.SetFrequencyHz(hz)
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if cmd == "brake" {
+			if err := hyInv.BrakeStop(); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if cmd == "estop" {
+			if err := hyInv.EStop(); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if cmd == "release" {
+			hyInv.ClearEStop()
+		} else if cmd == "pause" {
+			if err := hyInv.Pause(); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if strings.HasPrefix(cmd, "pause ") {
+			rpm, err := strconv.ParseUint(strings.TrimSpace(cmd[6:]), 10, 16)
+			if err == nil {
+				err = hyInv.PauseAt(uint16(rpm))
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if strings.HasPrefix(cmd, "run ") {
+			if err := runProgram(hyInv, strings.TrimSpace(cmd[4:])); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if cmd == "resume" {
+			if err := hyInv.Resume(); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if strings.HasPrefix(cmd, "preset ") {
+			if err := hyInv.RunPreset(strings.TrimSpace(cmd[7:])); err != nil {
+				fmt.Println("Error:", err)
+			}
+		} else if cmd == "stats" {
+			stats := hyInv.Stats()
+			fmt.Printf("Requests: %d, responses: %d, unanswered: %d, timing violations: %d, CRC errors: %d\n", stats.Requests, stats.Responses, stats.Unanswered, stats.TimingViolations, stats.CRCErrors)
+			fmt.Printf("Latency mean: %v, p50: %v, p99: %v, max: %v\n", stats.Latency.Mean(),
+				stats.Latency.Percentile(50), stats.Latency.Percentile(99), stats.Latency.Max)
+			for i, bound := range vfdio.LatencyBuckets {
+				fmt.Printf("  <= %-6v %d\n", bound, stats.Latency.Counts[i])
+			}
+			fmt.Printf("  >  %-6v %d\n", vfdio.LatencyBuckets[len(vfdio.LatencyBuckets)-1], stats.Latency.Counts[len(vfdio.LatencyBuckets)])
+			fmt.Printf("Ramp latency: %v\n", hyInv.RampLatency())
+			usage := hyInv.Usage()
+			fmt.Printf("Run time: %v, energy: %.1f Wh\n", usage.RunTime.Round(time.Second), usage.EnergyWh)
+		} else if cmd == "help" {
+			fmt.Println("Commands: M3, M4, M5, Snnnn, $, ?, trace, audit, stats, fault, reset, identify, accel n, decel n, hz n, preset name, brake, estop, release, pause [n], resume, run file, exit, help.")
+		} else if cmd == "$" {
+			fmt.Println("Commands: M3, M4, M5, Snnnn, ?, $, trace, audit, stats, fault, reset, identify, accel n, decel n, hz n, preset name, brake, estop, release, pause [n], resume, run file, exit, help")
+		} else if cmd == "exit" {
+			continueScanning = false
+			break
+		} else if results, err := hyInv.EnqueueResults(cmd); err != nil {
+			fmt.Println("Error:", err)
+		} else {
+			go func() {
+				for result := range results {
+					if result.Err != nil {
+						fmt.Printf("\nError: %s: %v\n> ", result.Command, result.Err)
+					}
+				}
+			}()
+		}
+		fmt.Print("> ")
+	}
+	fmt.Println("End.")
+}
+
+// newLogger returns a logger writing to stderr at the level, nil if level is empty.
+func newLogger(level string) *slog.Logger {
+	if level == "" {
+		return nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		fmt.Println("Invalid log level:", err)
+		return nil
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l}))
+}
+
+// openErrorHint returns the error of Open with a hint how to fix it.
+func openErrorHint(err error) string {
+	switch {
+	case errors.Is(err, vfdio.ErrPortNotFound):
+		return fmt.Sprintf("%v\nCheck the adapter and pass its device with -port, see --help.", err)
+	case errors.Is(err, vfdio.ErrPermissionDenied):
+		return fmt.Sprintf("%v\nAdd the user to the group of the device, e.g. dialout, or run as administrator.", err)
+	case errors.Is(err, vfdio.ErrPortBusy):
+		return fmt.Sprintf("%v\nClose the other program using the port.", err)
+	case errors.Is(err, vfdio.ErrNotSerialPort):
+		return fmt.Sprintf("%v\nPass the device of the RS485 adapter with -port, see --help.", err)
+	}
+	return fmt.Sprintf("Failed to open serial port: %v", err)
+}
+
+// runProgram queues the spindle commands of a G-code file and prints the progress.
+func runProgram(hyInv *vfdio.HyInverter, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	lines := 0
+	err = hyInv.StreamProgram(context.Background(), file, vfdio.StreamOptions{
+		Name:      name,
+		StopAtEnd: true,
+		Progress:  func(p vfdio.StreamProgress) { lines = p.Line },
+	})
+	fmt.Println("Queued", lines, "lines of", name)
+	return err
+}
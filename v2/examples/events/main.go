@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This example subscribes to the events of a simulated VFD. It changes the speed at the
+// simulated front panel and interrupts the connection to show how faults are reported.
+package main
+
+import (
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"os"
+	"time"
+)
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	vfd := simulator.New()
+	vfd.MaxFrequency = 30000 // PD005 set to 300 Hz
+	handle := vfdio.NewVfd()
+	handle.SetPollValues(vfdio.StatusOutputFrequency, vfdio.StatusSetFrequency)
+	events := make(chan vfdio.Event, 10)
+	handle.Subscribe(func(e vfdio.Event) {
+		events <- e
+	})
+	handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond))
+	defer handle.Close()
+
+	// The drive limits the speed to its maximum frequency.
+	handle.GCode("M3 S11520")
+	if err := expect(w, events, vfdio.Clamped); err != nil {
+		return err
+	}
+	// An operator turns the speed down at the front panel.
+	vfd.PanelSetFrequency(10000)
+	if err := expect(w, events, vfdio.ExternalChange); err != nil {
+		return err
+	}
+	// The RS485 cable is unplugged and plugged in again.
+	vfd.SetSilent(true)
+	if err := expect(w, events, vfdio.Offline); err != nil {
+		return err
+	}
+	vfd.SetSilent(false)
+	if err := expect(w, events, vfdio.Online); err != nil {
+		return err
+	}
+	handle.GCode("M5")
+	return nil
+}
+
+// expect prints events until one of the given type was received.
+func expect(w io.Writer, events chan vfdio.Event, eventType vfdio.EventType) error {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			switch e.Type {
+			case vfdio.Clamped:
+				fmt.Fprintf(w, "%v: requested %d 1/min, VFD runs %d 1/min\n", e.Type, e.RequestedRpm, e.Rpm)
+			case vfdio.ExternalChange:
+				fmt.Fprintf(w, "%v: speed changed at the front panel to %d 1/min\n", e.Type, e.Rpm)
+			case vfdio.Offline:
+				fmt.Fprintf(w, "%v: %v\n", e.Type, e.Err)
+			default:
+				fmt.Fprintln(w, e.Type)
+			}
+			if e.Type == eventType {
+				return nil
+			}
+		case <-timeout:
+			return fmt.Errorf("no %v event", eventType)
+		}
+	}
+}
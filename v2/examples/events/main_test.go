@@ -0,0 +1,19 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "os"
+
+func Example() {
+	if err := run(os.Stdout); err != nil {
+		panic(err)
+	}
+	// Output:
+	// Started
+	// Clamped: requested 11520 1/min, VFD runs 8640 1/min
+	// ExternalChange: speed changed at the front panel to 2880 1/min
+	// Offline: vfdio: VFD offline: no data received
+	// Online
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This example polls the fault code of a simulated VFD. The drive trips while the spindle
+// runs, the program resets the fault and starts the spindle again.
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"os"
+	"time"
+)
+
+// faultParameter is the PDxxx parameter holding the fault code, see the manual of the drive.
+const faultParameter = 100
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	vfd := simulator.New()
+	handle := vfdio.NewVfd()
+	handle.SetFaultParameter(faultParameter)
+	events := make(chan vfdio.Event, 10)
+	// Handlers must not block, events are dropped while the buffer is full.
+	handle.Subscribe(func(e vfdio.Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+	if err := handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond)); err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	handle.GCode("M3 S5760")
+	// The drive trips, e.g. because of an overcurrent.
+	vfd.Trip(faultParameter, 4)
+	e, err := expect(events, vfdio.Fault)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%v: code %d, feed hold\n", e.Type, e.FaultCode)
+	if running, _ := vfd.Running(); !running {
+		fmt.Fprintln(w, "spindle stopped by the drive")
+	}
+	// The cause is gone, the stop command resets the drive like its STOP/RESET key.
+	handle.ResetFault()
+	if e, err = expect(events, vfdio.FaultCleared); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, e.Type)
+	handle.GCode("M3")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handle.WaitProcessed(ctx, vfdio.DefaultAtSpeedTolerance); err != nil {
+		return fmt.Errorf("spindle did not start again: %w", err)
+	}
+	fmt.Fprintln(w, "spindle at speed again")
+	handle.GCode("M5")
+	return nil
+}
+
+// expect waits for an event of the given type, other events are skipped.
+func expect(events chan vfdio.Event, eventType vfdio.EventType) (vfdio.Event, error) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == eventType {
+				return e, nil
+			}
+		case <-timeout:
+			return vfdio.Event{}, fmt.Errorf("no %v event", eventType)
+		}
+	}
+}
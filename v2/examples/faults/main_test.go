@@ -0,0 +1,18 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "os"
+
+func Example() {
+	if err := run(os.Stdout); err != nil {
+		panic(err)
+	}
+	// Output:
+	// Fault: code 4, feed hold
+	// spindle stopped by the drive
+	// FaultCleared
+	// spindle at speed again
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This example exposes a simulated VFD as Modbus TCP slave and controls it like a
+// SCADA system would: it writes the speed and run command into the holding registers
+// and reads the status values from the input registers.
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/gateway"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	vfd := simulator.New()
+	handle := vfdio.NewVfd()
+	handle.SetPollValues(vfdio.StatusSetFrequency, vfdio.StatusOutputFrequency)
+	handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond))
+	defer handle.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	go gateway.NewServer(handle).Serve(listener)
+
+	master, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+
+	// Write multiple registers: control = forward, speed = 5760 1/min
+	if _, err := transact(master, []byte{0x10, 0x00, 0x00, 0x00, 0x02, 0x04, 0x00, 0x01, 0x16, 0x80}); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "spindle started by the Modbus TCP master")
+
+	// Read input registers 0 and 1: set frequency and output frequency
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		response, err := transact(master, []byte{0x04, 0x00, 0x00, 0x00, 0x02})
+		if err != nil {
+			return err
+		}
+		if response[0] == 0x04 {
+			setFrequency := binary.BigEndian.Uint16(response[2:4])
+			outputFrequency := binary.BigEndian.Uint16(response[4:6])
+			if setFrequency != 0 && outputFrequency == setFrequency {
+				fmt.Fprintf(w, "output frequency reached %.2f Hz\n", float64(outputFrequency)/100)
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Write single register: control = stop
+	_, err = transact(master, []byte{0x06, 0x00, 0x00, 0x00, 0x00})
+	return err
+}
+
+// transact sends a Modbus TCP request and returns the response PDU.
+func transact(conn net.Conn, pdu []byte) ([]byte, error) {
+	request := append([]byte{0x00, 0x01, 0x00, 0x00, 0x00, byte(len(pdu) + 1), 0x01}, pdu...)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(header[4:6])-1)
+	_, err := io.ReadFull(conn, response)
+	return response, err
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This example serves a simulated VFD over HTTP, e.g. for a web front end: GET /status
+// returns the Status as JSON and POST /gcode queues the G-code line of the body. A
+// client starts the spindle, waits until it reached its speed and sends an invalid line.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	vfd := simulator.New()
+	handle := vfdio.NewVfd()
+	// Reject words which are no spindle commands instead of ignoring them
+	handle.SetStrictWords(true)
+	if err := handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond)); err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: newHandler(handle)}
+	go server.Serve(listener)
+	defer server.Close()
+	url := "http://" + listener.Addr().String()
+
+	if err := post(w, url+"/gcode", "M3 S5760"); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var status vfdio.Status
+		if err := get(url+"/status", &status); err != nil {
+			return err
+		}
+		if status.TargetRpm != 0 && status.OutputRpm == status.TargetRpm {
+			fmt.Fprintf(w, "GET /status: output %d 1/min\n", status.OutputRpm)
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := post(w, url+"/gcode", "G1 X10"); err != nil {
+		return err
+	}
+	return post(w, url+"/gcode", "M5")
+}
+
+// newHandler returns the HTTP handler of the spindle.
+func newHandler(handle *vfdio.HyInverter) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(handle.Status())
+	})
+	mux.HandleFunc("/gcode", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		line, err := io.ReadAll(io.LimitReader(r.Body, vfdio.DefaultMaxLineLength))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := handle.Enqueue(string(line)); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// post sends a G-code line and prints the answer.
+func post(w io.Writer, url, line string) error {
+	response, err := http.Post(url, "text/plain", strings.NewReader(line))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "POST /gcode %q: %s\n", line, strings.TrimSpace(response.Status+" "+string(body)))
+	return nil
+}
+
+// get decodes the JSON answer of a GET request.
+func get(url string, v interface{}) error {
+	response, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(v)
+}
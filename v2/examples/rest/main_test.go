@@ -0,0 +1,18 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "os"
+
+func Example() {
+	if err := run(os.Stdout); err != nil {
+		panic(err)
+	}
+	// Output:
+	// POST /gcode "M3 S5760": 202 Accepted
+	// GET /status: output 5760 1/min
+	// POST /gcode "G1 X10": 422 Unprocessable Entity vfdio: unsupported word at column 1: unsupported code: "G1"
+	// POST /gcode "M5": 202 Accepted
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// This example streams a G-code program to a simulated VFD, waits until the spindle
+// reached its speed and shows how a rejected program line is reported.
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const warmup = `%
+(Spindle warm-up)
+M3 S2880
+G0 X0 Y0
+S5760
+%
+`
+
+const broken = `M3 S5760
+G0 X10 S20000
+M5
+`
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	vfd := simulator.New()
+	handle := vfdio.NewVfd()
+	handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond))
+	defer handle.Close()
+	// Reject programs above the maximum speed instead of clamping them
+	handle.SetAboveMaximum(vfdio.RejectAboveMaximum)
+
+	if err := handle.StreamProgram(context.Background(), strings.NewReader(warmup), vfdio.StreamOptions{Name: "warmup.nc"}); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handle.WaitAtSpeed(ctx, vfdio.DefaultAtSpeedTolerance); err != nil {
+		return fmt.Errorf("spindle did not reach the commanded speed: %w", err)
+	}
+	fmt.Fprintln(w, "warmup.nc finished, spindle at speed")
+
+	err := handle.StreamProgram(context.Background(), strings.NewReader(broken), vfdio.StreamOptions{Name: "broken.nc"})
+	if lineErr, ok := err.(*vfdio.LineError); ok {
+		fmt.Fprintf(w, "broken.nc rejected at line %d, column %d: %s\n", lineErr.Line, lineErr.Column, lineErr.Reason)
+	}
+	handle.GCode("M5")
+	return nil
+}
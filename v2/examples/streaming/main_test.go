@@ -0,0 +1,16 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "os"
+
+func Example() {
+	if err := run(os.Stdout); err != nil {
+		panic(err)
+	}
+	// Output:
+	// warmup.nc finished, spindle at speed
+	// broken.nc rejected at line 2, column 8: speed exceeds maximum of 11520
+}
@@ -0,0 +1,10 @@
+module github.com/itschleemilch/huanyango/v2
+
+go 1.21
+
+require (
+	github.com/jacobsa/go-serial v0.0.0-20180131005756-15cf729a72d4
+	github.com/npat-efault/crc16 v0.0.0-20161013170008-4128ccbe47c3
+)
+
+require golang.org/x/sys v0.15.0 // indirect
@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAtSpeedTolerance is the tolerance of Processed in percent of the set frequency.
+const DefaultAtSpeedTolerance = 10
+
+// waitInterval is the interval at which WaitAtSpeed and the other Wait functions check
+// their condition. The output frequency itself is updated at the poll interval passed to Open.
+const waitInterval = 10 * time.Millisecond
+
+// AtSpeed returns true if all commands were processed and the output frequency is within
+// tolerancePercent of the set frequency, e.g. 2 for ±2 %.
+func (o *HyInverter) AtSpeed(tolerancePercent float64) bool {
+	return atomic.LoadInt32(&o.commandQueue) == 0 && o.outputWithin(tolerancePercent)
+}
+
+// WaitAtSpeed blocks until AtSpeed(tolerancePercent) is true. Returns the context's error
+// if it is done first, or ErrNotOpen or ErrClosed if the connection is not open.
+func (o *HyInverter) WaitAtSpeed(ctx context.Context, tolerancePercent float64) error {
+	return o.waitFor(ctx, func() bool {
+		return o.AtSpeed(tolerancePercent)
+	})
+}
+
+// outputWithin returns true if the output frequency is within tolerancePercent of the set
+// frequency.
+func (o *HyInverter) outputWithin(tolerancePercent float64) bool {
+	o.pollMutex.Lock()
+	setFrequency := float64(o.setFrequency)
+	value := float64(o.outputFrequency)
+	o.pollMutex.Unlock()
+	return value >= setFrequency*(1-tolerancePercent/100) && value <= setFrequency*(1+tolerancePercent/100)
+}
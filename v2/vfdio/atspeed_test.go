@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAtSpeed(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.setFrequency = 10000
+	hy.outputFrequency = 9700
+	if !hy.AtSpeed(5) || hy.AtSpeed(2) {
+		t.Fatal("tolerance not applied")
+	}
+	if processed, _, _ := hy.Processed(); !processed {
+		t.Fatal("not within the default tolerance")
+	}
+	hy.GCode("S1000")
+	if hy.AtSpeed(5) {
+		t.Fatal("at speed with queued commands")
+	}
+}
+
+func TestWaitAtSpeed(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.setFrequency = 10000
+	hy.outputFrequency = 9700
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := hy.WaitAtSpeed(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		hy.pollMutex.Lock()
+		hy.outputFrequency = 9900
+		hy.pollMutex.Unlock()
+	}()
+	if err := hy.WaitAtSpeed(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpeedReachedEvent(t *testing.T) {
+	hy, _ := newTestInverter()
+	var reached []Event
+	hy.Subscribe(func(e Event) {
+		if e.Type == SpeedReached {
+			reached = append(reached, e)
+		}
+	})
+	hy.sendFrequency(10000)
+	for _, output := range []uint16{5000, 9950, 10000} {
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), byte(output >> 8), byte(output)}))
+	}
+	if len(reached) != 1 || reached[0].Frequency != 9950 {
+		t.Fatalf("expected one SpeedReached event at 9950, got %v", reached)
+	}
+}
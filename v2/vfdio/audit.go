@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// queuedCommand is a command word in the command queue.
+type queuedCommand struct {
+	word     string
+	enqueued time.Time
+	results  *resultSink
+	id       CommandID
+	// internal is set for the words created by the library, see lineWord.
+	internal bool
+}
+
+// CommandRecord holds the timestamps of a command word which was sent to the VFD. Use them
+// to correlate the spindle with the log of a motion controller.
+type CommandRecord struct {
+	Command string
+	// Enqueued is the time the command was queued by GCode, Enqueue or StreamProgram.
+	Enqueued time.Time
+	// Transmitted is the time its first frame was written to the port.
+	Transmitted time.Time
+	// Acknowledged is the time the VFD answered the last frame, zero if it did not answer.
+	Acknowledged time.Time
+	// Err tells why the command failed, e.g. a write error of the port. Nil on success.
+	Err error
+}
+
+func (r CommandRecord) String() string {
+	const layout = "15:04:05.000000"
+	acknowledged := "not acknowledged"
+	if !r.Acknowledged.IsZero() {
+		acknowledged = "acknowledged " + r.Acknowledged.Format(layout)
+	}
+	if r.Err != nil {
+		acknowledged += " failed: " + r.Err.Error()
+	}
+	return fmt.Sprintf("%s enqueued %s transmitted %s %s", r.Command, r.Enqueued.Format(layout),
+		r.Transmitted.Format(layout), acknowledged)
+}
+
+// commandLog is a ring buffer of the latest command records.
+type commandLog struct {
+	mutex   sync.Mutex
+	records []CommandRecord
+	next    int
+	full    bool
+}
+
+// SetCommandLog enables the audit log of the latest commands sent to the VFD and the
+// CommandCompleted event. Capacity is the number of commands kept. 0 disables both.
+// Default: 0.
+func (o *HyInverter) SetCommandLog(capacity int) {
+	l := &o.commandLog
+	l.mutex.Lock()
+	l.records = make([]CommandRecord, capacity)
+	l.next, l.full = 0, false
+	l.mutex.Unlock()
+}
+
+// CommandLog returns the logged commands, oldest first.
+func (o *HyInverter) CommandLog() []CommandRecord {
+	l := &o.commandLog
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.full {
+		return append([]CommandRecord(nil), l.records[:l.next]...)
+	}
+	return append(append([]CommandRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// WriteCommandLog writes the logged commands as text, one command per line.
+func (o *HyInverter) WriteCommandLog(w io.Writer) error {
+	for _, record := range o.CommandLog() {
+		if _, err := fmt.Fprintln(w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startAudit begins the record of a command taken from the queue.
+func (o *HyInverter) startAudit(command queuedCommand) {
+	o.txStats.mutex.Lock()
+	o.txStats.command = &CommandRecord{Command: command.word, Enqueued: command.enqueued}
+	o.txStats.mutex.Unlock()
+}
+
+// finishAudit logs the record of the executed command and raises CommandCompleted if a
+// frame was sent for it or it failed and the command log is enabled. It returns true if a
+// frame was sent for the command and the VFD did not answer.
+func (o *HyInverter) finishAudit(err error) (unanswered bool) {
+	o.txStats.mutex.Lock()
+	record := o.txStats.command
+	o.txStats.command = nil
+	o.txStats.mutex.Unlock()
+	if record == nil || (record.Transmitted.IsZero() && err == nil) {
+		return false
+	}
+	unanswered = !record.Transmitted.IsZero() && record.Acknowledged.IsZero()
+	record.Err = err
+	l := &o.commandLog
+	l.mutex.Lock()
+	enabled := len(l.records) > 0
+	if enabled {
+		l.records[l.next] = *record
+		if l.next++; l.next == len(l.records) {
+			l.next = 0
+			l.full = true
+		}
+	}
+	l.mutex.Unlock()
+	if enabled {
+		o.emit(Event{Type: CommandCompleted, Command: *record})
+	}
+	return unanswered
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandLog(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetCommandLog(2)
+	var events []Event
+	hy.Subscribe(func(e Event) {
+		if e.Type == CommandCompleted {
+			events = append(events, e)
+		}
+	})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, 0x09}))
+	}()
+	start := time.Now()
+	hy.GCode("G0 M3 S1000 M4")
+	for i := 0; i < 4; i++ {
+		hy.processNext()
+	}
+	log := hy.CommandLog()
+	// G0 sends no frame, M3 was dropped from the full log.
+	if len(log) != 2 || log[0].Command != "S1000" || log[1].Command != "M4" {
+		t.Fatalf("unexpected log %v", log)
+	}
+	if len(events) != 3 || events[0].Type != CommandCompleted || events[0].Command.Command != "M3" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	m3 := events[0].Command
+	if m3.Enqueued.Before(start) || m3.Transmitted.Before(m3.Enqueued) || m3.Acknowledged.Before(m3.Transmitted.Add(50*time.Millisecond)) {
+		t.Fatalf("unexpected timestamps %v", m3)
+	}
+	if !log[1].Acknowledged.IsZero() {
+		t.Fatalf("unanswered command acknowledged: %v", log[1])
+	}
+	var text strings.Builder
+	hy.WriteCommandLog(&text)
+	if lines := strings.Split(strings.TrimSpace(text.String()), "\n"); len(lines) != 2 || !strings.HasSuffix(lines[1], "not acknowledged") {
+		t.Fatalf("unexpected text %q", text.String())
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"math"
+)
+
+// Stop and DC brake parameters of the HY series.
+const (
+	// ParameterStopMode is PD026, 0 decelerates to stop, 1 lets the spindle coast.
+	ParameterStopMode byte = registers.PD026
+	// ParameterStopBrakeTime is PD028, the DC braking time at stop in 0.1 s.
+	ParameterStopBrakeTime byte = registers.PD028
+	// ParameterBrakeFrequency is PD029, the output frequency in 0.01 Hz at which DC
+	// braking starts while decelerating.
+	ParameterBrakeFrequency byte = registers.PD029
+	// ParameterBrakeVoltage is PD030, the DC braking voltage in percent of the rated voltage.
+	ParameterBrakeVoltage byte = registers.PD030
+)
+
+// Stop modes of PD026.
+const (
+	stopModeDecelerate = 0
+	stopModeCoast      = 1
+)
+
+// Ranges of the DC brake parameters.
+const (
+	maxBrakeTime    = 25.0
+	maxBrakeVoltage = 15.0
+)
+
+// DCBrake configures DC injection braking at stop.
+type DCBrake struct {
+	// Time is the braking time in seconds, up to 25 s. 0 disables DC braking.
+	Time float64
+	// StartHz is the output frequency at which braking starts.
+	StartHz float64
+	// Voltage is the braking voltage in percent of the rated voltage, up to 15 %. Higher
+	// values brake harder, but heat the motor.
+	Voltage float64
+}
+
+// SetDCBrake writes the DC brake parameters (PD028 to PD030) and waits until the VFD
+// confirmed them. They are used by BrakeStop and by every stop while PD026 selects
+// decelerating stop.
+func (o *HyInverter) SetDCBrake(brake DCBrake) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	if !(brake.Time >= 0 && brake.Time <= maxBrakeTime) {
+		return fmt.Errorf("%w: PD%03d: braking time %v s, allowed [0, %v]", ErrOutOfRange, ParameterStopBrakeTime, brake.Time, maxBrakeTime)
+	}
+	if !(brake.StartHz >= 0 && brake.StartHz*100 <= maxFrequencyRegister) {
+		return fmt.Errorf("%w: PD%03d: braking frequency %v Hz", ErrOutOfRange, ParameterBrakeFrequency, brake.StartHz)
+	}
+	if !(brake.Voltage >= 0 && brake.Voltage <= maxBrakeVoltage) {
+		return fmt.Errorf("%w: PD%03d: braking voltage %v %%, allowed [0, %v]", ErrOutOfRange, ParameterBrakeVoltage, brake.Voltage, maxBrakeVoltage)
+	}
+	for _, parameter := range []struct {
+		number byte
+		value  float64
+	}{{ParameterStopBrakeTime, brake.Time * 10}, {ParameterBrakeFrequency, brake.StartHz * 100}, {ParameterBrakeVoltage, brake.Voltage}} {
+		if err := o.writeParameter(parameter.number, uint16(math.Floor(parameter.value+0.5))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BrakeStop stops the spindle faster than a coast: it decelerates with the deceleration time
+// (PD015) and brakes with DC injection as set by SetDCBrake. If the stop mode (PD026) is
+// coasting, it is switched to decelerating stop first; the change is kept. The stop is
+// queued like M5.
+func (o *HyInverter) BrakeStop() error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	mode, err := o.readParameter(ParameterStopMode)
+	if err != nil {
+		return err
+	}
+	if mode == stopModeCoast {
+		if err := o.writeParameter(ParameterStopMode, stopModeDecelerate); err != nil {
+			return err
+		}
+	}
+	return o.Enqueue("M5")
+}
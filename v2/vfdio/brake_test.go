@@ -0,0 +1,39 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestBrakeStop(t *testing.T) {
+	vfd := simulator.New()
+	vfd.Parameters[ParameterStopMode] = stopModeCoast
+	hy := NewVfd()
+	if err := hy.BrakeStop(); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if err := hy.SetDCBrake(DCBrake{Time: 1.5, StartHz: 5, Voltage: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if vfd.Parameters[ParameterStopBrakeTime] != 15 || vfd.Parameters[ParameterBrakeFrequency] != 500 || vfd.Parameters[ParameterBrakeVoltage] != 10 {
+		t.Fatalf("unexpected parameters %v", vfd.Parameters)
+	}
+	if err := hy.SetDCBrake(DCBrake{Time: 30}); err == nil {
+		t.Fatal("expected an error for 30 s")
+	}
+	if err := hy.BrakeStop(); err != nil {
+		t.Fatal(err)
+	}
+	if vfd.Parameters[ParameterStopMode] != stopModeDecelerate {
+		t.Fatal("stop mode not changed")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoResponse is reported by LastError while the circuit breaker is open.
+var ErrNoResponse = fmt.Errorf("%w: requests not answered", ErrOffline)
+
+// Circuit breaker defaults.
+const (
+	defaultBreakerThreshold     = 5
+	defaultBreakerProbeInterval = 2 * time.Second
+)
+
+// SetCircuitBreaker configures the circuit breaker. After threshold requests in a row were not
+// answered, the breaker opens: queued commands and polls are held back, the VFD is reported
+// offline and only one status read is sent per probe interval. Stop words are sent anyway,
+// so M5 and EStop reach the VFD once it answers again. The first answer closes the breaker
+// and the held back requests are sent. A threshold of 0 disables the breaker.
+// Default: 5 requests, 2 seconds.
+func (o *HyInverter) SetCircuitBreaker(threshold int, probeInterval time.Duration) {
+	o.stateMutex.Lock()
+	o.breakerThreshold = threshold
+	o.breakerProbeInterval = probeInterval
+	o.breakerConfigured = true
+	o.stateMutex.Unlock()
+}
+
+// circuitBreaker returns the threshold and probe interval.
+func (o *HyInverter) circuitBreaker() (threshold int, probeInterval time.Duration) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if !o.breakerConfigured {
+		return defaultBreakerThreshold, defaultBreakerProbeInterval
+	}
+	return o.breakerThreshold, o.breakerProbeInterval
+}
+
+// CircuitOpen returns true while the circuit breaker holds back requests.
+func (o *HyInverter) CircuitOpen() bool {
+	o.txStats.mutex.Lock()
+	defer o.txStats.mutex.Unlock()
+	return o.txStats.open
+}
+
+// countFailure is called for every unanswered request and opens the breaker at the threshold.
+func (o *HyInverter) countFailure() {
+	threshold, _ := o.circuitBreaker()
+	s := &o.txStats
+	s.mutex.Lock()
+	s.failures++
+	opened := threshold > 0 && !s.open && s.failures >= threshold
+	if opened {
+		s.open = true
+	}
+	s.mutex.Unlock()
+	if opened {
+		o.setOffline(ErrNoResponse)
+		o.emit(Event{Type: CircuitOpen, Err: ErrNoResponse})
+	}
+}
+
+// countSuccess is called for every answered request and closes the breaker.
+func (o *HyInverter) countSuccess() {
+	s := &o.txStats
+	s.mutex.Lock()
+	s.failures = 0
+	closed := s.open
+	s.open = false
+	s.mutex.Unlock()
+	if closed {
+		o.emit(Event{Type: CircuitClosed})
+	}
+}
+
+// probeCircuit waits for the probe interval and sends a single status read. Stop words are
+// taken from the queue and sent meanwhile, the other commands are held back, see holdBack.
+func (o *HyInverter) probeCircuit() {
+	_, probeInterval := o.circuitBreaker()
+	timer := o.currentClock().NewTimer(probeInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case command := <-o.cmdChannel:
+			o.holdBack(command)
+			if !o.CircuitOpen() {
+				// Closed by the answer to a stop
+				return
+			}
+		case <-timer.C():
+			if o.CircuitOpen() {
+				o.readStatus(StatusOutputFrequency)
+			}
+			return
+		case <-o.done:
+			return
+		}
+	}
+}
+
+// holdBack keeps a command taken from the queue while it is held back, it is executed after
+// the commands held before, see takeHeldCommand. A stop word is sent at once instead. If
+// commands are held, it is repeated in its place, so they can't start the spindle again.
+func (o *HyInverter) holdBack(command queuedCommand) {
+	stop := isStopWord(command.word)
+	o.stateMutex.Lock()
+	if !stop {
+		o.heldCommands = append(o.heldCommands, command)
+		o.stateMutex.Unlock()
+		return
+	}
+	if len(o.heldCommands) > 0 {
+		o.heldCommands = append(o.heldCommands, queuedCommand{word: command.word, enqueued: command.enqueued})
+		atomic.AddInt32(&o.commandQueue, 1)
+		atomic.AddInt32(&o.preemptions, 1)
+	}
+	o.stateMutex.Unlock()
+	o.runCommand(command)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetCircuitBreaker(2, 20*time.Millisecond)
+	var events []EventType
+	hy.Subscribe(func(e Event) { events = append(events, e.Type) })
+	request := hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00})
+	for i := 0; i < 3; i++ {
+		hy.write(request)
+	}
+	if !hy.CircuitOpen() || hy.LastError() != ErrNoResponse {
+		t.Fatalf("circuit not opened, last error %v", hy.LastError())
+	}
+	// Commands are held back, only the probe is sent.
+	hy.GCode("M3")
+	sent := len(port.Bytes())
+	hy.processNext()
+	frames := port.Bytes()[sent:]
+	if len(frames) != 8 || frames[1] != byte(FunctionReadStatus) || len(hy.heldCommands) != 1 || hy.QueueDepth() != 1 {
+		t.Fatalf("unexpected probe % X", frames)
+	}
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	if hy.CircuitOpen() {
+		t.Fatal("circuit not closed by the answer")
+	}
+	hy.processNext()
+	if len(hy.heldCommands) != 0 || hy.QueueDepth() != 0 {
+		t.Fatal("held back command not sent")
+	}
+	expected := []EventType{Offline, CircuitOpen, CircuitClosed, Online}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("unexpected events %v", events)
+		}
+	}
+}
+
+func TestCircuitBreakerStop(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetStopEscalation(0, nil)
+	hy.SetCircuitBreaker(1, 20*time.Millisecond)
+	request := hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00})
+	for i := 0; i < 2; i++ {
+		hy.write(request)
+	}
+	if !hy.CircuitOpen() {
+		t.Fatal("circuit not opened")
+	}
+	// The stop is sent at once and repeated after the held back M3.
+	hy.GCode("M3 M5")
+	sent := len(port.Bytes())
+	hy.processNext()
+	stop := hy.controlFrame(CommandStop)
+	if frames := port.Bytes()[sent:]; !bytes.HasPrefix(frames, stop) {
+		t.Fatalf("stop not sent while the circuit is open: % X", frames)
+	}
+	if len(hy.heldCommands) != 2 || hy.QueueDepth() != 2 {
+		t.Fatalf("expected M3 and the stop to be held, got %d", len(hy.heldCommands))
+	}
+	parseModbusRTU(hy, request)
+	hy.processNext()
+	sent = len(port.Bytes())
+	hy.processNext()
+	if !bytes.Equal(port.Bytes()[sent:], stop) || hy.QueueDepth() != 0 {
+		t.Fatalf("stop not repeated after the held back command: % X", port.Bytes()[sent:])
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package bridge places a Huanyang VFD inline between a G-code sender and a motion
+// controller like GRBL or Smoothieware. The spindle words (S, M3, M4, M5) of each line are
+// sent to the VFD, everything else is forwarded to the controller. Before the next line is
+// forwarded after a spindle command, the bridge waits until the spindle is at speed, so
+// motion does not resume with a slow spindle.
+//
+// Lines holding nothing but spindle words are acknowledged with "ok" by the bridge, like
+// the controller would. The answers of the controller and the real-time commands of the
+// sender (?, !, ~, Ctrl-X and bytes above 0x7F) are relayed unchanged.
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default timing of the at-speed wait.
+const (
+	DefaultSettleTime     = 250 * time.Millisecond
+	DefaultAtSpeedTimeout = 30 * time.Second
+)
+
+// atSpeedPollInterval is the rate at which the spindle state is checked.
+const atSpeedPollInterval = 50 * time.Millisecond
+
+// ErrNotAtSpeed is reported to the sender if the spindle did not reach the speed in time.
+var ErrNotAtSpeed = errors.New("bridge: spindle not at speed")
+
+// ErrControllerClosed is returned by Run if the controller reached the end of its output.
+var ErrControllerClosed = errors.New("bridge: controller closed the connection")
+
+// Spindle is the part of vfdio.HyInverter used by the bridge.
+type Spindle interface {
+	StreamProgram(ctx context.Context, r io.Reader, opts vfdio.StreamOptions) error
+	Processed() (processed, outputFrequencyOk, commandsProcessed bool)
+	ControlStatus() (status vfdio.ControlStatus, ok bool)
+}
+
+// Bridge connects a sender and a controller, see the package documentation.
+type Bridge struct {
+	spindle Spindle
+	// SettleTime is waited after the spindle commands were sent, so the answers of the
+	// VFD are received. Default: DefaultSettleTime.
+	SettleTime time.Duration
+	// AtSpeedTimeout is the longest wait for the spindle. If it elapses, the line is not
+	// forwarded and an error is reported to the sender. Default: DefaultAtSpeedTimeout.
+	AtSpeedTimeout time.Duration
+	senderMutex    sync.Mutex
+}
+
+// New creates a bridge for the given spindle, usually a *vfdio.HyInverter.
+func New(spindle Spindle) *Bridge {
+	return &Bridge{spindle: spindle, SettleTime: DefaultSettleTime, AtSpeedTimeout: DefaultAtSpeedTimeout}
+}
+
+// Run relays between sender and controller until one of them fails or the sender reaches
+// the end of its input. Then both are closed, so the other direction ends as well, and Run
+// returns after both ended. It returns the error of the direction which ended first; io.EOF
+// of the sender is not reported as an error, the one of the controller as ErrControllerClosed.
+func (b *Bridge) Run(sender, controller io.ReadWriteCloser) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	program := make(chan error, 1)
+	answers := make(chan error, 1)
+	go func() {
+		program <- b.relayProgram(ctx, sender, controller)
+	}()
+	go func() {
+		answers <- b.relayAnswers(controller, sender)
+	}()
+	var err error
+	remaining := answers
+	select {
+	case err = <-program:
+		if err == io.EOF {
+			err = nil
+		}
+	case err = <-answers:
+		if err == io.EOF {
+			err = ErrControllerClosed
+		}
+		remaining = program
+	}
+	// Reads can't be canceled otherwise.
+	cancel()
+	sender.Close()
+	controller.Close()
+	<-remaining
+	return err
+}
+
+// relayAnswers copies the lines of the controller to the sender.
+func (b *Bridge) relayAnswers(controller io.Reader, sender io.Writer) error {
+	reader := bufio.NewReader(controller)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if writeErr := b.answer(sender, line); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// answer writes a line to the sender. Lines of the bridge and the controller don't mix.
+func (b *Bridge) answer(sender io.Writer, line string) error {
+	b.senderMutex.Lock()
+	defer b.senderMutex.Unlock()
+	_, err := io.WriteString(sender, line)
+	return err
+}
+
+// relayProgram reads the lines of the sender, sends their spindle words to the VFD and
+// forwards the rest to the controller. It ends when ctx is done.
+func (b *Bridge) relayProgram(ctx context.Context, sender io.ReadWriter, controller io.Writer) error {
+	reader := bufio.NewReader(sender)
+	var line []byte
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case isRealtime(c):
+			if _, err := controller.Write([]byte{c}); err != nil {
+				return err
+			}
+		case c == '\n' || c == '\r':
+			if len(line) > 0 {
+				if err := b.relayLine(ctx, string(line), sender, controller); err != nil {
+					return err
+				}
+				line = line[:0]
+			}
+		default:
+			line = append(line, c)
+		}
+	}
+}
+
+// relayLine processes a single line of the sender.
+func (b *Bridge) relayLine(ctx context.Context, line string, sender, controller io.Writer) error {
+	var rest bytes.Buffer
+	if err := b.spindle.StreamProgram(ctx, strings.NewReader(line), vfdio.StreamOptions{Name: "sender", Tee: &rest}); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return b.answer(sender, "error: "+err.Error()+"\r\n")
+	}
+	forwarded := strings.TrimSuffix(rest.String(), "\n")
+	if forwarded != line {
+		if err := b.waitAtSpeed(ctx); err == ErrNotAtSpeed {
+			return b.answer(sender, "error: "+err.Error()+"\r\n")
+		} else if err != nil {
+			return err
+		}
+	}
+	if forwarded == "" {
+		return b.answer(sender, "ok\r\n")
+	}
+	_, err := io.WriteString(controller, forwarded+"\n")
+	return err
+}
+
+// waitAtSpeed waits until the spindle commands were sent and a running spindle reached
+// the set frequency. It returns ErrNotAtSpeed after AtSpeedTimeout or the error of ctx.
+func (b *Bridge) waitAtSpeed(ctx context.Context) error {
+	deadline := time.Now().Add(b.AtSpeedTimeout)
+	for {
+		if _, _, commandsProcessed := b.spindle.Processed(); commandsProcessed {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrNotAtSpeed
+		}
+		if err := sleep(ctx, atSpeedPollInterval); err != nil {
+			return err
+		}
+	}
+	if err := sleep(ctx, b.SettleTime); err != nil {
+		return err
+	}
+	for {
+		status, ok := b.spindle.ControlStatus()
+		if ok && status&vfdio.ControlStatusRunCommand == 0 {
+			return nil
+		}
+		if _, outputFrequencyOk, _ := b.spindle.Processed(); outputFrequencyOk {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrNotAtSpeed
+		}
+		if err := sleep(ctx, atSpeedPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d and returns the error of ctx if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRealtime returns true for the single byte commands of GRBL which bypass the line buffer.
+func isRealtime(c byte) bool {
+	return c == '?' || c == '!' || c == '~' || c == 0x18 || c >= 0x80
+}
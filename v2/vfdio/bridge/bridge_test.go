@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"bytes"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// port is one side of the bridge. Reads return the given input, writes are recorded.
+type port struct {
+	io.Reader
+	mutex   sync.Mutex
+	written bytes.Buffer
+	// onWrite is called with every write.
+	onWrite func(p []byte)
+}
+
+func (p *port) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.onWrite != nil {
+		p.onWrite(b)
+	}
+	return p.written.Write(b)
+}
+
+// Close closes the reader if it can be closed, so a blocked read returns.
+func (p *port) Close() error {
+	if closer, ok := p.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (p *port) String() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.written.String()
+}
+
+func TestBridge(t *testing.T) {
+	vfd := simulator.New()
+	hy := vfdio.NewVfd()
+	hy.SetPollValues(vfdio.StatusOutputFrequency)
+	if err := hy.OpenPort(vfd, vfdio.WithMaxRpm(24000), vfdio.WithPollInterval(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+
+	sender := &port{Reader: strings.NewReader("G21\r\nS6000 M3\r\n?G1 X10 F100 (cut)\r\nM5\r\nG0 Z5\r\n")}
+	controllerIn, controllerAnswers := io.Pipe()
+	defer controllerAnswers.Close()
+	controller := &port{Reader: controllerIn}
+	controller.onWrite = func(b []byte) {
+		if strings.HasPrefix(string(b), "G1") {
+			if running, _ := vfd.Running(); !running || vfd.OutputFrequency() < vfd.SetFrequency()*9/10 {
+				t.Errorf("motion forwarded before the spindle was at speed: %d", vfd.OutputFrequency())
+			}
+		}
+	}
+	if err := New(hy).Run(sender, controller); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "G21\n?G1 X10 F100 (cut)\nG0 Z5\n"; controller.String() != expected {
+		t.Errorf("controller: expected %q, got %q", expected, controller.String())
+	}
+	if expected := "ok\r\nok\r\n"; sender.String() != expected {
+		t.Errorf("sender: expected %q, got %q", expected, sender.String())
+	}
+	if running, _ := vfd.Running(); running {
+		t.Error("spindle not stopped")
+	}
+	// The answers of the controller are no longer read.
+	if _, err := controllerAnswers.Write([]byte("ok\r\n")); err != io.ErrClosedPipe {
+		t.Errorf("controller not closed at the end of the sender: %v", err)
+	}
+}
+
+func TestBridgeControllerClosed(t *testing.T) {
+	vfd := simulator.New()
+	hy := vfdio.NewVfd()
+	if err := hy.OpenPort(vfd, vfdio.WithMaxRpm(24000), vfdio.WithPollInterval(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	senderIn, senderLines := io.Pipe()
+	defer senderLines.Close()
+	sender := &port{Reader: senderIn}
+	controller := &port{Reader: strings.NewReader("Grbl 1.1h ['$' for help]\r\n")}
+	done := make(chan error, 1)
+	go func() {
+		done <- New(hy).Run(sender, controller)
+	}()
+	select {
+	case err := <-done:
+		if err != ErrControllerClosed {
+			t.Fatalf("expected ErrControllerClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run blocked on the sender after the controller ended")
+	}
+	if sender.String() != "Grbl 1.1h ['$' for help]\r\n" {
+		t.Errorf("answer not relayed: %q", sender.String())
+	}
+}
+
+func TestBridgeRejectsInvalidLines(t *testing.T) {
+	vfd := simulator.New()
+	hy := vfdio.NewVfd()
+	if err := hy.OpenPort(vfd, vfdio.WithMaxRpm(24000), vfdio.WithPollInterval(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.SetAboveMaximum(vfdio.RejectAboveMaximum)
+	sender := &port{Reader: strings.NewReader("S30000 M3\n")}
+	controllerIn, controllerAnswers := io.Pipe()
+	defer controllerAnswers.Close()
+	controller := &port{Reader: controllerIn}
+	if err := New(hy).Run(sender, controller); err != nil {
+		t.Fatal(err)
+	}
+	if controller.String() != "" || !strings.HasPrefix(sender.String(), "error: ") {
+		t.Fatalf("invalid line accepted: sender %q, controller %q", sender.String(), controller.String())
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ErrInvalidConfig is returned by Config.Validate and NewVfdFromConfig.
+var ErrInvalidConfig = errors.New("vfdio: invalid configuration")
+
+// maxSlaveAddress is the highest unicast address of Modbus, higher ones are reserved.
+const maxSlaveAddress = 247
+
+// Config holds the settings of Open in one struct, e.g. for configuration files. Zero
+// values select the defaults of the corresponding options, except for MaxRpm.
+type Config struct {
+	// MaxRpm is the maximum allowed RPM, see WithMaxRpm. It has to be given.
+	MaxRpm uint16
+	// RpmToHertz is the frequency register value per RPM, see WithRpmToHertz.
+	RpmToHertz float64
+	// PollInterval is the interval of the status polls, see WithPollInterval.
+	PollInterval time.Duration
+	// BaudRate is the baud rate of the serial port, see SetBaudRate.
+	BaudRate uint
+	// SlaveAddress is the RS485 address of the VFD, see SetSlaveAddress.
+	SlaveAddress byte
+	// QueueSize is the number of command words which can be queued, see WithQueueSize.
+	QueueSize int
+	// OnlineThreshold is the time after the last message for which the VFD is considered
+	// online, see SetOnlineThreshold.
+	OnlineThreshold time.Duration
+	// Logger receives structured logs, see WithLogger. It is not read from files.
+	Logger *slog.Logger
+}
+
+// Validate returns an error wrapping ErrInvalidConfig if a setting can't work: MaxRpm is 0,
+// RpmToHertz is negative or converts MaxRpm to less than 1 or more than the frequency
+// register, PollInterval is shorter than a request and its answer at the baud rate,
+// SlaveAddress is reserved or QueueSize or OnlineThreshold is negative.
+func (c Config) Validate() error {
+	if c.MaxRpm == 0 {
+		return fmt.Errorf("%w: max RPM is 0", ErrInvalidConfig)
+	}
+	if math.IsNaN(c.RpmToHertz) || math.IsInf(c.RpmToHertz, 0) || c.RpmToHertz < 0 {
+		return fmt.Errorf("%w: RPM conversion factor %v", ErrInvalidConfig, c.RpmToHertz)
+	}
+	if c.RpmToHertz > 0 {
+		maxFrequency := float64(c.MaxRpm) * c.RpmToHertz
+		if maxFrequency < 1 {
+			return fmt.Errorf("%w: RPM conversion factor %v is too small for %d RPM", ErrInvalidConfig, c.RpmToHertz, c.MaxRpm)
+		}
+		if maxFrequency > maxFrequencyRegister {
+			return fmt.Errorf("%w: %d RPM exceed the frequency register with conversion factor %v", ErrInvalidConfig, c.MaxRpm, c.RpmToHertz)
+		}
+	}
+	if c.PollInterval < 0 {
+		return fmt.Errorf("%w: negative poll interval", ErrInvalidConfig)
+	}
+	baudRate := c.BaudRate
+	if baudRate == 0 {
+		baudRate = 9600
+	}
+	if minimum := transactionTime(baudRate); c.PollInterval > 0 && c.PollInterval <= minimum {
+		return fmt.Errorf("%w: poll interval %v is not longer than a transaction of %v at %d baud", ErrInvalidConfig, c.PollInterval, minimum, baudRate)
+	}
+	if c.SlaveAddress > maxSlaveAddress {
+		return fmt.Errorf("%w: slave address %d is reserved", ErrInvalidConfig, c.SlaveAddress)
+	}
+	if c.OnlineThreshold < 0 {
+		return fmt.Errorf("%w: negative online threshold", ErrInvalidConfig)
+	}
+	if c.QueueSize < 0 {
+		return fmt.Errorf("%w: negative queue size %d", ErrInvalidConfig, c.QueueSize)
+	}
+	return nil
+}
+
+// Options returns the options of the configuration for Open.
+func (c Config) Options() []Option {
+	return []Option{
+		WithMaxRpm(c.MaxRpm),
+		WithRpmToHertz(c.RpmToHertz),
+		WithPollInterval(c.PollInterval),
+		WithBaudRate(c.BaudRate),
+		WithSlaveAddress(c.SlaveAddress),
+		WithQueueSize(c.QueueSize),
+		WithLogger(c.Logger),
+		WithOnlineThreshold(c.OnlineThreshold),
+	}
+}
+
+// NewVfdFromConfig validates cfg and creates a HyInverter using it. Options passed to Open
+// override the configuration.
+func NewVfdFromConfig(cfg Config) (*HyInverter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	o := NewVfd()
+	o.configOptions = cfg.Options()
+	openSettings{baudRate: cfg.BaudRate, slaveAddress: cfg.SlaveAddress, logger: cfg.Logger, staleAfter: cfg.OnlineThreshold}.apply(o)
+	return o, nil
+}
+
+// transactionTime returns the time of the longest request and answer at the baud rate.
+func transactionTime(baudRate uint) time.Duration {
+	character, _, t35 := characterTimes(baudRate)
+	return 2 * (time.Duration(registers.FrameLength(registers.MaxDataLength))*character + t35)
+}
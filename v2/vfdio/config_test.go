@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{MaxRpm: 11520, RpmToHertz: 3.47222, PollInterval: 750 * time.Millisecond}, true},
+		{Config{MaxRpm: 24000}, true},
+		{Config{}, false},
+		{Config{MaxRpm: 11520, RpmToHertz: -1}, false},
+		{Config{MaxRpm: 11520, RpmToHertz: math.NaN()}, false},
+		{Config{MaxRpm: 11520, RpmToHertz: 0.00001}, false},
+		{Config{MaxRpm: 24000, RpmToHertz: 3.47222}, false},
+		{Config{MaxRpm: 11520, PollInterval: 10 * time.Millisecond}, false},
+		{Config{MaxRpm: 11520, PollInterval: 20 * time.Millisecond, BaudRate: 38400}, true},
+		{Config{MaxRpm: 11520, PollInterval: -time.Second}, false},
+		{Config{MaxRpm: 11520, SlaveAddress: 248}, false},
+		{Config{MaxRpm: 11520, QueueSize: 500}, true},
+		{Config{MaxRpm: 11520, QueueSize: -1}, false},
+	}
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%+v: unexpected error %v", test.config, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: expected ErrInvalidConfig, got %v", test.config, err)
+		}
+	}
+}
+
+func TestNewVfdFromConfig(t *testing.T) {
+	if _, err := NewVfdFromConfig(Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	hy, err := NewVfdFromConfig(Config{MaxRpm: 12000, PollInterval: 10 * time.Second, BaudRate: 19200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hy.BaudRate() != 19200 {
+		t.Errorf("expected baud rate 19200, got %d", hy.BaudRate())
+	}
+	if err := hy.OpenPort(simulator.New()); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if hy.MaxRpm() != 12000 {
+		t.Errorf("expected max RPM 12000 of the config, got %d", hy.MaxRpm())
+	}
+
+	override, _ := NewVfdFromConfig(Config{MaxRpm: 12000})
+	if err := override.OpenPort(simulator.New(), WithMaxRpm(6000)); err != nil {
+		t.Fatal(err)
+	}
+	defer override.Close()
+	if override.MaxRpm() != 6000 {
+		t.Errorf("expected max RPM 6000 of the option, got %d", override.MaxRpm())
+	}
+}
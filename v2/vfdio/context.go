@@ -0,0 +1,224 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// OpenContext works like Open, but returns the context's error if it is done before the
+// serial port was opened, e.g. because the adapter hangs. A port which opens afterwards is
+// closed again, OpenContext can be called again in that case. Reconnects are not limited by
+// ctx. The parameter reads of Open are limited by their own timeout.
+func (o *HyInverter) OpenContext(ctx context.Context, portName string, opts ...Option) error {
+	return o.open(ctx, o.serialDial(portName), true, o.settings(opts))
+}
+
+// dialContext calls dial and returns early if ctx is done. The port returned later is closed.
+func dialContext(ctx context.Context, dial func() (io.ReadWriteCloser, error)) (io.ReadWriteCloser, error) {
+	if ctx.Done() == nil {
+		return dial()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type dialed struct {
+		port io.ReadWriteCloser
+		err  error
+	}
+	result := make(chan dialed, 1)
+	go func() {
+		port, err := dial()
+		result <- dialed{port, err}
+	}()
+	select {
+	case r := <-result:
+		return r.port, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-result; r.err == nil {
+				r.port.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// EnqueueContext works like Enqueue, but waits for queue space instead of returning
+// ErrQueueFull. If ctx is done first, the context's error is returned and the remaining
+// words of the line are not queued.
+func (o *HyInverter) EnqueueContext(ctx context.Context, cmd string) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return err
+	}
+	for _, word := range words {
+		if word.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		if err := o.queueContext(ctx, word, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GCodeWait works like GCode, but waits until the whole line fits into the command queue
+// instead of dropping the words which don't fit, so "M3 S12000" never starts the spindle
+// without its speed. The space is reserved for the line at once, so words queued
+// concurrently can't take it. Nothing of the line is queued if ctx is done while waiting for
+// space. A line longer than QueueCapacity is queued as space becomes available, ctx does not
+// cut it short after its first words were queued. It returns the errors of Enqueue except
+// ErrQueueFull, or the context's error.
+func (o *HyInverter) GCodeWait(ctx context.Context, cmd string) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return err
+	}
+	commands := 0
+	for _, word := range words {
+		if word.text != "?" {
+			commands++
+		}
+	}
+	reserved := min(commands, o.QueueCapacity())
+	if err := o.reserveContext(ctx, reserved); err != nil {
+		return err
+	}
+	for _, word := range words {
+		if word.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		if reserved == 0 {
+			if err := o.reserveContext(context.Background(), 1); err != nil {
+				return err
+			}
+			reserved++
+		}
+		reserved--
+		o.queueReserved(word, nil)
+	}
+	return nil
+}
+
+// queueContext adds a single command word to the command queue and waits for space.
+// The result of the command is sent to results unless it is nil.
+func (o *HyInverter) queueContext(ctx context.Context, word lineWord, results *resultSink) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	if err := o.reserveContext(ctx, 1); err != nil {
+		return err
+	}
+	o.queueReserved(word, results)
+	return nil
+}
+
+// reserve reserves the space of n words in the command queue for queueReserved. All ways of
+// queueing take their space this way, so a reservation can't be taken by others. Held back
+// commands keep their space. If the words don't fit, it returns false and a channel which
+// is closed when space was freed.
+func (o *HyInverter) reserve(n int) (ok bool, freed <-chan struct{}) {
+	o.queueMutex.Lock()
+	defer o.queueMutex.Unlock()
+	o.stateMutex.Lock()
+	held := len(o.heldCommands)
+	o.stateMutex.Unlock()
+	if len(o.cmdChannel)+o.reserved+held+n > cap(o.cmdChannel) {
+		if o.spaceFreed == nil {
+			o.spaceFreed = make(chan struct{})
+		}
+		return false, o.spaceFreed
+	}
+	o.reserved += n
+	return true, nil
+}
+
+// reserveContext waits until the space of n words was reserved, see reserve. Returns the
+// context's error if it is done first, or ErrClosed if Close was called.
+func (o *HyInverter) reserveContext(ctx context.Context, n int) error {
+	for {
+		ok, freed := o.reserve(n)
+		if ok {
+			return nil
+		}
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.done:
+			return ErrClosed
+		}
+	}
+}
+
+// freeSpace wakes the senders waiting for queue space after a word was taken from the queue.
+func (o *HyInverter) freeSpace() {
+	o.queueMutex.Lock()
+	if o.spaceFreed != nil {
+		close(o.spaceFreed)
+		o.spaceFreed = nil
+	}
+	o.queueMutex.Unlock()
+}
+
+// WaitProcessed blocks until all queued commands were processed and the output frequency is
+// within tolerancePercent of the set frequency, like Processed with DefaultAtSpeedTolerance.
+// Use it instead of polling Processed. It is the same as WaitAtSpeed.
+func (o *HyInverter) WaitProcessed(ctx context.Context, tolerancePercent float64) error {
+	return o.WaitAtSpeed(ctx, tolerancePercent)
+}
+
+// ReadStatus requests a status value and waits for the answer of the VFD. The value is
+// requested in addition to the poll values, see SetPollValues. Returns the context's error if
+// it is done first, or ErrNotOpen or ErrClosed if the connection is not open.
+func (o *HyInverter) ReadStatus(ctx context.Context, value StatusValue) (uint16, error) {
+	if value >= statusValueCount {
+		return 0, fmt.Errorf("%w: status value %d", ErrOutOfRange, value)
+	}
+	o.pollMutex.Lock()
+	received := o.statusReceived[value]
+	o.pollMutex.Unlock()
+	o.requestStatus(value)
+	var raw uint16
+	err := o.waitFor(ctx, func() bool {
+		o.pollMutex.Lock()
+		defer o.pollMutex.Unlock()
+		raw = o.status[value]
+		return o.statusReceived[value] != received
+	})
+	return raw, err
+}
+
+// waitFor polls done until it returns true.
+func (o *HyInverter) waitFor(ctx context.Context, done func() bool) error {
+	ticker := o.currentClock().NewTicker(waitInterval)
+	defer ticker.Stop()
+	for {
+		if err := o.checkOpen(); err != nil {
+			return err
+		}
+		if done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDialContext(t *testing.T) {
+	release := make(chan struct{})
+	closed := make(chan struct{})
+	dial := func() (io.ReadWriteCloser, error) {
+		<-release
+		return &closingPort{closed: closed}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	hy := NewVfd()
+	if err := hy.open(ctx, dial, false, newOpenSettings(nil)); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if err := hy.checkOpen(); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("late port not closed")
+	}
+}
+
+type closingPort struct {
+	testPort
+	closed chan struct{}
+}
+
+func (p *closingPort) Close() error {
+	close(p.closed)
+	return nil
+}
+
+func TestEnqueueContext(t *testing.T) {
+	hy, _ := newTestInverter()
+	for i := 0; i < cap(hy.cmdChannel); i++ {
+		hy.GCode("G0")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := hy.EnqueueContext(ctx, "S1000 M4"); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if hy.commandQueue != int32(cap(hy.cmdChannel)) {
+		t.Fatalf("unexpected queue counter %d", hy.commandQueue)
+	}
+	go func() {
+		for i := 0; i < cap(hy.cmdChannel)+2; i++ {
+			hy.processNext()
+		}
+	}()
+	if err := hy.EnqueueContext(context.Background(), "S1000 M4"); err != nil {
+		t.Fatal(err)
+	}
+	// The test port doesn't answer, so the output frequency is not checked.
+	if err := hy.waitFor(context.Background(), func() bool { return hy.QueueDepth() == 0 }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCodeWait(t *testing.T) {
+	hy, _ := newTestInverter()
+	for i := 0; i < cap(hy.cmdChannel)-1; i++ {
+		hy.GCode("G0")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := hy.GCodeWait(ctx, "M3 S1000"); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if queued := len(hy.cmdChannel); queued != cap(hy.cmdChannel)-1 {
+		t.Fatalf("part of the line was queued: %d words", queued)
+	}
+	go func() {
+		for i := 0; i < cap(hy.cmdChannel)+1; i++ {
+			hy.processNext()
+		}
+	}()
+	if err := hy.GCodeWait(context.Background(), "M3 S1000"); err != nil {
+		t.Fatal(err)
+	}
+	// The test port doesn't answer, so the output frequency is not checked.
+	if err := hy.waitFor(context.Background(), func() bool { return hy.QueueDepth() == 0 }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCodeWaitReserves(t *testing.T) {
+	hy, _ := newTestInverter()
+	for i := 0; i < cap(hy.cmdChannel)-2; i++ {
+		hy.GCode("G0")
+	}
+	// Reserved like the space of GCodeWait for a line of two words
+	if ok, _ := hy.reserve(2); !ok {
+		t.Fatal("space not reserved")
+	}
+	if err := hy.Enqueue("S100"); err != ErrQueueFull {
+		t.Fatalf("reserved space taken: %v", err)
+	}
+	hy.queueReserved(lineWord{text: "M3"}, nil)
+	hy.queueReserved(lineWord{text: "S1000"}, nil)
+
+	// A waiting line is woken when space was freed.
+	queued := make(chan error)
+	go func() {
+		queued <- hy.GCodeWait(context.Background(), "M5 S0")
+	}()
+	for i := 0; i < cap(hy.cmdChannel)-2; i++ {
+		<-hy.cmdChannel
+	}
+	hy.freeSpace()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("line not queued after space was freed")
+	}
+	var words []string
+	for len(hy.cmdChannel) > 0 {
+		words = append(words, (<-hy.cmdChannel).word)
+	}
+	if expected := []string{"M3", "S1000", "M5", "S0"}; !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %q, got %q", expected, words)
+	}
+}
+
+func TestReadStatus(t *testing.T) {
+	hy, _ := newTestInverter()
+	go func() {
+		<-hy.pollChannel
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusTemperature), 0x00, 0x2A}))
+	}()
+	if value, err := hy.ReadStatus(context.Background(), StatusTemperature); err != nil || value != 42 {
+		t.Fatalf("expected 42, got %d, %v", value, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := hy.ReadStatus(ctx, StatusTemperature); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestWaitProcessed(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.setFrequency = 10000
+	hy.outputFrequency = 9700
+	hy.GCode("G0")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := hy.WaitProcessed(ctx, 5); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout with a queued command, got %v", err)
+	}
+	hy.processNext()
+	if err := hy.WaitProcessed(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := hy.WaitProcessed(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout outside the tolerance, got %v", err)
+	}
+}
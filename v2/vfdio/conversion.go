@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import "math"
+
+// maxFrequencyRegister is the highest value of the 16 bit frequency register.
+const maxFrequencyRegister = math.MaxUint16
+
+// FrequencyResolution is the step of the frequency registers in Hz. The set frequency, the
+// status values StatusSetFrequency and StatusOutputFrequency and frequency parameters like
+// PD005 are transferred in 0.01 Hz. All frequencies named "frequency" in this package are
+// register values in this unit, Hz values are named "hertz".
+const FrequencyResolution = 0.01
+
+// HertzToFrequency converts Hz into a frequency register value, rounded to the nearest
+// 0.01 Hz. Results above 655.35 Hz are saturated. Negative and NaN inputs result in 0.
+func HertzToFrequency(hertz float64) (frequency uint16, saturated bool) {
+	return saturateFrequency(hertz / FrequencyResolution)
+}
+
+// FrequencyToHertz converts a frequency register value into Hz.
+func FrequencyToHertz(frequency uint16) float64 {
+	return float64(frequency) * FrequencyResolution
+}
+
+// rpmToHertz returns the output frequency in Hz for rpm. The conversion factor passed to Open
+// is given in register steps (0.01 Hz) per RPM.
+func (o *HyInverter) rpmToHertz(rpm float64) float64 {
+	return rpm * o.frequencyPerRpm * FrequencyResolution
+}
+
+// rpmToFrequency converts RPM into the frequency register value, rounded to the nearest step.
+// Results above the register maximum are saturated. Negative, NaN and infinite inputs as well
+// as an invalid conversion factor result in 0.
+func (o *HyInverter) rpmToFrequency(rpm float64) (frequency uint16, saturated bool) {
+	return HertzToFrequency(o.rpmToHertz(rpm))
+}
+
+// requestedFrequency converts RPM into register steps like rpmToFrequency, but without the
+// saturation at the register maximum, for the RequestedFrequency of events.
+func (o *HyInverter) requestedFrequency(rpm float64) uint32 {
+	value := o.rpmToHertz(rpm) / FrequencyResolution
+	if math.IsNaN(value) || value <= 0 {
+		return 0
+	}
+	if value = math.Floor(value + 0.5); value > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(value)
+}
+
+// frequencyToRpm converts a frequency register value into RPM, saturated at 65535.
+func (o *HyInverter) frequencyToRpm(frequency uint16) uint16 {
+	if o.frequencyPerRpm <= 0 {
+		return 0
+	}
+	return saturateRpm(FrequencyToHertz(frequency) / (o.frequencyPerRpm * FrequencyResolution))
+}
+
+func saturateFrequency(value float64) (frequency uint16, saturated bool) {
+	if math.IsNaN(value) || value <= 0 {
+		return 0, false
+	}
+	if value = math.Floor(value + 0.5); value > maxFrequencyRegister {
+		return maxFrequencyRegister, true
+	}
+	return uint16(value), false
+}
+
+func saturateRpm(value float64) uint16 {
+	if math.IsNaN(value) || value <= 0 {
+		return 0
+	}
+	if value = math.Floor(value + 0.5); value > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(value)
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+// Diagnostics is a snapshot of the internal state, e.g. to diagnose a stuck queue remotely.
+type Diagnostics struct {
+	// Version is the library version, see Version.
+	Version string
+	// QueuedCommands and QueuedStatusReads are waiting to be sent.
+	QueuedCommands    int
+	QueuedStatusReads int
+	Online            bool
+	CircuitOpen       bool
+	// LastError is the text of LastError, empty if there is none.
+	LastError string
+	Stats     Stats
+}
+
+// Diagnostics returns a snapshot of the queues, connection state and transaction counters.
+func (o *HyInverter) Diagnostics() Diagnostics {
+	d := Diagnostics{
+		Version:           Version(),
+		QueuedCommands:    len(o.cmdChannel),
+		QueuedStatusReads: len(o.pollChannel),
+		Online:            o.Online(),
+		CircuitOpen:       o.CircuitOpen(),
+		Stats:             o.Stats(),
+	}
+	if err := o.LastError(); err != nil {
+		d.LastError = err.Error()
+	}
+	return d
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import "testing"
+
+func TestDiagnostics(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.GCode("M3 S1000 ?")
+	hy.setOffline(ErrReadTimeout)
+	d := hy.Diagnostics()
+	if d.QueuedCommands != 2 || d.QueuedStatusReads != 1 || d.Online || d.LastError != ErrReadTimeout.Error() {
+		t.Fatalf("unexpected diagnostics %+v", d)
+	}
+}
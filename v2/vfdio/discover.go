@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"github.com/jacobsa/go-serial/serial"
+	"io"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// DiscoveredVfd is a port setting where a Huanyang VFD answered a status read.
+type DiscoveredVfd struct {
+	PortName     string
+	BaudRate     uint
+	SlaveAddress byte
+}
+
+// DiscoverOptions limits the settings tried by Discover. Empty fields use the defaults.
+type DiscoverOptions struct {
+	// PortNames defaults to CandidatePorts().
+	PortNames []string
+	// BaudRates defaults to 9600, 19200, 38400 and 4800 (the PD164 settings).
+	BaudRates []uint
+	// SlaveAddresses defaults to 1.
+	SlaveAddresses []byte
+	// Timeout is the time to wait for an answer per try. Default: 300 ms.
+	Timeout time.Duration
+}
+
+// Discover tries all combinations of serial ports, baud rates and slave addresses and
+// returns those where a VFD answered. The output frequency is read as harmless request.
+// Ports which can not be opened (busy, no permission) are skipped.
+// Example:
+//
+//   found, _ := Discover(DiscoverOptions{})
+//   for _, vfd := range found {
+//       fmt.Println(vfd.PortName, vfd.BaudRate, vfd.SlaveAddress)
+//   }
+//
+func Discover(opts DiscoverOptions) (found []DiscoveredVfd, err error) {
+	if opts.PortNames == nil {
+		opts.PortNames = CandidatePorts()
+	}
+	if opts.BaudRates == nil {
+		opts.BaudRates = []uint{9600, 19200, 38400, 4800}
+	}
+	if opts.SlaveAddresses == nil {
+		opts.SlaveAddresses = []byte{1}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 300 * time.Millisecond
+	}
+	for _, portName := range opts.PortNames {
+		for _, baudRate := range opts.BaudRates {
+			port, openErr := serial.Open(serial.OpenOptions{
+				PortName:              portName,
+				BaudRate:              baudRate,
+				DataBits:              8,
+				StopBits:              1,
+				ParityMode:            serial.PARITY_NONE,
+				InterCharacterTimeout: 100,
+			})
+			if openErr != nil {
+				break
+			}
+			for _, address := range opts.SlaveAddresses {
+				if probe(port, address, opts.Timeout) {
+					found = append(found, DiscoveredVfd{portName, baudRate, address})
+				}
+			}
+			port.Close()
+		}
+	}
+	if len(found) == 0 {
+		err = fmt.Errorf("vfdio: no VFD answered on %d ports", len(opts.PortNames))
+	}
+	return
+}
+
+// CandidatePorts returns the serial ports which are likely USB-RS485 adapters.
+func CandidatePorts() (ports []string) {
+	switch runtime.GOOS {
+	case "windows":
+		for i := 1; i <= 32; i++ {
+			ports = append(ports, fmt.Sprintf("COM%d", i))
+		}
+		return
+	case "darwin":
+		return glob("/dev/cu.usbserial*", "/dev/cu.usbmodem*", "/dev/cu.wchusbserial*")
+	}
+	return glob("/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyAMA*")
+}
+
+func glob(patterns ...string) (matches []string) {
+	for _, pattern := range patterns {
+		m, _ := filepath.Glob(pattern)
+		matches = append(matches, m...)
+	}
+	return
+}
+
+// probe sends a status read and checks if a valid answer arrives within the timeout.
+func probe(port io.ReadWriter, address byte, timeout time.Duration) bool {
+	vfd := &HyInverter{}
+	request := vfd.signMessage([]byte{address, byte(FunctionReadStatus), ReadStatusDataLength, byte(StatusOutputFrequency), 0x00, 0x00})
+	if _, err := port.Write(request); err != nil {
+		return false
+	}
+	answer := make([]byte, 0, 8)
+	rxBuf := make([]byte, 8)
+	deadline := time.Now().Add(timeout)
+	for len(answer) < 8 && time.Now().Before(deadline) {
+		n, err := port.Read(rxBuf[:8-len(answer)])
+		if err != nil && err != io.EOF {
+			return false
+		}
+		answer = append(answer, rxBuf[:n]...)
+		if n == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if len(answer) != 8 || answer[0] != address || Function(answer[1]) != FunctionReadStatus || answer[2] != ReadStatusDataLength {
+		return false
+	}
+	signTest := vfd.signMessage(answer[:6])
+	return signTest[6] == answer[6] && signTest[7] == answer[7]
+}
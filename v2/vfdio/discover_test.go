@@ -0,0 +1,29 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	hy, port := newTestInverter()
+	port.Reply(hy.signMessage([]byte{0x02, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	if !probe(port, 2, 50*time.Millisecond) {
+		t.Fatal("valid answer not detected")
+	}
+	request := port.Bytes()
+	if len(request) != 8 || request[0] != 0x02 || request[1] != 0x04 {
+		t.Fatalf("unexpected request % X", request)
+	}
+	port.Reply([]byte{0x02, 0x04, 0x03, 0x01, 0x00, 0x00, 0x00, 0x00})
+	if probe(port, 2, 50*time.Millisecond) {
+		t.Fatal("answer with bad CRC accepted")
+	}
+	if probe(port, 2, 50*time.Millisecond) {
+		t.Fatal("silence accepted")
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package vfdio contains the Huanyango library. It can control a Huanyang VFD via RS485.
+//
+// Concurrency: the methods of HyInverter may be called from several goroutines once it was
+// opened, e.g. GCode from a job runner while a UI polls OutputRpm. The values received from
+// the VFD are updated by the library's goroutines and read under a lock, so each call returns
+// a consistent value, but two calls may see different polling cycles. SetBaudRate,
+// SetReadOptions and the conversion factor only take effect on Open and should be set before.
+// Event handlers are called from the library's goroutines, see Subscribe.
+package vfdio
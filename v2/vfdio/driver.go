@@ -0,0 +1,272 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Driver controls a VFD. The processor of an opened HyInverter sends the Huanyang frames
+// through a built-in driver, and HyInverter.Driver returns one using the command queue. Drivers
+// of other VFDs, e.g. Fuling, Yalang or Delta, are passed to OpenDriver to use
+// the G-code front end, queue and poller of HyInverter. The methods of a driver are called
+// from a single goroutine.
+type Driver interface {
+	// Start runs the spindle in the given direction.
+	Start(direction Direction) error
+	// Stop stops the spindle.
+	Stop() error
+	// SetFrequency sets the output frequency in 0.01 Hz.
+	SetFrequency(frequency uint16) error
+	// Status reads the state of the drive.
+	Status() (DriverStatus, error)
+	// Fault reads the fault code of the drive, 0 if there is none. Drivers which can't read
+	// it return ErrUnsupported.
+	Fault() (code uint16, err error)
+}
+
+// DriverStatus is the state of a drive returned by Driver.Status.
+type DriverStatus struct {
+	// SetFrequency and OutputFrequency are in 0.01 Hz.
+	SetFrequency    uint16
+	OutputFrequency uint16
+	Running         bool
+	// Reverse is true for backward (M4) rotation.
+	Reverse bool
+}
+
+var _ Driver = queueDriver{}
+
+// connectionDriver is the driver of an open connection which the processor and the poller
+// call: huanyangDriver after Open and externalDriver after OpenDriver.
+type connectionDriver interface {
+	Driver
+	// jog runs the spindle in the direction until the next control command.
+	jog(direction Direction) error
+	// pollValues returns the status values requested in every poll interval.
+	pollValues() []StatusValue
+	// read reads a status value returned by pollValues.
+	read(value StatusValue)
+	// writeFrame sends a frame of the Huanyang protocol.
+	writeFrame(frame []byte) error
+	// close ends the connection when the HyInverter is closed.
+	close() error
+}
+
+// currentDriver returns the driver of the connection, huanyangDriver unless OpenDriver
+// was called.
+func (o *HyInverter) currentDriver() connectionDriver {
+	if o.driver == nil {
+		return huanyangDriver{o}
+	}
+	return o.driver
+}
+
+// pollDriver is queued like a status value and reads the status of the driver passed to
+// OpenDriver.
+const pollDriver StatusValue = statusValueCount + 2
+
+// Start queues M3 for Forward or M4 for Backward without parsing G-code. It returns the
+// errors of Enqueue, e.g. ErrReverseLocked.
+func (o *HyInverter) Start(direction Direction) error {
+	if direction == Backward {
+		return o.enqueueWords(lineWord{text: "M4"})
+	}
+	return o.enqueueWords(lineWord{text: "M3"})
+}
+
+// Stop queues M5 without parsing G-code. It returns the errors of Enqueue.
+func (o *HyInverter) Stop() error {
+	return o.enqueueWords(lineWord{text: "M5"})
+}
+
+// Driver returns a Driver which controls the VFD through the command queue, e.g. for code
+// written against the Driver interface. Its commands return the errors of Enqueue, Status
+// and Fault return the last polled values.
+func (o *HyInverter) Driver() Driver {
+	return queueDriver{o}
+}
+
+// queueDriver is the Driver returned by HyInverter.Driver.
+type queueDriver struct {
+	o *HyInverter
+}
+
+// Start queues M3 or M4, see HyInverter.Start.
+func (d queueDriver) Start(direction Direction) error {
+	return d.o.Start(direction)
+}
+
+// Stop queues M5, see HyInverter.Stop.
+func (d queueDriver) Stop() error {
+	return d.o.Stop()
+}
+
+// SetFrequency queues the frequency without converting it to RPM, see SetFrequencyHz.
+func (d queueDriver) SetFrequency(frequency uint16) error {
+	return d.o.enqueueFrequency(frequency)
+}
+
+// Status returns the last polled state, see driverStatus.
+func (d queueDriver) Status() (DriverStatus, error) {
+	return d.o.driverStatus()
+}
+
+// Fault returns the last fault code, see driverFault.
+func (d queueDriver) Fault() (uint16, error) {
+	return d.o.driverFault()
+}
+
+// driverStatus returns the last polled state. It returns an error wrapping ErrOffline and
+// LastError if the VFD is offline. Running and Reverse require SetRunStatePolling.
+func (o *HyInverter) driverStatus() (DriverStatus, error) {
+	status := DriverStatus{
+		SetFrequency:    o.RawStatus(StatusSetFrequency),
+		OutputFrequency: o.RawStatus(StatusOutputFrequency),
+		Running:         o.Running(),
+		Reverse:         !o.Direction(),
+	}
+	if err := o.LastError(); err != nil {
+		if errors.Is(err, ErrOffline) {
+			return status, err
+		}
+		return status, fmt.Errorf("%w: %w", ErrOffline, err)
+	}
+	return status, nil
+}
+
+// driverFault returns the last fault code, see SetFaultParameter. It returns ErrUnsupported
+// if no fault parameter is set.
+func (o *HyInverter) driverFault() (uint16, error) {
+	if o.FaultParameter() == 0 {
+		return 0, fmt.Errorf("%w: fault parameter not set", ErrUnsupported)
+	}
+	code, _ := o.FaultCode()
+	return code, nil
+}
+
+// OpenDriver works like Open, but controls the VFD with the driver instead of the Huanyang
+// protocol. WithRpmToHertz has to be given. The driver is polled for its status and fault
+// code in every interval. Frames of the Huanyang protocol, e.g. parameter reads, fail with
+// ErrUnsupported. Errors of the driver are reported by LastError and Offline events. Close
+// does not close the driver.
+func (o *HyInverter) OpenDriver(driver Driver, opts ...Option) error {
+	settings := o.settings(opts)
+	if settings.rpmToHertz <= 0 {
+		return errors.New("vfdio: OpenDriver requires the RPM conversion factor")
+	}
+	o.connectMutex.Lock()
+	defer o.connectMutex.Unlock()
+	o.lifecycleMutex.Lock()
+	if o.lifecycle == opened {
+		o.lifecycleMutex.Unlock()
+		return ErrAlreadyOpen
+	}
+	settings.apply(o)
+	o.reset()
+	o.driver = externalDriver{driver, o}
+	o.prepareConnection(settings)
+	o.startProcessing(settings)
+	o.lifecycle = opened
+	o.lifecycleMutex.Unlock()
+	o.opened(settings)
+	return nil
+}
+
+// externalDriver adapts the driver passed to OpenDriver. Its errors are reported by
+// LastError and Offline events.
+type externalDriver struct {
+	Driver
+	o *HyInverter
+}
+
+// Start starts the driver.
+func (d externalDriver) Start(direction Direction) error {
+	err := d.Driver.Start(direction)
+	if err != nil {
+		d.o.setOffline(err)
+	}
+	return err
+}
+
+// Stop stops the driver. An error is reported with a StopFailed event and the fallback of
+// SetStopEscalation is called.
+func (d externalDriver) Stop() error {
+	err := d.Driver.Stop()
+	if err != nil {
+		d.o.emit(Event{Type: StopFailed, Err: err})
+		if _, fallback := d.o.stopEscalation(); fallback != nil {
+			fallback()
+		}
+	}
+	return err
+}
+
+// SetFrequency sets the frequency of the driver.
+func (d externalDriver) SetFrequency(frequency uint16) error {
+	err := d.Driver.SetFrequency(frequency)
+	if err != nil {
+		d.o.setOffline(err)
+	}
+	return err
+}
+
+// jog starts the driver, a driver has no jog mode.
+func (d externalDriver) jog(direction Direction) error {
+	return d.Start(direction)
+}
+
+// pollValues returns pollDriver, the driver is polled for any value.
+func (d externalDriver) pollValues() []StatusValue {
+	return []StatusValue{pollDriver}
+}
+
+// read polls the status and fault code of the driver.
+func (d externalDriver) read(StatusValue) {
+	o := d.o
+	status, err := d.Driver.Status()
+	if err != nil {
+		o.setOffline(err)
+		return
+	}
+	o.pollMutex.Lock()
+	o.status[StatusSetFrequency] = status.SetFrequency
+	o.status[StatusOutputFrequency] = status.OutputFrequency
+	o.statusReceived[StatusSetFrequency]++
+	o.statusReceived[StatusOutputFrequency]++
+	o.outputFrequency = status.OutputFrequency
+	o.outputRpm = o.frequencyToRpm(status.OutputFrequency)
+	o.pollMutex.Unlock()
+	o.measureRampLatency(status.OutputFrequency)
+	o.checkSpeedReached(status.OutputFrequency)
+	o.countUsage(status.OutputFrequency, o.now())
+	var control ControlStatus
+	if status.Running {
+		control = modbusRunState(ModbusStateForward)
+		if status.Reverse {
+			control = modbusRunState(ModbusStateReverse)
+		}
+	}
+	o.controlAnswered(control)
+	if code, err := d.Driver.Fault(); err == nil {
+		o.setFaultCode(code)
+	}
+	received := o.now()
+	o.pollMutex.Lock()
+	o.lastReceived = received
+	o.pollMutex.Unlock()
+	o.setOnline()
+}
+
+// writeFrame returns ErrUnsupported, the driver doesn't speak the Huanyang protocol.
+func (d externalDriver) writeFrame([]byte) error {
+	return fmt.Errorf("%w: Huanyang frames with a driver", ErrUnsupported)
+}
+
+// close does nothing, Close does not close the driver.
+func (d externalDriver) close() error {
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver records the calls of the processor.
+type fakeDriver struct {
+	mutex  sync.Mutex
+	calls  []string
+	status DriverStatus
+	fault  uint16
+}
+
+func (d *fakeDriver) record(call string) error {
+	d.mutex.Lock()
+	d.calls = append(d.calls, call)
+	d.mutex.Unlock()
+	return nil
+}
+
+func (d *fakeDriver) Start(direction Direction) error {
+	return d.record(fmt.Sprint("start ", direction))
+}
+
+func (d *fakeDriver) Stop() error {
+	return d.record("stop")
+}
+
+func (d *fakeDriver) SetFrequency(frequency uint16) error {
+	return d.record(fmt.Sprint("frequency ", frequency))
+}
+
+func (d *fakeDriver) Status() (DriverStatus, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status, nil
+}
+
+func (d *fakeDriver) Fault() (uint16, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.fault, nil
+}
+
+func (d *fakeDriver) Calls() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+func TestOpenDriver(t *testing.T) {
+	driver := &fakeDriver{status: DriverStatus{SetFrequency: 10000, OutputFrequency: 10000, Running: true, Reverse: true}, fault: 3}
+	hy := NewVfd()
+	hy.SetStallDetection(0, false)
+	var faults []uint16
+	hy.Subscribe(func(e Event) {
+		if e.Type == Fault {
+			faults = append(faults, e.FaultCode)
+		}
+	})
+	if err := hy.OpenDriver(driver, WithMaxRpm(24000), WithPollInterval(10*time.Millisecond)); err == nil {
+		t.Fatal("opened without conversion factor")
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	if err := hy.OpenDriver(driver, WithMaxRpm(24000), WithRpmToHertz(10000.0/6000), WithPollInterval(10*time.Millisecond), WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if !strings.Contains(logs.String(), `msg="vfdio: opened"`) {
+		t.Errorf("opening not logged: %s", logs.String())
+	}
+	hy.GCode("S6000 M4")
+	hy.GCode("M5")
+	time.Sleep(100 * time.Millisecond)
+	expected := []string{"frequency 10000", "start 1", "stop"}
+	if calls := driver.Calls(); fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if hy.OutputRpm() != 6000 || !hy.Running() || hy.Direction() || !hy.Online() {
+		t.Fatalf("status not polled: %d RPM, running %v", hy.OutputRpm(), hy.Running())
+	}
+	if len(faults) != 1 || faults[0] != 3 {
+		t.Fatalf("fault not reported: %v", faults)
+	}
+	if _, err := hy.readParameter(ParameterMaxFrequency); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestHyInverterDriver(t *testing.T) {
+	hy, _ := newTestInverter()
+	driver := hy.Driver()
+	driver.Start(Backward)
+	driver.SetFrequency(20001)
+	driver.Stop()
+	for _, expected := range []string{"M4", hertzWordPrefix + "200.01", "M5"} {
+		if word := (<-hy.cmdChannel).word; word != expected {
+			t.Fatalf("expected %q, got %q", expected, word)
+		}
+	}
+	if _, err := driver.Fault(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	hy.setOffline(errUnplugged)
+	if _, err := driver.Status(); !errors.Is(err, ErrOffline) || !errors.Is(err, errUnplugged) {
+		t.Fatalf("expected ErrOffline wrapping the port error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidDwell is returned for a G4 word without a P or S word with a time in seconds.
+var ErrInvalidDwell = errors.New("vfdio: invalid dwell time")
+
+// dwellWordPrefix starts the internal command words of G4, followed by the time in seconds.
+const dwellWordPrefix = "dwell"
+
+// holdCheckInterval is the longest time the processor waits before checking if a dwell or
+// a pause was ended by Resume, EStop or Close.
+const holdCheckInterval = 100 * time.Millisecond
+
+// mergeDwell joins G4 and the following P or S word into a dwell word, so the S word is not
+// taken as speed. Both give the time in seconds like in LinuxCNC and GRBL, e.g. "G4 P2.5". A
+// G4 without them is kept as an invalid dwell word. The dwell words are internal.
+func mergeDwell(words []lineWord) []lineWord {
+	merged := words[:0]
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i].text)
+		if word != "g4" && word != "g04" {
+			merged = append(merged, words[i])
+			continue
+		}
+		if i+1 < len(words) && strings.ContainsAny(words[i+1].text[:1], "pPsS") {
+			i++
+			merged = append(merged, lineWord{text: dwellWordPrefix + words[i].text[1:], internal: true})
+			continue
+		}
+		merged = append(merged, lineWord{text: dwellWordPrefix, internal: true})
+	}
+	return merged
+}
+
+// isDwell returns true for the words created by mergeDwell.
+func isDwell(word lineWord) bool {
+	return word.internal && strings.HasPrefix(word.text, dwellWordPrefix)
+}
+
+// parseDwell returns the time of a dwell word.
+func parseDwell(word string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(word[len(dwellWordPrefix):], 64)
+	if err != nil || math.IsNaN(seconds) || seconds < 0 || seconds*float64(time.Second) > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: G4 %q", ErrInvalidDwell, word[len(dwellWordPrefix):])
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// checkDwellWords returns ErrInvalidDwell if a dwell word of a line is invalid.
+func checkDwellWords(words []lineWord) error {
+	for _, word := range words {
+		if !isDwell(word) {
+			continue
+		}
+		if _, err := parseDwell(word.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startDwell holds back the following commands for the time of a dwell word. The status
+// polls continue meanwhile. The result of the word is sent when the time elapsed.
+func (o *HyInverter) startDwell(command queuedCommand) {
+	o.markProgress()
+	atomic.AddInt32(&o.commandQueue, -1)
+	d, err := parseDwell(command.word)
+	if err != nil || d == 0 {
+		o.commandIDs.finish(command.id)
+		command.results.send(CommandResult{ID: command.id, Command: command.word, Err: err})
+		return
+	}
+	o.stateMutex.Lock()
+	o.dwellCommand = &command
+	o.dwellUntil = o.clockLocked().Now().Add(d)
+	o.stateMutex.Unlock()
+}
+
+// continueHold processes a status poll while the queue is held back by a dwell or Pause.
+// During Pause, it takes a command from the queue, see holdCommand. It ends the dwell if its
+// time elapsed.
+func (o *HyInverter) continueHold() {
+	o.stateMutex.Lock()
+	dwelling, paused := o.dwellCommand != nil, o.paused
+	remaining := o.dwellUntil.Sub(o.clockLocked().Now())
+	o.stateMutex.Unlock()
+	if dwelling && remaining <= 0 {
+		o.endDwell(nil)
+		return
+	}
+	wait := holdCheckInterval
+	if dwelling && !paused {
+		wait = min(remaining, wait)
+	}
+	// Stop words pass a pause, see holdCommand.
+	var commands chan queuedCommand
+	if paused {
+		commands = o.cmdChannel
+	}
+	timer := o.currentClock().NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case command := <-commands:
+		o.holdBack(command)
+	case value := <-o.pollChannel:
+		o.readStatus(value)
+	case <-timer.C():
+	case <-o.done:
+		o.endDwell(ErrClosed)
+	}
+}
+
+// dwelling returns true while a dwell holds back the queue.
+func (o *HyInverter) dwelling() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.dwellCommand != nil
+}
+
+// holding returns true while a dwell or Pause holds back the queue.
+func (o *HyInverter) holding() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.dwellCommand != nil || o.paused
+}
+
+// endDwell sends the result of the current dwell word, the following commands are processed
+// again.
+func (o *HyInverter) endDwell(err error) {
+	o.stateMutex.Lock()
+	command := o.dwellCommand
+	o.dwellCommand = nil
+	o.stateMutex.Unlock()
+	if command == nil {
+		return
+	}
+	o.commandIDs.finish(command.id)
+	command.results.send(CommandResult{ID: command.id, Command: command.word, Err: err})
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitDwell(t *testing.T) {
+	tests := map[string][]string{
+		"M3 S12000 G4 P2.5 S6000": {"M3", "S12000", "dwell2.5", "S6000"},
+		"G4S1M5":                  {"dwell1", "M5"},
+		"g04 p0.2":                {"dwell0.2"},
+		"G4 M5":                   {"dwell", "M5"},
+	}
+	for line, expected := range tests {
+		if words := wordTexts(splitGCode(line)); !reflect.DeepEqual(words, expected) {
+			t.Errorf("%s: expected %q, got %q", line, expected, words)
+		}
+	}
+	for _, line := range []string{"G4", "G4 P-1", "G4 M3"} {
+		if err := checkDwellWords(splitGCode(line)); !errors.Is(err, ErrInvalidDwell) {
+			t.Errorf("%s: expected ErrInvalidDwell, got %v", line, err)
+		}
+	}
+}
+
+func TestDwell(t *testing.T) {
+	hy, port := newTestInverter()
+	results, err := hy.EnqueueResults("G4 P0.3 M3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	hy.processNext()
+	if !hy.dwelling() || hy.QueueDepth() != 1 {
+		t.Fatalf("dwell not started, queue depth %d", hy.QueueDepth())
+	}
+	// Status polls continue during the dwell.
+	hy.requestStatus(StatusOutputFrequency)
+	hy.processNext()
+	if frames := port.Bytes(); len(frames) != 8 || frames[1] != byte(FunctionReadStatus) {
+		t.Fatalf("expected a status read, got % X", frames)
+	}
+	for hy.QueueDepth() > 0 && time.Since(start) < 2*time.Second {
+		hy.processNext()
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("M3 sent after %v", elapsed)
+	}
+	for i := 0; i < 2; i++ {
+		if result := <-results; result.Err != nil {
+			t.Errorf("%s failed: %v", result.Command, result.Err)
+		}
+	}
+	if _, ok := <-results; ok {
+		t.Error("results not closed")
+	}
+
+	hy.GCode("G4 P10")
+	hy.processNext()
+	hy.EStop()
+	if hy.dwelling() {
+		t.Error("dwell not ended by EStop")
+	}
+}
+
+func TestDwellClock(t *testing.T) {
+	hy, port := newTestInverter()
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	hy.SetClock(clock)
+	hy.GCode("G4 P10 M3")
+	hy.processNext()
+	// The dwell is timed by the clock, not in real time.
+	for i := 0; i < 3; i++ {
+		hy.processNext()
+	}
+	if !hy.dwelling() || len(port.Bytes()) != 0 {
+		t.Fatalf("dwell ended before the clock advanced: % X", port.Bytes())
+	}
+	clock.Set(start.Add(10 * time.Second))
+	hy.processNext()
+	if hy.dwelling() {
+		t.Fatal("dwell not ended after the clock advanced")
+	}
+	hy.processNext()
+	if !bytes.Equal(port.Bytes(), hy.controlFrame(CommandRunForward)) {
+		t.Fatalf("expected M3 after the dwell, sent % X", port.Bytes())
+	}
+}
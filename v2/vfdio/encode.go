@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// splitGCode separates the words of a G-Code line, see scanGCode, e.g. "N12S20 F200M3" into
+// N12, S20, F200 and M3. Malformed input is kept as a word and rejected or ignored later.
+// G4 and its time are joined to a single word, see mergeDwell.
+func splitGCode(cmd string) []lineWord {
+	var words []lineWord
+	for _, token := range scanGCode(cmd) {
+		words = append(words, lineWord{text: token.text})
+	}
+	return mergeDwell(words)
+}
+
+// controlCommand returns the control command of a normalized M word.
+func controlCommand(word string) (command ControlCommand, ok bool) {
+	switch word {
+	case "end", "m0", "m1", "m30", "m60", "m5", "m05":
+		return CommandStop, true
+	case "m3", "m03":
+		return CommandRunForward, true
+	case "m4", "m04":
+		return CommandRunBackward, true
+	}
+	return 0, false
+}
+
+// jogCommand returns the control command of an internal jog word, see Jog. Jog words of the
+// caller are not supported.
+func jogCommand(word lineWord) (command ControlCommand, ok bool) {
+	if !word.internal {
+		return 0, false
+	}
+	switch word.text {
+	case jogForwardWord:
+		return ControlJogForward, true
+	case jogBackwardWord:
+		return ControlJogReverse, true
+	}
+	return 0, false
+}
+
+// controlFrame returns the frame of a control command. The directions are swapped if
+// SetInvertDirection is enabled.
+func (o *HyInverter) controlFrame(command ControlCommand) []byte {
+	return o.wireControlFrame(o.wiredCommand(command))
+}
+
+// wireControlFrame returns the frame of a control command as it is sent, without swapping
+// the directions.
+func (o *HyInverter) wireControlFrame(command ControlCommand) []byte {
+	return o.signMessage([]byte{o.SlaveAddress(), byte(FunctionControl), ControlDataLength, byte(command)})
+}
+
+func (o *HyInverter) frequencyFrame(frequency uint16) []byte {
+	fBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(fBytes, frequency)
+	return o.signMessage([]byte{o.SlaveAddress(), byte(FunctionSetFrequency), SetFrequencyDataLength, fBytes[0], fBytes[1]})
+}
+
+func (o *HyInverter) statusFrame(value StatusValue) []byte {
+	return o.signMessage([]byte{o.SlaveAddress(), byte(FunctionReadStatus), ReadStatusDataLength, byte(value), 0x00, 0x00})
+}
+
+// EncodeCommand returns the frames GCode would send for a line, without sending them.
+// Words without a VFD function, e.g. G0 or F200, produce no frame, or a WordError if
+// SetStrictWords is enabled. Speeds are converted with
+// the factor passed to Open. A stop frame is listed once, even if it has to be repeated.
+// The frames use the register map set by SetRegisterMap.
+// Use it to verify command generators without a VFD.
+func (o *HyInverter) EncodeCommand(cmd string) ([][]byte, error) {
+	if ignored := ignoredWords(cmd); len(ignored) > 0 && o.StrictWords() {
+		return nil, ignored[0]
+	}
+	var frames [][]byte
+	for _, w := range o.lineWords(cmd) {
+		word := w.text
+		if word == "?" {
+			for _, value := range o.PollValues() {
+				frames = append(frames, o.statusFrame(value))
+			}
+			continue
+		}
+		word = strings.ToLower(word)
+		if command, ok := controlCommand(word); ok {
+			if err := o.checkReverse(word); err != nil {
+				return nil, err
+			}
+			frames = append(frames, o.controlFrame(command))
+		} else if strings.HasPrefix(word, "s") {
+			rpm, err := parseSpeed(word)
+			if err != nil {
+				return nil, err
+			}
+			if err := o.checkMinRpm(rpm); err != nil {
+				return nil, err
+			}
+			if err := o.checkMaxRpm(rpm); err != nil {
+				return nil, err
+			}
+			rpm, _ = o.raiseToMinRpm(rpm)
+			rpm, _ = o.lowerToMaxRpm(rpm)
+			frequency, _ := o.rpmToFrequency(rpm)
+			frames = append(frames, o.frequencyFrame(frequency))
+		}
+	}
+	for i, frame := range frames {
+		wire, err := o.toWire(frame)
+		if err != nil {
+			return nil, err
+		}
+		frames[i] = wire
+	}
+	return frames, nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	hy, port := newTestInverter()
+	frames, err := hy.EncodeCommand("G0 M3S11520 F200 ? M05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{
+		hy.signMessage([]byte{0x01, 0x03, 0x01, 0x01}),
+		hy.signMessage([]byte{0x01, 0x05, 0x02, 0x9C, 0x40}),
+		hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}),
+		hy.signMessage([]byte{0x01, 0x03, 0x01, 0x08}),
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("unexpected frames % X", frames)
+	}
+	for i := range expected {
+		if !bytes.Equal(frames[i], expected[i]) {
+			t.Errorf("frame %d: expected % X, got % X", i, expected[i], frames[i])
+		}
+	}
+	// The frames equal the ones sent by GCode.
+	hy.GCode("M3S11520")
+	hy.processNext()
+	hy.processNext()
+	if !bytes.Equal(port.Bytes(), append(append([]byte(nil), expected[0]...), expected[1]...)) {
+		t.Errorf("GCode sent % X", port.Bytes())
+	}
+	if _, err := hy.EncodeCommand("M3 S-100"); err == nil {
+		t.Error("expected an error for an invalid speed")
+	}
+}
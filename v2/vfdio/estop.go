@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrEStopped is returned for commands other than stops between EStop and ClearEStop.
+var ErrEStopped = errors.New("vfdio: emergency stop active")
+
+// EStop stops the spindle at once. The stop frame is written ahead of the queued commands,
+// even while another request waits for its answer, and it is repeated as configured by
+// SetStopEscalation. Pending S and M commands are discarded, a running ramp or jog ends.
+// Until ClearEStop is called, all commands except stops (M5 and its aliases) and status
+// requests are rejected with ErrEStopped, even after Close and Open. EStop returns when the
+// stop was delivered or escalated. Returns the error of the stop, e.g. ErrPortClosed or
+// ErrStopNotAcknowledged, ErrNotOpen or ErrClosed if the connection is not open, or
+// ErrReadOnly. The latch is set in any case.
+func (o *HyInverter) EStop() error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	if err := o.checkWritable(); err != nil {
+		return err
+	}
+	o.stateMutex.Lock()
+	o.estopped = true
+	if o.jogTimer != nil {
+		o.jogTimer.Stop()
+		o.jogTimer = nil
+	}
+	o.stateMutex.Unlock()
+	o.flushCommands(ErrEStopped)
+	err := o.sendStop()
+	// A command which was sent concurrently may have started the spindle again.
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	stop := o.controlFrame(CommandStop)
+	o.stateMutex.Lock()
+	resend := !bytes.Equal(o.lastControlFrame, stop)
+	o.stateMutex.Unlock()
+	if resend {
+		if resendErr := o.sendStop(); err == nil {
+			err = resendErr
+		}
+	}
+	return err
+}
+
+// ClearEStop accepts commands again after EStop. The spindle is not started again.
+func (o *HyInverter) ClearEStop() {
+	o.stateMutex.Lock()
+	o.estopped = false
+	o.stateMutex.Unlock()
+}
+
+// EStopped returns true between EStop and ClearEStop.
+func (o *HyInverter) EStopped() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.estopped
+}
+
+// flushCommands discards the queued commands and the commands held back by Pause or the
+// circuit breaker, and ends a dwell.
+func (o *HyInverter) flushCommands(reason error) {
+	o.endDwell(reason)
+	o.stateMutex.Lock()
+	held := o.heldCommands
+	o.heldCommands = nil
+	o.stateMutex.Unlock()
+	for _, command := range held {
+		o.discardCommand(command, reason)
+	}
+	for {
+		select {
+		case command := <-o.cmdChannel:
+			o.discardCommand(command, reason)
+		default:
+			return
+		}
+	}
+}
+
+// discardCommand reports reason as result of a queued command which is not executed.
+func (o *HyInverter) discardCommand(command queuedCommand, reason error) {
+	o.freeSpace()
+	atomic.AddInt32(&o.commandQueue, -1)
+	if preempts(lineWord{command.word, command.internal}) {
+		atomic.AddInt32(&o.preemptions, -1)
+	}
+	o.commandIDs.finish(command.id)
+	command.results.send(CommandResult{ID: command.id, Command: command.word, Err: reason})
+}
+
+// checkEStop returns ErrEStopped for a command word other than a stop or status request
+// while the emergency stop is active.
+func (o *HyInverter) checkEStop(word string) error {
+	if word == "?" || !o.EStopped() {
+		return nil
+	}
+	if command, ok := controlCommand(strings.ToLower(word)); ok && command == CommandStop {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrEStopped, word)
+}
+
+// checkEStopWords checks the words of a line, see checkEStop.
+func (o *HyInverter) checkEStopWords(words []string) error {
+	for _, word := range words {
+		if err := o.checkEStop(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEStop(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetStopEscalation(0, nil)
+	hy.GCode("S3000 M3")
+	if err := hy.EStop(); err != nil {
+		t.Fatal(err)
+	}
+	if frames := port.Bytes(); len(frames) != 6 || frames[3] != byte(CommandStop) {
+		t.Fatalf("stop frame not sent: % X", frames)
+	}
+	if len(hy.cmdChannel) != 0 || hy.commandQueue != 0 {
+		t.Fatalf("queue not flushed: %d commands", len(hy.cmdChannel))
+	}
+	if err := hy.Enqueue("M3"); !errors.Is(err, ErrEStopped) {
+		t.Fatalf("expected ErrEStopped, got %v", err)
+	}
+	if err := hy.Enqueue("M5 ?"); err != nil {
+		t.Fatalf("stop rejected: %v", err)
+	}
+	hy.ClearEStop()
+	if hy.EStopped() || !hy.GCode("M3") {
+		t.Fatal("commands rejected after ClearEStop")
+	}
+}
+
+func TestEStopDropsQueuedCommands(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetStopEscalation(0, nil)
+	hy.EStop()
+	// Queued concurrently, after the queue was flushed.
+	hy.queue(lineWord{text: "M3"}, nil)
+	sent := len(port.Bytes())
+	hy.processNext()
+	if len(port.Bytes()) != sent {
+		t.Fatalf("command sent during emergency stop: % X", port.Bytes()[sent:])
+	}
+}
+
+func TestEStopFails(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.port = unpluggedPort{}
+	hy.SetStopEscalation(0, nil)
+	if err := hy.EStop(); !errors.Is(err, ErrPortClosed) || !errors.Is(err, errUnplugged) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if !hy.EStopped() {
+		t.Error("EStop not latched")
+	}
+	hy.SetStopEscalation(50*time.Millisecond, nil)
+	if err := hy.EStop(); !errors.Is(err, ErrStopNotAcknowledged) {
+		t.Fatalf("expected ErrStopNotAcknowledged, got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+const (
+	// ExternalChange is raised if the set frequency reported by the VFD differs from the
+	// commanded one, e.g. because an operator used the front panel. Requires
+	// StatusSetFrequency in SetPollValues.
+	ExternalChange EventType = iota
+	// Offline is raised if the VFD stopped answering or the serial port failed, see Event.Err.
+	Offline
+	// Online is raised when a valid message is received again after Offline.
+	Online
+	// Clamped is raised if the VFD applied a different frequency than requested by an
+	// S command, usually because of its PD005 maximum or PD011 minimum frequency.
+	// Detected by the echo of the command or by a StatusSetFrequency readback. It is raised
+	// as well if the library raised the speed to the minimum set by SetMinRpm or lowered it
+	// to MaxRpm.
+	Clamped
+	// Disconnected is raised if the serial port failed repeatedly, see Event.Err. The library
+	// closes it and tries to open it again.
+	Disconnected
+	// Reconnected is raised after the serial port was opened again and the last set frequency
+	// and run state were restored.
+	Reconnected
+	// Saturated is raised if an S command exceeds the frequency register. The register maximum
+	// is sent instead. RequestedFrequency holds the frequency of the S command, RequestedRpm
+	// is saturated at 65535.
+	Saturated
+	// CircuitOpen is raised if the VFD did not answer several requests in a row. Requests are
+	// held back and the VFD is probed at a slow rate, see SetCircuitBreaker.
+	CircuitOpen
+	// CircuitClosed is raised when the VFD answers a probe again after CircuitOpen.
+	CircuitClosed
+	// HighTemperature is raised once if the drive temperature exceeds the limit set by
+	// SetTemperatureLimit. It is raised again after the temperature dropped below the limit.
+	// Requires StatusTemperature in SetPollValues.
+	HighTemperature
+	// Fault is raised when the drive reports a fault code other than 0, see Event.FaultCode.
+	// Feed-hold the machine when it arrives. Requires SetFaultParameter.
+	Fault
+	// FaultCleared is raised when the fault code returned to 0, e.g. after ResetFault.
+	FaultCleared
+	// StopFailed is raised if the VFD did not acknowledge a stop command within the deadline
+	// set by SetStopEscalation. The spindle may still be running, see Event.Err.
+	StopFailed
+	// TimingViolation is raised if a received frame was rejected because it violates the
+	// Modbus RTU timing, see SetStrictTiming and Event.Err.
+	TimingViolation
+	// CommandCompleted is raised after a command was sent to the VFD, see Event.Command.
+	// Requires SetCommandLog.
+	CommandCompleted
+	// QueueStalled is raised if queued commands were not processed within the timeout set
+	// by SetStallDetection, e.g. because a write to the port blocks, see Event.Err.
+	QueueStalled
+	// QueueResumed is raised when the queue is processed again after QueueStalled.
+	QueueResumed
+	// KeepaliveExpired is raised if the application did not call Keepalive in time and the
+	// spindle was stopped, see SetKeepalive.
+	KeepaliveExpired
+	// Panicked is raised if a goroutine of the library panicked, see Event.Err, which is a
+	// *PanicError, and SetRestartPolicy.
+	Panicked
+	// CommandRejected is raised if a queued command could not be sent, e.g. an S word
+	// without a valid speed, see Event.Err. Enqueue returns such errors directly.
+	CommandRejected
+	// Started is raised when the VFD reports that the motor started running, see
+	// Event.Forward. Detected by the answers of M3 and M4 and by SetRunStatePolling.
+	Started
+	// Stopped is raised when the VFD reports that the motor stopped running, e.g. after M5
+	// or a stop at the front panel.
+	Stopped
+	// DirectionChanged is raised when the VFD reports the other direction while the motor
+	// runs, see Event.Forward.
+	DirectionChanged
+	// SpeedReached is raised once after a set frequency or run command when the output
+	// frequency is within DefaultAtSpeedTolerance of the set frequency, see AtSpeed.
+	// Requires StatusOutputFrequency in SetPollValues.
+	SpeedReached
+	// WordIgnored is raised for every word of a queued line which has no spindle function,
+	// e.g. G1, F200 or a typo, see Event.Err, which is a *WordError, and SetStrictWords.
+	WordIgnored
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ExternalChange:
+		return "ExternalChange"
+	case Offline:
+		return "Offline"
+	case Online:
+		return "Online"
+	case Clamped:
+		return "Clamped"
+	case Disconnected:
+		return "Disconnected"
+	case Reconnected:
+		return "Reconnected"
+	case Saturated:
+		return "Saturated"
+	case CircuitOpen:
+		return "CircuitOpen"
+	case CircuitClosed:
+		return "CircuitClosed"
+	case HighTemperature:
+		return "HighTemperature"
+	case Fault:
+		return "Fault"
+	case FaultCleared:
+		return "FaultCleared"
+	case StopFailed:
+		return "StopFailed"
+	case TimingViolation:
+		return "TimingViolation"
+	case CommandCompleted:
+		return "CommandCompleted"
+	case QueueStalled:
+		return "QueueStalled"
+	case QueueResumed:
+		return "QueueResumed"
+	case KeepaliveExpired:
+		return "KeepaliveExpired"
+	case Panicked:
+		return "Panicked"
+	case CommandRejected:
+		return "CommandRejected"
+	case Started:
+		return "Started"
+	case Stopped:
+		return "Stopped"
+	case DirectionChanged:
+		return "DirectionChanged"
+	case SpeedReached:
+		return "SpeedReached"
+	case WordIgnored:
+		return "WordIgnored"
+	}
+	return "Unknown"
+}
+
+// Event is passed to all handlers registered with Subscribe.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Frequency is the raw frequency (0.01 Hz) reported with the event.
+	Frequency uint16
+	// Rpm is Frequency converted to RPM.
+	Rpm uint16
+	// RequestedFrequency and RequestedRpm are the values commanded before a Clamped or
+	// Saturated event. RequestedFrequency is not limited to the register range.
+	RequestedFrequency uint32
+	RequestedRpm       uint16
+	// Temperature is the drive temperature in °C of a HighTemperature event.
+	Temperature float64
+	// FaultCode is the code reported by the drive with a Fault event.
+	FaultCode uint16
+	// Command holds the timestamps of the command of a CommandCompleted event.
+	Command CommandRecord
+	// Forward is the direction of a Started or DirectionChanged event, see Direction.
+	Forward bool
+	// Err is the cause of an Offline, Disconnected, CircuitOpen, StopFailed, TimingViolation,
+	// QueueStalled, Panicked, CommandRejected or WordIgnored event.
+	Err error
+}
+
+// Subscribe registers a handler which is called for every event.
+// Handlers are called from the library's goroutines and must not block.
+func (o *HyInverter) Subscribe(handler func(Event)) {
+	o.eventMutex.Lock()
+	o.eventHandlers = append(o.eventHandlers, handler)
+	o.eventMutex.Unlock()
+}
+
+func (o *HyInverter) emit(event Event) {
+	event.Time = o.now()
+	o.logEvent(event)
+	o.eventMutex.Lock()
+	handlers := o.eventHandlers
+	o.eventMutex.Unlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// checkEcho compares the echo of a set frequency command with the sent frequency.
+func (o *HyInverter) checkEcho(accepted uint16) {
+	o.stateMutex.Lock()
+	o.acceptedFrequency = accepted
+	o.frequencyConfirmed = accepted == o.sentFrequency
+	report := !o.frequencyConfirmed && !o.clampChecked
+	o.clampChecked = true
+	sent := o.sentFrequency
+	o.stateMutex.Unlock()
+	if report {
+		o.emitClamped(sent, accepted)
+	}
+}
+
+// checkSetFrequency compares a set frequency read from the VFD with the commanded one.
+// The first readback after an S command is checked for clamping, later ones for changes
+// made at the front panel.
+func (o *HyInverter) checkSetFrequency(reported uint16) {
+	o.stateMutex.Lock()
+	if !o.frequencyCommanded || atomic.LoadInt32(&o.commandQueue) != 0 {
+		o.stateMutex.Unlock()
+		return
+	}
+	firstReadback := !o.clampChecked
+	o.clampChecked = true
+	sent, accepted, external := o.sentFrequency, o.acceptedFrequency, o.externalFrequency
+	o.externalFrequency = reported
+	o.stateMutex.Unlock()
+	if firstReadback {
+		if reported != sent {
+			o.emitClamped(sent, reported)
+		}
+		return
+	}
+	if reported == sent || reported == accepted || reported == external {
+		return
+	}
+	o.emit(Event{
+		Type:      ExternalChange,
+		Frequency: reported,
+		Rpm:       o.frequencyToRpm(reported),
+	})
+}
+
+// checkTemperature raises HighTemperature when the reported temperature exceeds the limit.
+func (o *HyInverter) checkTemperature(reported float64) {
+	o.stateMutex.Lock()
+	limit := o.temperatureLimit
+	wasHigh := o.temperatureHigh
+	high := limit > 0 && reported > limit
+	o.temperatureHigh = high
+	o.stateMutex.Unlock()
+	if high && !wasHigh {
+		o.emit(Event{Type: HighTemperature, Temperature: reported})
+	}
+}
+
+// checkRunState raises Started, Stopped and DirectionChanged when the control status
+// reported by the VFD changes. known is false before the first answer.
+func (o *HyInverter) checkRunState(previous ControlStatus, known bool, status ControlStatus) {
+	wasRunning := known && previous&ControlStatusRunning != 0
+	running := status&ControlStatusRunning != 0
+	forward := (status&ControlStatusReverse == 0) != o.InvertDirection()
+	switch {
+	case running && !wasRunning:
+		o.emit(Event{Type: Started, Forward: forward})
+	case !running && wasRunning:
+		o.emit(Event{Type: Stopped})
+	case running && (previous^status)&ControlStatusReverse != 0:
+		o.emit(Event{Type: DirectionChanged, Forward: forward})
+	}
+}
+
+// checkSpeedReached raises SpeedReached once the output frequency reached the set frequency
+// after it was commanded.
+func (o *HyInverter) checkSpeedReached(output uint16) {
+	o.pollMutex.Lock()
+	setFrequency := o.setFrequency
+	o.pollMutex.Unlock()
+	if setFrequency == 0 || !o.outputWithin(DefaultAtSpeedTolerance) {
+		return
+	}
+	o.stateMutex.Lock()
+	pending := o.speedPending
+	o.speedPending = false
+	o.stateMutex.Unlock()
+	if pending {
+		o.emit(Event{Type: SpeedReached, Frequency: output, Rpm: o.frequencyToRpm(output)})
+	}
+}
+
+func (o *HyInverter) emitClamped(requested, applied uint16) {
+	o.emit(Event{
+		Type:               Clamped,
+		Frequency:          applied,
+		Rpm:                o.frequencyToRpm(applied),
+		RequestedFrequency: uint32(requested),
+		RequestedRpm:       o.frequencyToRpm(requested),
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ExecuteGCode works like EnqueueContext, but returns only after all words of the line were
+// executed and their frames acknowledged by the VFD, so simple scripts don't have to poll
+// Processed. It returns the first error of a command, see CommandResult, ErrNotAcknowledged
+// if the VFD did not answer a frame of the line, or the context's error if it is done first,
+// e.g. while the circuit breaker holds back the queue. Words which send no frame, e.g. a
+// deferred S word, are done when they were executed. The spindle may still be accelerating,
+// call WaitAtSpeed afterwards to wait for the speed.
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//   defer cancel()
+//   if err := handle.ExecuteGCode(ctx, "M3 S12000"); err != nil {
+//       return err
+//   }
+//   err := handle.WaitAtSpeed(ctx, 2)
+//
+func (o *HyInverter) ExecuteGCode(ctx context.Context, cmd string) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return err
+	}
+	sink := &resultSink{results: make(chan CommandResult, len(words)), pending: 1, acknowledged: true}
+	for _, word := range words {
+		if word.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		atomic.AddInt32(&sink.pending, 1)
+		if err := o.queueContext(ctx, word, sink); err != nil {
+			atomic.AddInt32(&sink.pending, -1)
+			sink.done()
+			return err
+		}
+	}
+	sink.done()
+	return waitResults(ctx, sink.results)
+}
+
+// waitResults returns the first error of the results after the channel was closed.
+func waitResults(ctx context.Context, results <-chan CommandResult) (err error) {
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return err
+			}
+			if err == nil {
+				err = result.Err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestExecuteGCode(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	if err := hy.ExecuteGCode(context.Background(), "M3"); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hy.ExecuteGCode(ctx, "M3 S12000"); err != nil {
+		t.Fatal(err)
+	}
+	if running, _ := vfd.Running(); !running || vfd.SetFrequency() == 0 {
+		t.Errorf("command not executed: running %v, set frequency %d", running, vfd.SetFrequency())
+	}
+	if err := hy.ExecuteGCode(ctx, "Sabc"); !errors.Is(err, ErrInvalidSpeed) {
+		t.Errorf("expected ErrInvalidSpeed, got %v", err)
+	}
+
+	vfd.SetSilent(true)
+	short, cancelShort := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancelShort()
+	if err := hy.ExecuteGCode(short, "S6000"); !errors.Is(err, ErrNotAcknowledged) {
+		t.Errorf("expected ErrNotAcknowledged, got %v", err)
+	}
+}
+
+func TestExecuteGCodeWithoutFrame(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.SetDeferSpeed(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// The deferred S word and G1 send no frame, the other echoes don't count for the line.
+	if err := hy.ExecuteGCode(ctx, "S6000 G1"); err != nil {
+		t.Fatal(err)
+	}
+	if vfd.SetFrequency() != 0 {
+		t.Errorf("deferred speed sent: %d", vfd.SetFrequency())
+	}
+	if err := hy.ExecuteGCode(ctx, "M3"); err != nil {
+		t.Fatal(err)
+	}
+	if running, _ := vfd.Running(); !running || vfd.SetFrequency() == 0 {
+		t.Errorf("deferred speed not sent with M3: running %v, set frequency %d", running, vfd.SetFrequency())
+	}
+}
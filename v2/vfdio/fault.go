@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+// pollFault is queued like a status value and reads the fault parameter.
+const pollFault StatusValue = statusValueCount
+
+// SetFaultParameter enables polling of the drive's fault code. The status values of the
+// Huanyang protocol contain no fault code, so the number of the PDxxx parameter holding it
+// has to be taken from the drive's manual. It is read with FunctionReadParameter in every
+// polling cycle. 0 disables the fault polling. Default: 0.
+func (o *HyInverter) SetFaultParameter(parameter byte) {
+	o.stateMutex.Lock()
+	o.faultParameter = parameter
+	o.stateMutex.Unlock()
+}
+
+// FaultParameter returns the parameter polled for the fault code, 0 if disabled.
+func (o *HyInverter) FaultParameter() byte {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.faultParameter
+}
+
+// FaultCode returns the last fault code read from the drive. Active is true if the code is
+// not 0. Requires SetFaultParameter.
+func (o *HyInverter) FaultCode() (code uint16, active bool) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.faultCode, o.faultCode != 0
+}
+
+// ResetFault clears a trip, e.g. E.OC, after its cause is gone. The Huanyang protocol has no
+// separate reset command, the stop command acts like the STOP/RESET key of the front panel.
+// The fault code is read again right after the stop command.
+// Returns false if the command queue is full.
+func (o *HyInverter) ResetFault() bool {
+	if !o.GCode("M5") {
+		return false
+	}
+	if o.FaultParameter() != 0 {
+		o.requestStatus(pollFault)
+	}
+	return true
+}
+
+// readFault sends the request frame of the fault parameter.
+func (o *HyInverter) readFault() {
+	parameter := o.FaultParameter()
+	if parameter == 0 {
+		return
+	}
+	o.write(o.signMessage([]byte{o.SlaveAddress(), byte(FunctionReadParameter), ReadParameterDataLength, parameter, 0x00, 0x00}))
+}
+
+// checkFault stores a parameter value if it is the fault parameter and raises Fault or
+// FaultCleared if the code changed.
+func (o *HyInverter) checkFault(parameter byte, value uint16) {
+	o.stateMutex.Lock()
+	if parameter == 0 || parameter != o.faultParameter {
+		o.stateMutex.Unlock()
+		return
+	}
+	o.stateMutex.Unlock()
+	o.setFaultCode(value)
+}
+
+// setFaultCode stores the fault code and raises Fault or FaultCleared if it changed.
+func (o *HyInverter) setFaultCode(value uint16) {
+	o.stateMutex.Lock()
+	previous := o.faultCode
+	o.faultCode = value
+	o.stateMutex.Unlock()
+	if value != 0 && value != previous {
+		o.emit(Event{Type: Fault, FaultCode: value})
+	} else if value == 0 && previous != 0 {
+		o.emit(Event{Type: FaultCleared})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFaultCode(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.requestStatus(pollFault)
+	hy.processNext()
+	if len(port.Bytes()) != 0 {
+		t.Fatalf("fault read sent without parameter: % X", port.Bytes())
+	}
+	hy.SetFaultParameter(100)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.requestStatus(pollFault)
+	hy.processNext()
+	expected := hy.signMessage([]byte{0x01, 0x01, 0x03, 100, 0x00, 0x00})
+	if !bytes.Equal(port.Bytes(), expected) {
+		t.Fatalf("unexpected fault read % X", port.Bytes())
+	}
+	// Other parameters are ignored.
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x01, 0x03, 5, 0x9C, 0x40}))
+	if _, active := hy.FaultCode(); active {
+		t.Fatal("unexpected fault")
+	}
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x01, 0x03, 100, 0x00, 0x02}))
+	if code, active := hy.FaultCode(); code != 2 || !active {
+		t.Fatalf("unexpected fault code %d / %v", code, active)
+	}
+	if !hy.ResetFault() || len(hy.cmdChannel) != 1 || len(hy.pollChannel) != 1 {
+		t.Fatal("reset not queued")
+	}
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x01, 0x03, 100, 0x00, 0x00}))
+	if code, active := hy.FaultCode(); code != 0 || active {
+		t.Fatalf("fault not cleared: %d", code)
+	}
+	if len(events) != 2 || events[0].Type != Fault || events[0].FaultCode != 2 || events[1].Type != FaultCleared {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
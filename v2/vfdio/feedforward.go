@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// rampLatencyTolerance is the deviation of the output frequency from the set frequency,
+// in percent, at which the VFD counts as having reached the set frequency.
+const rampLatencyTolerance = 1
+
+// RampLatency returns the measured time the VFD needs to reach a new set frequency, averaged
+// over the latest S commands. It is 0 until a set frequency was reached. The resolution is
+// limited by the poll interval. Requires StatusOutputFrequency in SetPollValues.
+func (o *HyInverter) RampLatency() time.Duration {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.rampLatency
+}
+
+// startRampLatency starts the measurement for a sent set frequency.
+func (o *HyInverter) startRampLatency() {
+	o.stateMutex.Lock()
+	o.frequencySentAt = o.clockLocked().Now()
+	o.rampMeasuring = true
+	o.stateMutex.Unlock()
+}
+
+// measureRampLatency completes the measurement when the output frequency reached the set
+// frequency.
+func (o *HyInverter) measureRampLatency(output uint16) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	deviation := math.Abs(float64(output) - float64(o.sentFrequency))
+	if !o.rampMeasuring || deviation*100 > float64(o.sentFrequency)*rampLatencyTolerance {
+		return
+	}
+	o.rampMeasuring = false
+	latency := o.clockLocked().Now().Sub(o.frequencySentAt)
+	if o.rampLatency == 0 {
+		o.rampLatency = latency
+	} else {
+		o.rampLatency = (3*o.rampLatency + latency) / 4
+	}
+}
+
+// speedLead extrapolates gradual S ramps of a streamed program, see StreamOptions.Feedforward.
+type speedLead struct {
+	speeds int
+	last   float64
+	step   float64
+	lastAt time.Time
+}
+
+// next returns the speed to command for the programmed speed at the given time. Within a
+// ramp, the speed is extrapolated by latency along the slope of the last step. The lead is
+// limited to one step, so the ramp's end is not overshot by more than that.
+func (l *speedLead) next(speed float64, at time.Time, latency time.Duration) float64 {
+	step := speed - l.last
+	elapsed := at.Sub(l.lastAt)
+	ramp := l.speeds >= 2 && step != 0 && l.step != 0 && (step > 0) == (l.step > 0) && elapsed > 0
+	l.speeds++
+	l.last, l.step, l.lastAt = speed, step, at
+	if !ramp || latency <= 0 {
+		return speed
+	}
+	lead := step * latency.Seconds() / elapsed.Seconds()
+	if math.Abs(lead) > math.Abs(step) {
+		lead = step
+	}
+	return math.Max(speed+lead, 0)
+}
+
+// leadWord returns the S word for the extrapolated speed of a programmed S word.
+func (o *HyInverter) leadWord(lead *speedLead, word string) string {
+	speed, err := strconv.ParseFloat(word[1:], 64)
+	if err != nil {
+		return word
+	}
+	adjusted := lead.next(speed, o.now(), o.RampLatency())
+	if o.maxRpm > 0 && adjusted > float64(o.maxRpm) {
+		adjusted = float64(o.maxRpm)
+	}
+	if adjusted == speed {
+		return word
+	}
+	return "S" + strconv.Itoa(int(math.Floor(adjusted+0.5)))
+}
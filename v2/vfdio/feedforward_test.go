@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpeedLead(t *testing.T) {
+	start := time.Now()
+	latency := 500 * time.Millisecond
+	var lead speedLead
+	// A ramp of 100 RPM per second, a step every second.
+	for i, expected := range []float64{1000, 1100, 1250, 1350, 1450} {
+		speed := 1000 + 100*float64(i)
+		if adjusted := lead.next(speed, start.Add(time.Duration(i)*time.Second), latency); adjusted != expected {
+			t.Fatalf("step %d: expected %v, got %v", i, expected, adjusted)
+		}
+	}
+	// The direction changed, no lead.
+	if adjusted := lead.next(1000, start.Add(5*time.Second), latency); adjusted != 1000 {
+		t.Fatalf("lead at the end of the ramp: %v", adjusted)
+	}
+	// Limited to one step.
+	lead.next(900, start.Add(6*time.Second), latency)
+	if adjusted := lead.next(800, start.Add(6100*time.Millisecond), latency); adjusted != 700 {
+		t.Fatalf("lead not limited: %v", adjusted)
+	}
+}
+
+func TestRampLatency(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.sentFrequency = 10000
+	hy.startRampLatency()
+	time.Sleep(20 * time.Millisecond)
+	hy.measureRampLatency(5000)
+	if hy.RampLatency() != 0 {
+		t.Fatalf("latency measured before reaching the set frequency: %v", hy.RampLatency())
+	}
+	hy.measureRampLatency(9950)
+	if latency := hy.RampLatency(); latency < 20*time.Millisecond || latency > time.Second {
+		t.Fatalf("unexpected latency %v", latency)
+	}
+}
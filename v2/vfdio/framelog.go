@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FrameDirection tells if a logged frame was sent or received.
+type FrameDirection int
+
+const (
+	// Transmitted frames were written to the port.
+	Transmitted FrameDirection = iota
+	// Received frames were read from the port.
+	Received
+)
+
+func (d FrameDirection) String() string {
+	if d == Transmitted {
+		return "TX"
+	}
+	return "RX"
+}
+
+// Decode states of received frames.
+const (
+	FrameOk              = "ok"
+	FrameUnknown         = "unknown message"
+	FrameDiscarded       = "discarded (noise or CRC error)"
+	FrameIncomplete      = "incomplete"
+	FrameTimingViolation = "rejected (timing violation)"
+)
+
+// FrameRecord is an entry of the frame log.
+type FrameRecord struct {
+	Time      time.Time
+	Direction FrameDirection
+	Data      []byte
+	// Status is the decode state of received data, empty for transmitted frames.
+	Status string
+}
+
+func (r FrameRecord) String() string {
+	line := fmt.Sprintf("%s %v % X", r.Time.Format("15:04:05.000000"), r.Direction, r.Data)
+	if r.Status != "" {
+		line += " " + r.Status
+	}
+	return line
+}
+
+// frameLog is a ring buffer of the latest frames. It also keeps the hooks of OnTransmit and
+// OnReceive.
+type frameLog struct {
+	mutex        sync.Mutex
+	records      []FrameRecord
+	next         int
+	full         bool
+	transmitHook func([]byte)
+	receiveHook  func([]byte)
+}
+
+// SetFrameLog enables logging of the latest transmitted and received frames, so a protocol trace
+// can be attached to bug reports. Capacity is the number of kept frames, 0 disables the log.
+func (o *HyInverter) SetFrameLog(capacity int) {
+	o.frameLog.mutex.Lock()
+	defer o.frameLog.mutex.Unlock()
+	o.frameLog.records = make([]FrameRecord, capacity)
+	o.frameLog.next = 0
+	o.frameLog.full = false
+}
+
+// FrameLog returns the logged frames, oldest first.
+func (o *HyInverter) FrameLog() []FrameRecord {
+	l := &o.frameLog
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.full {
+		return append([]FrameRecord(nil), l.records[:l.next]...)
+	}
+	return append(append([]FrameRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// WriteFrameLog writes the logged frames as text, one frame per line.
+func (o *HyInverter) WriteFrameLog(w io.Writer) error {
+	for _, record := range o.FrameLog() {
+		if _, err := fmt.Fprintln(w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnTransmit registers a hook which is called with every frame written to the port, e.g.
+// for protocol analyzers or a live view of the bus traffic. It replaces the previous hook,
+// nil removes it. The hook runs on the goroutine writing to the port and must return
+// quickly; it may keep the slice.
+func (o *HyInverter) OnTransmit(hook func([]byte)) {
+	o.frameLog.mutex.Lock()
+	o.frameLog.transmitHook = hook
+	o.frameLog.mutex.Unlock()
+}
+
+// OnReceive works like OnTransmit for the data read from the port: valid frames and the
+// discarded bytes, see FrameRecord.Status. It runs on the goroutine reading the port.
+func (o *HyInverter) OnReceive(hook func([]byte)) {
+	o.frameLog.mutex.Lock()
+	o.frameLog.receiveHook = hook
+	o.frameLog.mutex.Unlock()
+}
+
+func (o *HyInverter) logFrame(direction FrameDirection, data []byte, status string) {
+	l := &o.frameLog
+	if len(data) == 0 {
+		return
+	}
+	o.logFrameEntry(direction, data, status)
+	l.mutex.Lock()
+	hook := l.receiveHook
+	if direction == Transmitted {
+		hook = l.transmitHook
+	}
+	l.mutex.Unlock()
+	if hook != nil {
+		hook(append([]byte(nil), data...))
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.records) == 0 {
+		return
+	}
+	l.records[l.next] = FrameRecord{
+		Time:      o.now(),
+		Direction: direction,
+		Data:      append([]byte(nil), data...),
+		Status:    status,
+	}
+	if l.next++; l.next == len(l.records) {
+		l.next = 0
+		l.full = true
+	}
+}
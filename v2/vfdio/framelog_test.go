@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFrameLog(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetFrameLog(3)
+	hy.GCode("M3")
+	hy.processNext()
+	hy.requestStatus(StatusOutputFrequency)
+	hy.processNext()
+	port.Reply([]byte{0x55})
+	port.Reply(hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	go parser(hy)
+	defer func() { atomic.StoreInt32(&hy.stop, 1) }()
+	var records []FrameRecord
+	for i := 0; i < 100 && len(records) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records = hy.FrameLog()
+	}
+	// The first frame (M3) was overwritten.
+	if len(records) != 3 {
+		t.Fatalf("unexpected log %v", records)
+	}
+	expected := []struct {
+		direction FrameDirection
+		length    int
+		status    string
+	}{{Transmitted, 8, ""}, {Received, 1, FrameDiscarded}, {Received, 8, FrameOk}}
+	for i, e := range expected {
+		if records[i].Direction != e.direction || len(records[i].Data) != e.length || records[i].Status != e.status {
+			t.Errorf("record %d: unexpected %v", i, records[i])
+		}
+	}
+	var text bytes.Buffer
+	hy.WriteFrameLog(&text)
+	if lines := strings.Split(strings.TrimSpace(text.String()), "\n"); len(lines) != 3 || !strings.Contains(lines[0], " TX 01 04 03 01 00 00 ") {
+		t.Errorf("unexpected text log:\n%s", text.String())
+	}
+}
+
+func TestFrameHooks(t *testing.T) {
+	hy, port := newTestInverter()
+	var mutex sync.Mutex
+	var transmitted, received [][]byte
+	hy.OnTransmit(func(frame []byte) {
+		mutex.Lock()
+		transmitted = append(transmitted, frame)
+		mutex.Unlock()
+	})
+	hy.OnReceive(func(data []byte) {
+		mutex.Lock()
+		received = append(received, data)
+		mutex.Unlock()
+	})
+	answer := hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00})
+	hy.requestStatus(StatusOutputFrequency)
+	hy.processNext()
+	port.Reply([]byte{0x55})
+	port.Reply(answer)
+	go parser(hy)
+	defer func() { atomic.StoreInt32(&hy.stop, 1) }()
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		n := len(received)
+		mutex.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(transmitted) != 1 || !bytes.Equal(transmitted[0], port.Bytes()) {
+		t.Errorf("unexpected transmitted frames % X", transmitted)
+	}
+	if len(received) != 2 || !bytes.Equal(received[0], []byte{0x55}) || !bytes.Equal(received[1], answer) {
+		t.Errorf("unexpected received data % X", received)
+	}
+	hy.OnTransmit(nil)
+	hy.GCode("M3")
+	hy.processNext()
+	if len(transmitted) != 1 {
+		t.Error("hook called after it was removed")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package gateway exposes a Huanyang VFD as a Modbus TCP slave. Masters like SCADA tools
+// or Node-RED query the values cached by the library's polling, so the serial port stays
+// exclusively owned by vfdio.
+//
+// Register map (all unit IDs are accepted):
+//
+//   Input registers 0-7 (function 0x04): raw status values, see vfdio.StatusValue.
+//   Holding register 0 (functions 0x03, 0x06, 0x10): control, 0 = stop, 1 = forward, 2 = reverse.
+//   Holding register 1 (functions 0x03, 0x06, 0x10): set speed in RPM.
+//
+package gateway
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"io"
+	"net"
+	"sync"
+)
+
+// Holding register addresses.
+const (
+	RegisterControl = 0
+	RegisterSpeed   = 1
+)
+
+// Control register values.
+const (
+	ControlStop    = 0
+	ControlForward = 1
+	ControlReverse = 2
+)
+
+// Modbus exception codes.
+const (
+	exceptionIllegalFunction    = 0x01
+	exceptionIllegalAddress     = 0x02
+	exceptionIllegalValue       = 0x03
+	exceptionDeviceFailure      = 0x04
+	exceptionTargetNotResponded = 0x0B
+)
+
+const statusRegisterCount = 8
+
+// Spindle is the part of vfdio.HyInverter used by the gateway.
+type Spindle interface {
+	GCode(cmd string) bool
+	RawStatus(value vfdio.StatusValue) uint16
+	Online() bool
+}
+
+// Server translates Modbus TCP requests into calls of a Spindle.
+type Server struct {
+	spindle Spindle
+	mutex   sync.Mutex
+	holding [2]uint16
+}
+
+// NewServer creates a gateway for the given spindle, usually a *vfdio.HyInverter.
+func NewServer(spindle Spindle) *Server {
+	return &Server{spindle: spindle}
+}
+
+// ListenAndServe listens on the TCP address (e.g. ":502") and serves until the listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections and handles each one in a new goroutine.
+func (s *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn answers requests of a single master until the connection is closed.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	header := make([]byte, 7)
+	for {
+		// MBAP header: transaction id, protocol id, length, unit id
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:6])
+		if binary.BigEndian.Uint16(header[2:4]) != 0 || length < 2 || length > 254 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		response := s.handle(pdu)
+		frame := make([]byte, 7, 7+len(response))
+		copy(frame, header)
+		binary.BigEndian.PutUint16(frame[4:6], uint16(len(response)+1))
+		if _, err := conn.Write(append(frame, response...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle processes a protocol data unit and returns the response PDU.
+func (s *Server) handle(pdu []byte) []byte {
+	function := pdu[0]
+	switch function {
+	case 0x03, 0x04:
+		if len(pdu) != 5 {
+			return exception(function, exceptionIllegalValue)
+		}
+		address := binary.BigEndian.Uint16(pdu[1:3])
+		count := binary.BigEndian.Uint16(pdu[3:5])
+		values, code := s.read(function, address, count)
+		if code != 0 {
+			return exception(function, code)
+		}
+		response := []byte{function, byte(2 * len(values))}
+		for _, value := range values {
+			response = append(response, byte(value>>8), byte(value))
+		}
+		return response
+	case 0x06:
+		if len(pdu) != 5 {
+			return exception(function, exceptionIllegalValue)
+		}
+		address := binary.BigEndian.Uint16(pdu[1:3])
+		if code := s.write(address, []uint16{binary.BigEndian.Uint16(pdu[3:5])}); code != 0 {
+			return exception(function, code)
+		}
+		return pdu
+	case 0x10:
+		if len(pdu) < 6 || int(pdu[5]) != len(pdu)-6 {
+			return exception(function, exceptionIllegalValue)
+		}
+		address := binary.BigEndian.Uint16(pdu[1:3])
+		count := binary.BigEndian.Uint16(pdu[3:5])
+		if int(count)*2 != int(pdu[5]) {
+			return exception(function, exceptionIllegalValue)
+		}
+		values := make([]uint16, count)
+		for i := range values {
+			values[i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+		}
+		if code := s.write(address, values); code != 0 {
+			return exception(function, code)
+		}
+		return pdu[:5]
+	}
+	return exception(function, exceptionIllegalFunction)
+}
+
+func (s *Server) read(function byte, address, count uint16) (values []uint16, code byte) {
+	if function == 0x04 {
+		if count == 0 || int(address)+int(count) > statusRegisterCount {
+			return nil, exceptionIllegalAddress
+		}
+		if !s.spindle.Online() {
+			return nil, exceptionTargetNotResponded
+		}
+		for i := address; i < address+count; i++ {
+			values = append(values, s.spindle.RawStatus(vfdio.StatusValue(i)))
+		}
+		return values, 0
+	}
+	if count == 0 || int(address)+int(count) > len(s.holding) {
+		return nil, exceptionIllegalAddress
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append(values, s.holding[address:address+count]...), 0
+}
+
+func (s *Server) write(address uint16, values []uint16) (code byte) {
+	if len(values) == 0 || int(address)+len(values) > len(s.holding) {
+		return exceptionIllegalAddress
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cmds := ""
+	for i, value := range values {
+		switch address + uint16(i) {
+		case RegisterControl:
+			switch value {
+			case ControlStop:
+				cmds += "M5 "
+			case ControlForward:
+				cmds += "M3 "
+			case ControlReverse:
+				cmds += "M4 "
+			default:
+				return exceptionIllegalValue
+			}
+		case RegisterSpeed:
+			// The speed is applied before starting the spindle.
+			cmds = fmt.Sprintf("S%d ", value) + cmds
+		}
+	}
+	if !s.spindle.GCode(cmds) {
+		return exceptionDeviceFailure
+	}
+	copy(s.holding[address:], values)
+	return 0
+}
+
+func exception(function, code byte) []byte {
+	return []byte{function | 0x80, code}
+}
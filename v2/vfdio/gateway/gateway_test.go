@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"bytes"
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"io"
+	"net"
+	"testing"
+)
+
+type fakeSpindle struct {
+	online bool
+	status [8]uint16
+	gcode  []string
+}
+
+func (f *fakeSpindle) GCode(cmd string) bool {
+	f.gcode = append(f.gcode, cmd)
+	return true
+}
+
+func (f *fakeSpindle) RawStatus(value vfdio.StatusValue) uint16 {
+	return f.status[value]
+}
+
+func (f *fakeSpindle) Online() bool {
+	return f.online
+}
+
+func transact(t *testing.T, conn net.Conn, pdu []byte) []byte {
+	request := append([]byte{0x12, 0x34, 0x00, 0x00, 0x00, byte(len(pdu) + 1), 0x01}, pdu...)
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x12 || header[1] != 0x34 || header[6] != 0x01 {
+		t.Fatalf("unexpected MBAP header % X", header)
+	}
+	response := make([]byte, int(header[5])-1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestGateway(t *testing.T) {
+	spindle := &fakeSpindle{online: true}
+	spindle.status[vfdio.StatusOutputFrequency] = 40000
+	spindle.status[vfdio.StatusOutputCurrent] = 42
+	client, server := net.Pipe()
+	defer client.Close()
+	go NewServer(spindle).ServeConn(server)
+
+	tests := []struct {
+		request  []byte
+		response []byte
+	}{
+		{[]byte{0x04, 0x00, 0x01, 0x00, 0x02}, []byte{0x04, 0x04, 0x9C, 0x40, 0x00, 0x2A}},
+		{[]byte{0x04, 0x00, 0x07, 0x00, 0x02}, []byte{0x84, 0x02}},
+		{[]byte{0x10, 0x00, 0x00, 0x00, 0x02, 0x04, 0x00, 0x01, 0x2E, 0xE0}, []byte{0x10, 0x00, 0x00, 0x00, 0x02}},
+		{[]byte{0x03, 0x00, 0x00, 0x00, 0x02}, []byte{0x03, 0x04, 0x00, 0x01, 0x2E, 0xE0}},
+		{[]byte{0x06, 0x00, 0x00, 0x00, 0x07}, []byte{0x86, 0x03}},
+		{[]byte{0x06, 0x00, 0x00, 0x00, 0x00}, []byte{0x06, 0x00, 0x00, 0x00, 0x00}},
+		{[]byte{0x01, 0x00, 0x00, 0x00, 0x01}, []byte{0x81, 0x01}},
+	}
+	for _, test := range tests {
+		if response := transact(t, client, test.request); !bytes.Equal(response, test.response) {
+			t.Errorf("request % X: expected % X, got % X", test.request, test.response, response)
+		}
+	}
+	if len(spindle.gcode) != 2 || spindle.gcode[0] != "S12000 M3 " || spindle.gcode[1] != "M5 " {
+		t.Errorf("unexpected G-code %q", spindle.gcode)
+	}
+
+	spindle.online = false
+	if response := transact(t, client, []byte{0x04, 0x00, 0x00, 0x00, 0x01}); !bytes.Equal(response, []byte{0x84, 0x0B}) {
+		t.Errorf("offline read: got % X", response)
+	}
+}
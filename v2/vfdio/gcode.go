@@ -0,0 +1,154 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSyntax is wrapped by a SyntaxError.
+var ErrSyntax = errors.New("vfdio: G-code syntax error")
+
+// Word is a word of a G-code line, a letter followed by a number, e.g. S12000 or G28.3.
+type Word struct {
+	// Letter is the letter in upper case.
+	Letter byte
+	Value  float64
+	// Text is the word as written in the line, Offset its byte offset in the line.
+	Text   string
+	Offset int
+}
+
+// SyntaxError describes malformed input of a G-code line, see ParseLine. Offset is the byte
+// offset of the input in the line. Text is the malformed word or character, empty for an
+// unterminated comment. It wraps ErrSyntax.
+type SyntaxError struct {
+	Offset int
+	Text   string
+	Reason string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("%v at offset %d: %s", ErrSyntax, e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("%v at offset %d: %s: %q", ErrSyntax, e.Offset, e.Reason, e.Text)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
+// ParseLine splits a G-code line into its words. Blanks between the words are optional,
+// e.g. "M3S12000". Control characters, comments in parentheses and comments after a
+// semicolon are skipped. At the first malformed input it returns the words before it and a
+// *SyntaxError, e.g. for "S1..2" or a character which does not start a word.
+//
+//   words, err := vfdio.ParseLine("N10 M3 S12000 (start)")
+//   // words: N10, M3 and S12000, err: nil
+//
+func ParseLine(line string) ([]Word, error) {
+	var words []Word
+	t := tokenizer{line: line}
+	for {
+		word, ok, err := t.next()
+		if err != nil {
+			return words, err
+		}
+		if !ok {
+			return words, nil
+		}
+		words = append(words, word)
+	}
+}
+
+// tokenizer reads the words of a line, see ParseLine.
+type tokenizer struct {
+	line string
+	pos  int
+}
+
+// next returns the next word of the line. ok is false at the end of the line. After a
+// *SyntaxError the position is unchanged, see skip.
+func (t *tokenizer) next() (word Word, ok bool, err *SyntaxError) {
+	line := t.line
+	for t.pos < len(line) {
+		c := line[t.pos]
+		switch {
+		case isBlank(c):
+			t.pos++
+		case c == ';':
+			t.pos = len(line)
+		case c == '(':
+			end := strings.IndexByte(line[t.pos:], ')')
+			if end < 0 {
+				return Word{}, false, &SyntaxError{Offset: t.pos, Reason: "unterminated comment"}
+			}
+			t.pos += end + 1
+		case isLetter(c):
+			end := t.pos + 1
+			for end < len(line) && (line[end] == '-' || line[end] == '+' || line[end] == '.' || isDigit(line[end])) {
+				end++
+			}
+			text := line[t.pos:end]
+			value, parseErr := strconv.ParseFloat(text[1:], 64)
+			if parseErr != nil {
+				return Word{}, false, &SyntaxError{Offset: t.pos, Text: text, Reason: "malformed word"}
+			}
+			word = Word{Letter: c &^ 0x20, Value: value, Text: text, Offset: t.pos}
+			t.pos = end
+			return word, true, nil
+		default:
+			_, size := utf8.DecodeRuneInString(line[t.pos:])
+			return Word{}, false, &SyntaxError{Offset: t.pos, Text: line[t.pos : t.pos+size], Reason: "unexpected character"}
+		}
+	}
+	return Word{}, false, nil
+}
+
+// skip moves past the input up to the next blank, e.g. after a *SyntaxError, and returns it.
+func (t *tokenizer) skip() string {
+	start := t.pos
+	for t.pos < len(t.line) && !isBlank(t.line[t.pos]) {
+		t.pos++
+	}
+	return t.line[start:t.pos]
+}
+
+// isBlank returns true for blanks and control characters, which separate words.
+func isBlank(c byte) bool {
+	return c == ' ' || c < 0x20 || c == 0x7F
+}
+
+// gcodeToken is a word of a line or malformed input, see scanGCode. err is set for
+// malformed input, text holds it up to the next blank.
+type gcodeToken struct {
+	text   string
+	offset int
+	word   Word
+	err    *SyntaxError
+}
+
+// scanGCode splits a line like ParseLine, but continues after malformed input, which is
+// returned as a token up to the next blank. The library words like "?" or "end" are
+// malformed G-code and are returned this way as well.
+func scanGCode(line string) (tokens []gcodeToken) {
+	t := tokenizer{line: line}
+	for {
+		word, ok, err := t.next()
+		if err != nil {
+			tokens = append(tokens, gcodeToken{text: t.skip(), offset: err.Offset, err: err})
+			continue
+		}
+		if !ok {
+			return
+		}
+		tokens = append(tokens, gcodeToken{text: word.Text, offset: word.Offset, word: word})
+	}
+}
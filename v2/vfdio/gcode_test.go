@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	words, err := ParseLine("N12S20 f200M3\tG28.3Z-100 (comment) Y-29.3 ; rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Word{
+		{Letter: 'N', Value: 12, Text: "N12", Offset: 0},
+		{Letter: 'S', Value: 20, Text: "S20", Offset: 3},
+		{Letter: 'F', Value: 200, Text: "f200", Offset: 7},
+		{Letter: 'M', Value: 3, Text: "M3", Offset: 11},
+		{Letter: 'G', Value: 28.3, Text: "G28.3", Offset: 14},
+		{Letter: 'Z', Value: -100, Text: "Z-100", Offset: 19},
+		{Letter: 'Y', Value: -29.3, Text: "Y-29.3", Offset: 35},
+	}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, words)
+	}
+
+	tests := map[string]SyntaxError{
+		"M3 S1..2":    {Offset: 3, Text: "S1..2", Reason: "malformed word"},
+		"M3 Sabc":     {Offset: 3, Text: "S", Reason: "malformed word"},
+		"G0 X1 # Y2":  {Offset: 6, Text: "#", Reason: "unexpected character"},
+		"M3 (comment": {Offset: 3, Reason: "unterminated comment"},
+	}
+	for line, expected := range tests {
+		words, err := ParseLine(line)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrSyntax) || *syntaxErr != expected {
+			t.Errorf("%s: expected %+v, got %v", line, expected, err)
+		}
+		if len(words) == 0 || words[len(words)-1].Offset >= expected.Offset {
+			t.Errorf("%s: expected the words before the error, got %+v", line, words)
+		}
+	}
+}
+
+func TestScanGCode(t *testing.T) {
+	tests := map[string][]string{
+		"N12S20 F200M3 G28.3Z-100 Y-29.3": {"N12", "S20", "F200", "M3", "G28.3", "Z-100", "Y-29.3"},
+		"M3abc Sabc ? X#1":                {"M3", "abc", "Sabc", "?", "X#1"},
+		"end hz50 (comment) M5":           {"end", "hz50", "M5"},
+	}
+	for line, expected := range tests {
+		var words []string
+		for _, token := range scanGCode(line) {
+			words = append(words, token.text)
+		}
+		if !reflect.DeepEqual(words, expected) {
+			t.Errorf("%s: expected %q, got %q", line, expected, words)
+		}
+	}
+}
+
+func FuzzParseLine(f *testing.F) {
+	for _, line := range []string{"M3 S12000", "N12S20 F200M3", "G4 P2.5 (wait)", "S1..2", "X#1 ; end", "(open", "%"} {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		words, err := ParseLine(line)
+		for _, word := range words {
+			if !strings.HasPrefix(line[word.Offset:], word.Text) || word.Letter < 'A' || word.Letter > 'Z' {
+				t.Fatalf("word %+v does not match the line %q", word, line)
+			}
+		}
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) && (syntaxErr.Offset >= len(line) || !strings.HasPrefix(line[syntaxErr.Offset:], syntaxErr.Text)) {
+			t.Fatalf("error %v does not match the line %q", err, line)
+		}
+		offset := 0
+		for _, token := range scanGCode(line) {
+			if token.offset < offset || !strings.HasPrefix(line[token.offset:], token.text) || token.text == "" {
+				t.Fatalf("token %+v does not match the line %q", token, line)
+			}
+			offset = token.offset + len(token.text)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// huanyangDriver sends the frames of the Huanyang protocol to the serial port. It is the
+// driver of a HyInverter opened with Open or OpenPort.
+type huanyangDriver struct {
+	o *HyInverter
+}
+
+var _ connectionDriver = huanyangDriver{}
+
+// Start sends the run frame of the direction on the wire.
+func (d huanyangDriver) Start(direction Direction) error {
+	command := CommandRunForward
+	if direction == Backward {
+		command = CommandRunBackward
+	}
+	err := d.o.write(d.o.wireControlFrame(command))
+	d.o.sleep(time.Millisecond * 110)
+	return err
+}
+
+// Stop sends the stop frame and repeats it until the VFD acknowledges it, see
+// SetStopEscalation.
+func (d huanyangDriver) Stop() error {
+	o := d.o
+	frame := o.wireControlFrame(CommandStop)
+	deadline, fallback := o.stopEscalation()
+	acks := atomic.LoadUint32(&o.controlAcks)
+	clock := o.currentClock()
+	start := clock.Now()
+	err := o.write(frame)
+	if deadline <= 0 {
+		o.sleep(time.Millisecond * 110)
+		return err
+	}
+	for {
+		for retry := clock.Now().Add(stopRetryInterval); clock.Now().Before(retry); {
+			if atomic.LoadUint32(&o.controlAcks) != acks {
+				return nil
+			}
+			if !o.sleep(stopAckPollInterval) {
+				break
+			}
+		}
+		if clock.Now().Sub(start) >= deadline || o.stopped() {
+			break
+		}
+		o.log(slog.LevelWarn, "vfdio: stop not acknowledged, retrying", "elapsed", clock.Now().Sub(start))
+		o.write(frame)
+	}
+	o.emit(Event{Type: StopFailed, Err: ErrStopNotAcknowledged})
+	if fallback != nil {
+		fallback()
+	}
+	return ErrStopNotAcknowledged
+}
+
+// SetFrequency sends the set frequency frame.
+func (d huanyangDriver) SetFrequency(frequency uint16) error {
+	err := d.o.write(d.o.frequencyFrame(frequency))
+	d.o.sleep(time.Millisecond * 110)
+	return err
+}
+
+// Status returns the last polled state, see driverStatus.
+func (d huanyangDriver) Status() (DriverStatus, error) {
+	return d.o.driverStatus()
+}
+
+// Fault returns the last polled fault code, see driverFault.
+func (d huanyangDriver) Fault() (uint16, error) {
+	return d.o.driverFault()
+}
+
+// jog sends the jog frame of the direction on the wire.
+func (d huanyangDriver) jog(direction Direction) error {
+	command := ControlJogForward
+	if direction == Backward {
+		command = ControlJogReverse
+	}
+	err := d.o.write(d.o.wireControlFrame(command))
+	d.o.sleep(time.Millisecond * 110)
+	return err
+}
+
+// pollValues returns the poll values and the fault parameter and run state if enabled.
+func (d huanyangDriver) pollValues() []StatusValue {
+	values := d.o.PollValues()
+	if d.o.FaultParameter() != 0 {
+		values = append(values, pollFault)
+	}
+	if d.o.RunStatePolling() {
+		values = append(values, pollRunState)
+	}
+	return values
+}
+
+// read sends the request frame of a single status value, the fault parameter or the
+// control status. The answer is handled by the parser.
+func (d huanyangDriver) read(value StatusValue) {
+	switch value {
+	case pollFault:
+		d.o.readFault()
+	case pollRunState:
+		d.o.readRunState()
+	default:
+		d.o.write(d.o.statusFrame(value))
+	}
+	d.o.sleep(time.Millisecond * 110)
+}
+
+// writeFrame writes a frame to the port. Failures are counted towards a reconnect.
+func (d huanyangDriver) writeFrame(frame []byte) error {
+	o := d.o
+	if o.ReadOnly() && !isReadFrame(frame) {
+		return ErrReadOnly
+	}
+	wire, err := o.toWire(frame)
+	if err != nil {
+		return err
+	}
+	o.stateMutex.Lock()
+	o.pendingRequest = frame
+	o.stateMutex.Unlock()
+	port := o.currentPort()
+	o.txMutex.Lock()
+	_, err = port.Write(wire)
+	o.txMutex.Unlock()
+	o.markProgress()
+	o.logFrame(Transmitted, wire, "")
+	if err != nil {
+		o.portFailed(port, err)
+		return fmt.Errorf("%w: %w", ErrPortClosed, err)
+	}
+	o.markSent(frame)
+	return nil
+}
+
+// close closes the port, which ends a blocking read of the parser.
+func (d huanyangDriver) close() error {
+	return d.o.currentPort().Close()
+}
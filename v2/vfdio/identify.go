@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+)
+
+// Motor parameters of the HY series read by Identify.
+const (
+	// ParameterRatedVoltage is PD141, the rated motor voltage in V.
+	ParameterRatedVoltage byte = registers.PD141
+	// ParameterMotorPoles is PD143, the number of motor poles.
+	ParameterMotorPoles byte = registers.PD143
+)
+
+// Identity holds the values which identify a drive and its motor. The Huanyang protocol
+// has no model or firmware register, the configured ratings are used instead.
+type Identity struct {
+	// MaxFrequency is PD005 in 0.01 Hz.
+	MaxFrequency uint16
+	// RatedVoltage is PD141 in V.
+	RatedVoltage uint16
+	// RatedCurrent is PD142 in 0.1 A.
+	RatedCurrent uint16
+	// MotorPoles is PD143.
+	MotorPoles uint16
+	// RatedRpm is PD144.
+	RatedRpm uint16
+	// BaseFrequency is PD176: 0 = 50 Hz, 1 = 60 Hz.
+	BaseFrequency uint16
+}
+
+// Identify reads the ratings of the drive, e.g. to verify that a multi-drive setup talks
+// to the expected inverter before sending commands, see Identity.Matches.
+func (o *HyInverter) Identify() (Identity, error) {
+	var id Identity
+	if err := o.checkOpen(); err != nil {
+		return id, err
+	}
+	for _, field := range []struct {
+		parameter byte
+		value     *uint16
+	}{
+		{ParameterMaxFrequency, &id.MaxFrequency},
+		{ParameterRatedVoltage, &id.RatedVoltage},
+		{ParameterRatedCurrent, &id.RatedCurrent},
+		{ParameterMotorPoles, &id.MotorPoles},
+		{ParameterRatedMotorRpm, &id.RatedRpm},
+		{ParameterBaseFrequency, &id.BaseFrequency},
+	} {
+		value, err := o.readParameter(field.parameter)
+		if err != nil {
+			// The error names the parameter.
+			return id, err
+		}
+		*field.value = value
+	}
+	return id, nil
+}
+
+// Matches returns true if id has the values of expected. Fields of expected which are 0
+// are not compared, except BaseFrequency.
+func (id Identity) Matches(expected Identity) bool {
+	for _, field := range [][2]uint16{
+		{id.MaxFrequency, expected.MaxFrequency},
+		{id.RatedVoltage, expected.RatedVoltage},
+		{id.RatedCurrent, expected.RatedCurrent},
+		{id.MotorPoles, expected.MotorPoles},
+		{id.RatedRpm, expected.RatedRpm},
+	} {
+		if field[1] != 0 && field[0] != field[1] {
+			return false
+		}
+	}
+	return id.BaseFrequency == expected.BaseFrequency
+}
+
+func (id Identity) String() string {
+	return fmt.Sprintf("%.2f Hz max, motor %d V %.1f A %d poles %d RPM at %d Hz", float64(id.MaxFrequency)/100,
+		id.RatedVoltage, float64(id.RatedCurrent)/10, id.MotorPoles, id.RatedRpm, 50+10*id.BaseFrequency)
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdentify(t *testing.T) {
+	vfd := simulator.New()
+	vfd.Parameters[ParameterRatedVoltage] = 220
+	vfd.Parameters[ParameterRatedCurrent] = 80
+	vfd.Parameters[ParameterMotorPoles] = 2
+	hy := NewVfd()
+	if _, err := hy.Identify(); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	id, err := hy.Identify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Identity{MaxFrequency: 40000, RatedVoltage: 220, RatedCurrent: 80, MotorPoles: 2, RatedRpm: 3000}
+	if id != expected {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+	if !id.Matches(Identity{RatedCurrent: 80}) || id.Matches(Identity{RatedCurrent: 100}) || id.Matches(Identity{BaseFrequency: 1}) {
+		t.Fatal("unexpected match")
+	}
+	if s := id.String(); s != "400.00 Hz max, motor 220 V 8.0 A 2 poles 3000 RPM at 50 Hz" {
+		t.Fatalf("unexpected text %q", s)
+	}
+	vfd.SetSilent(true)
+	if _, err := hy.Identify(); !errors.Is(err, ErrParameterTimeout) || strings.Count(err.Error(), "PD005") != 1 {
+		t.Fatalf("expected a timeout naming PD005 once, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+// SetInvertDirection swaps the directions sent to the VFD: M3 and forward jogs run the drive
+// in reverse, M4 and reverse jogs forward. Use it if the motor is wired so that "forward" at
+// the VFD turns the tool counter-clockwise, instead of swapping two of U, V and W.
+// Direction reports the direction of the tool accordingly. SetReverseLockout still refers to
+// M4 and reverse jogs. Default: false.
+func (o *HyInverter) SetInvertDirection(inverted bool) {
+	o.stateMutex.Lock()
+	o.invertDirection = inverted
+	o.stateMutex.Unlock()
+}
+
+// InvertDirection returns true if the directions sent to the VFD are swapped.
+func (o *HyInverter) InvertDirection() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.invertDirection
+}
+
+// wiredCommand returns the control command sent to the VFD for a command of the G-code
+// front end, see SetInvertDirection. Stop and status reads are not changed.
+func (o *HyInverter) wiredCommand(command ControlCommand) ControlCommand {
+	if !o.InvertDirection() {
+		return command
+	}
+	if command&ControlRun != 0 {
+		command ^= ControlReverseDirection
+	}
+	if command&(ControlJogForward|ControlJogReverse) != 0 {
+		command ^= ControlJogForward | ControlJogReverse
+	}
+	return command
+}
+
+// wiredDirection returns the direction of a run or jog command as it is sent, see
+// wiredCommand.
+func (o *HyInverter) wiredDirection(command ControlCommand) Direction {
+	if isReverse(o.wiredCommand(command)) {
+		return Backward
+	}
+	return Forward
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInvertDirection(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetInvertDirection(true)
+	hy.GCode("M3")
+	hy.processNext()
+	if expected := hy.signMessage([]byte{0x01, 0x03, 0x01, byte(CommandRunBackward)}); !bytes.Equal(port.Bytes(), expected) {
+		t.Fatalf("expected % X, sent % X", expected, port.Bytes())
+	}
+	frames, err := hy.EncodeCommand("M4 M5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames = append(frames, hy.controlFrame(ControlJogForward))
+	expected := [][]byte{
+		hy.signMessage([]byte{0x01, 0x03, 0x01, byte(CommandRunForward)}),
+		hy.signMessage([]byte{0x01, 0x03, 0x01, byte(CommandStop)}),
+		hy.signMessage([]byte{0x01, 0x03, 0x01, byte(ControlJogReverse)}),
+	}
+	for i := range expected {
+		if i >= len(frames) || !bytes.Equal(frames[i], expected[i]) {
+			t.Fatalf("expected % X, got % X", expected, frames)
+		}
+	}
+	// The VFD runs in reverse for M3.
+	hy.controlAnswered(ControlStatusRunCommand | ControlStatusRunning | ControlStatusReverse)
+	if !hy.Direction() {
+		t.Fatal("expected clockwise rotation of the tool")
+	}
+	hy.SetInvertDirection(false)
+	if hy.Direction() {
+		t.Fatal("expected reverse rotation of the VFD")
+	}
+}
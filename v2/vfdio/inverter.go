@@ -0,0 +1,966 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"github.com/jacobsa/go-serial/serial"
+	"github.com/npat-efault/crc16"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HyInverter is the base of a VFD controller. It contains the configuration and run time variables.
+// Example usage:
+//
+//  handle := &HyInverter{}
+//  handle.Open("/dev/ttyUSB0", WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(750*time.Millisecond))
+//  defer handle.Close()
+//  handle.GCode("M3 S300")
+//
+type HyInverter struct {
+	port io.ReadWriteCloser
+	// stop is set to 1 by Close, the goroutines end when they see it, see stopped.
+	stop        int32
+	cmdChannel  chan queuedCommand
+	pollChannel chan StatusValue
+	pollPending [pollValueCount]int32
+	// pollMutex guards the poll values and the values received from the VFD: status,
+	// setFrequency, outputFrequency, outputRpm and lastReceived. If both are needed, it is
+	// locked before stateMutex.
+	pollMutex       sync.Mutex
+	pollValues      []StatusValue
+	status          [statusValueCount]uint16
+	setFrequency    uint16
+	outputFrequency uint16
+	outputRpm       uint16
+	lastReceived    time.Time
+	pollIntervalSec float64
+	// The API sets and reads the output frequency, which has a linear relation to output RPM.
+	// frequencyPerRpm is given in register steps (0.01 Hz) per RPM, see FrequencyResolution.
+	// Experimentally determined: 3.47222 (using the VFD display while spinning)
+	frequencyPerRpm float64
+	// Experimentally determined with inverter: 11520 at my setup.
+	maxRpm uint16
+	// commandQueue is a counter which is increased by the gcode preprocessor and
+	// decreased by the gcode interpreter.
+	commandQueue int32
+	// frequencyCommanded is set by the first S command, externalFrequency is the last set
+	// frequency read back from the VFD. Guarded by stateMutex.
+	frequencyCommanded bool
+	externalFrequency  uint16
+	eventMutex         sync.Mutex
+	eventHandlers      []func(Event)
+	// stateMutex guards the settings and most of the state, see the individual accessors.
+	stateMutex         sync.Mutex
+	readTimeout        time.Duration
+	onlineThreshold    time.Duration
+	offline            bool
+	lastError          error
+	baudRate           uint
+	slaveAddress       byte
+	readOptions        ReadOptions
+	sentFrequency      uint16
+	acceptedFrequency  uint16
+	frequencyConfirmed bool
+	// clampChecked is set by the first echo or readback after an S command.
+	clampChecked bool
+	// dial opens the serial port, it is used again for reconnects.
+	dial               func() (io.ReadWriteCloser, error)
+	portMutex          sync.Mutex
+	txMutex            sync.Mutex
+	portErrors         int32
+	reconnecting       int32
+	reconnectChannel   chan struct{}
+	lastControlFrame   []byte
+	lastFrequencyFrame []byte
+	frameLog           frameLog
+	openState          OpenState
+	txStats            txStats
+	// breakerThreshold and breakerProbeInterval are valid if breakerConfigured is set.
+	breakerThreshold     int
+	breakerProbeInterval time.Duration
+	breakerConfigured    bool
+	temperatureLimit     float64
+	temperatureHigh      bool
+	faultParameter       byte
+	faultCode            uint16
+	clock                Clock
+	pollJitter           time.Duration
+	controlAcks          uint32
+	stopDeadline         time.Duration
+	stopFallback         func()
+	stopConfigured       bool
+	jogTimer             Timer
+	jogDirection         Direction
+	jogRpm               uint16
+	jogRefreshed         time.Time
+	parameterAnswer      chan parameterValue
+	lifecycleMutex       sync.RWMutex
+	lifecycle            lifecycle
+	// busMutex is held for a request and its answer.
+	busMutex     sync.Mutex
+	strictTiming bool
+	ramp         RampProfile
+	// preemptions counts the queued words which cut a ramp short.
+	preemptions     int32
+	frequencySentAt time.Time
+	rampMeasuring   bool
+	rampLatency     time.Duration
+	runStatePolling bool
+	controlStatus   ControlStatus
+	// controlStatusReceived is set by the first FunctionControl answer.
+	controlStatusReceived bool
+	minRpm                uint16
+	belowMinimum          BelowMinimum
+	aboveMaximum          AboveMaximum
+	presets               []Preset
+	commandLog            commandLog
+	reverseLockout        bool
+	estopped              bool
+	stallTimeout          time.Duration
+	stallReset            bool
+	stallConfigured       bool
+	lastProgress          time.Time
+	stalled               bool
+	keepaliveWindow       time.Duration
+	keepaliveAt           time.Time
+	keepaliveTimer        Timer
+	maxRestarts           int
+	restartDelay          time.Duration
+	restartConfigured     bool
+	usage                 Usage
+	usageSampled          time.Time
+	ratedCurrent          float64
+	readOnly              bool
+	registerMap           RegisterMap
+	// pendingRequest is the last classic request, the context of the answer for fromWire.
+	pendingRequest []byte
+	// driver is set by OpenDriver, see currentDriver.
+	driver          connectionDriver
+	invertDirection bool
+	// statusReceived counts the answers per status value for ReadStatus.
+	statusReceived [statusValueCount]uint32
+	// configOptions are applied before the options of Open, see NewVfdFromConfig.
+	configOptions []Option
+	// speedPending is set by a set frequency or run command until SpeedReached was raised.
+	// Guarded by stateMutex.
+	speedPending bool
+	// statusUpdates are the channels of StatusUpdates, updatesClosed is set by Close.
+	// Guarded by eventMutex.
+	statusUpdates []chan Status
+	updatesClosed bool
+	// pollCycleStarted is the time the last poll cycle was queued. Guarded by pollMutex.
+	pollCycleStarted time.Time
+	// done is closed by Close to wake the goroutines, which are counted by goroutines.
+	done       chan struct{}
+	goroutines sync.WaitGroup
+	// connectMutex serializes Open, OpenDriver and Close.
+	connectMutex sync.Mutex
+	// logger receives structured logs, nil disables them. Guarded by stateMutex.
+	logger *slog.Logger
+	// commandIDs tracks the words which were queued and not executed yet, see Done.
+	commandIDs commandIDs
+	// dwellCommand is the G4 word holding back the queue until dwellUntil, see startDwell.
+	// Guarded by stateMutex.
+	dwellCommand *queuedCommand
+	dwellUntil   time.Time
+	// paused is set by Pause, heldCommands were taken from the queue during the pause or
+	// while the circuit breaker was open. pauseFrequency is the frequency before PauseAt if
+	// pauseLowered is set. Guarded by stateMutex.
+	paused         bool
+	heldCommands   []queuedCommand
+	pauseLowered   bool
+	pauseFrequency uint16
+	// speedFirst is set by SetSpeedFirst. Guarded by stateMutex.
+	speedFirst bool
+	// modal is the state returned by ModalState, deferSpeedEnabled is set by SetDeferSpeed.
+	// Guarded by stateMutex.
+	modal             ModalState
+	deferSpeedEnabled bool
+	// strictWords is set by SetStrictWords. Guarded by stateMutex.
+	strictWords bool
+	// restoreRunState is set by SetRestoreRunState. Guarded by stateMutex.
+	restoreRunState bool
+	// reserved is the queue space reserved for words which were not queued yet, spaceFreed
+	// is closed when a word was taken from the queue, see reserve. Guarded by queueMutex.
+	queueMutex sync.Mutex
+	reserved   int
+	spaceFreed chan struct{}
+	// jogEnded is the time the last jog timed out, see endJog. Guarded by stateMutex.
+	jogEnded time.Time
+}
+
+// ErrOffline is wrapped by the errors which report that the VFD does not answer, like
+// ErrReadTimeout and ErrNoResponse, and by the error of Status while the VFD is offline.
+var ErrOffline = errors.New("vfdio: VFD offline")
+
+// ErrReadTimeout is reported by LastError if the VFD did not send any data within the read timeout.
+var ErrReadTimeout = fmt.Errorf("%w: no data received", ErrOffline)
+
+// ErrCRC is combined with ErrParameterTimeout if frames with an invalid CRC were received
+// instead of the answer, which points to noise on the bus, see Stats.CRCErrors.
+var ErrCRC = errors.New("vfdio: CRC error")
+
+// NewVfd creates an empty data struct. Please call Open and defer Close.
+func NewVfd() *HyInverter {
+	return &HyInverter{}
+}
+
+// Open inits a serial port handle and creates all required goroutines.
+// Param portName: OS specific refence to a serial port (examples - Windows: COM3, Linux: /dev/ttyUSB0).
+// Param opts: Settings like WithMaxRpm, WithRpmToHertz and WithPollInterval, see Option.
+// Returns ErrAlreadyOpen if called twice, or ErrPortNotFound, ErrPermissionDenied, ErrPortBusy or
+// ErrNotSerialPort if the port can't be opened. Without WithRpmToHertz, the error of reading
+// PD144 and PD176 is returned and the port is closed again. In these cases or after Close, Open
+// can be called again.
+func (o *HyInverter) Open(portName string, opts ...Option) (err error) {
+	return o.open(context.Background(), o.serialDial(portName), true, o.settings(opts))
+}
+
+// serialDial returns the function which opens the serial port with the configured options.
+// The options are read at every dial.
+func (o *HyInverter) serialDial(portName string) func() (io.ReadWriteCloser, error) {
+	return func() (io.ReadWriteCloser, error) {
+		readOptions := o.ReadOptions()
+		port, err := serial.Open(serial.OpenOptions{
+			PortName:              portName,
+			BaudRate:              o.BaudRate(),
+			DataBits:              8,
+			StopBits:              1,
+			ParityMode:            serial.PARITY_NONE,
+			InterCharacterTimeout: uint(readOptions.InterCharacterTimeout / time.Millisecond),
+			MinimumReadSize:       readOptions.MinimumReadSize,
+		})
+		if err != nil {
+			return nil, serialOpenError(portName, err)
+		}
+		return port, nil
+	}
+}
+
+// OpenPort works like Open, but uses an already opened port, for instance a simulator or a
+// network transport. Reads of the port should return io.EOF after a silent interval like
+// a serial port with inter character timeout, and an error after Close closed the port.
+// The port is not reopened after errors.
+func (o *HyInverter) OpenPort(port io.ReadWriteCloser, opts ...Option) (err error) {
+	dial := func() (io.ReadWriteCloser, error) {
+		return port, nil
+	}
+	return o.open(context.Background(), dial, false, o.settings(opts))
+}
+
+// start launches the goroutines. Parameters of the VFD are read before requests are processed.
+// If the conversion factor can't be read, the port is closed and the goroutines end again.
+func (o *HyInverter) start(port io.ReadWriteCloser, settings openSettings) (err error) {
+	o.port = port
+	o.prepareConnection(settings)
+	o.launch("parser", parser)
+	if settings.rpmToHertz <= 0 {
+		o.frequencyPerRpm, err = o.deriveFrequencyPerRpm()
+		if err != nil {
+			// Without the factor all S words would map to 0 Hz.
+			atomic.StoreInt32(&o.stop, 1)
+			close(o.done)
+			port.Close()
+			o.goroutines.Wait()
+			return err
+		}
+	}
+	o.startProcessing(settings)
+	if o.dial != nil {
+		o.reconnectChannel = make(chan struct{}, 1)
+		o.launch("reconnector", reconnector)
+	}
+	return nil
+}
+
+// prepareConnection applies the settings and creates the channels of a new connection,
+// see start and OpenDriver.
+func (o *HyInverter) prepareConnection(settings openSettings) {
+	o.frequencyPerRpm = settings.rpmToHertz
+	o.maxRpm = settings.maxRpm
+	o.pollIntervalSec = settings.pollInterval.Seconds()
+	atomic.StoreInt32(&o.stop, 0)
+	o.cmdChannel = make(chan queuedCommand, settings.queueSize)
+	o.pollChannel = make(chan StatusValue, pollValueCount)
+	o.done = make(chan struct{})
+}
+
+// startProcessing limits the RPM to the maximum frequency of the VFD and launches the
+// processor, the poller and the stall watchdog.
+func (o *HyInverter) startProcessing(settings openSettings) {
+	// maxRpm is used as passed if PD005 is not available, e.g. on drives which don't answer
+	// parameter reads.
+	var limitErr error
+	o.maxRpm, limitErr = o.limitMaxRpm(settings.maxRpm)
+	if limitErr != nil && !errors.Is(limitErr, ErrUnsupported) {
+		o.log(slog.LevelWarn, "vfdio: maximum frequency not read, max RPM not limited by the VFD", "max_rpm", settings.maxRpm, "err", limitErr)
+	}
+	o.launch("processor", processor)
+	o.launch("poller", func(handle *HyInverter) {
+		outFrequencyRequester(handle, settings.pollInterval)
+	})
+	o.launch("watchdog", stallWatchdog)
+}
+
+// OpenState selects what Open does with the VFD.
+type OpenState int
+
+const (
+	// LeaveOnOpen sends nothing on Open, a running spindle keeps running, e.g. to resume a job.
+	LeaveOnOpen OpenState = iota
+	// StopOnOpen stops the spindle and sets the frequency to zero on Open to establish a known state.
+	StopOnOpen
+)
+
+// SetOpenState selects what Open does with the VFD. Default: LeaveOnOpen.
+func (o *HyInverter) SetOpenState(state OpenState) {
+	o.stateMutex.Lock()
+	o.openState = state
+	o.stateMutex.Unlock()
+}
+
+// OpenState returns the state selected by SetOpenState.
+func (o *HyInverter) OpenState() OpenState {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.openState
+}
+
+// GCode is the external control input. It accepts string messages in the standard G-Code format.
+// Accepted commands: M2, M3, M4, M5, Sxxx. Aliases for M5: M0, M1, M30, M60.
+// G4 Pn or G4 Sn holds back the following commands for n seconds, e.g. to wait for the
+// spindle after M3; the status is still polled meanwhile.
+// Returns true if the command stack has space for the new input and the connection is open,
+// see Enqueue for the reason of a failure.
+// This function also acts as a preprocessor since it reformats the input commands.
+// Status requests (?) are queued separately with a lower priority than control commands
+// and read all values selected by SetPollValues.
+// Examples:
+//
+//   M3S400
+//   M4 S5000
+//   M9 S0 M5
+//   M3 S12000 G4 P3
+//
+func (o *HyInverter) GCode(cmd string) (ok bool) {
+	return o.Enqueue(cmd) == nil
+}
+
+// queue adds a single command word to the command queue. Returns false if it is full.
+// The result of the command is sent to results unless it is nil.
+func (o *HyInverter) queue(word lineWord, results *resultSink) bool {
+	return o.queueID(word, results) != 0
+}
+
+// queueID works like queue, but returns the ID of the queued word, 0 if the queue is full.
+func (o *HyInverter) queueID(word lineWord, results *resultSink) CommandID {
+	if ok, _ := o.reserve(1); !ok {
+		return 0
+	}
+	return o.queueReserved(word, results)
+}
+
+// queueReserved adds a word to the command queue for which space was reserved, see reserve.
+func (o *HyInverter) queueReserved(word lineWord, results *resultSink) CommandID {
+	atomic.AddInt32(&o.commandQueue, 1)
+	if preempts(word) {
+		atomic.AddInt32(&o.preemptions, 1)
+	}
+	id := o.commandIDs.next()
+	o.cmdChannel <- queuedCommand{word.text, o.now(), results, id, word.internal}
+	o.queueMutex.Lock()
+	o.reserved--
+	o.queueMutex.Unlock()
+	return id
+}
+
+// QueueDepth returns the number of command words which were queued and not sent yet,
+// including the one being sent. A sender can queue a line of n words without getting
+// ErrQueueFull if QueueCapacity() - QueueDepth() >= n.
+func (o *HyInverter) QueueDepth() int {
+	return int(atomic.LoadInt32(&o.commandQueue))
+}
+
+// QueueCapacity returns the number of command words which can be queued, see QueueDepth
+// and WithQueueSize. It returns 0 before Open.
+func (o *HyInverter) QueueCapacity() int {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	return cap(o.cmdChannel)
+}
+
+// processNext blocks until work is available and executes it. Control commands
+// are always executed before pending status requests. While the circuit breaker is
+// open, only a probe and stop words are sent.
+func (o *HyInverter) processNext() {
+	if o.CircuitOpen() {
+		o.probeCircuit()
+		return
+	}
+	if o.holding() {
+		o.continueHold()
+		return
+	}
+	if command := o.takeHeldCommand(); command != nil {
+		o.runCommand(*command)
+		return
+	}
+	select {
+	case command := <-o.cmdChannel:
+		o.executeQueued(command)
+		return
+	default:
+	}
+	select {
+	case command := <-o.cmdChannel:
+		o.executeQueued(command)
+	case value := <-o.pollChannel:
+		o.readStatus(value)
+	case <-o.done:
+	}
+}
+
+func processor(handle *HyInverter) {
+	for !handle.stopped() {
+		handle.processNext()
+	}
+}
+
+// executeQueued executes a command taken from the queue unless it is held back by Pause.
+func (o *HyInverter) executeQueued(command queuedCommand) {
+	if o.holdCommand(command) {
+		return
+	}
+	o.runCommand(command)
+}
+
+// runCommand executes a command taken from the queue or held back, records its timestamps
+// and reports its result.
+func (o *HyInverter) runCommand(command queuedCommand) {
+	o.freeSpace()
+	if isDwell(lineWord{command.word, command.internal}) {
+		o.startDwell(command)
+		return
+	}
+	o.markProgress()
+	o.startAudit(command)
+	err := o.execute(command)
+	control, ok := controlCommand(strings.TrimSpace(strings.ToLower(command.word)))
+	if ok && err == nil && control&ControlRun != 0 {
+		// The speed is reached after the run command as well.
+		o.stateMutex.Lock()
+		o.speedPending = true
+		o.stateMutex.Unlock()
+	}
+	if o.finishAudit(err) && err == nil && command.results != nil && command.results.acknowledged {
+		err = ErrNotAcknowledged
+	}
+	o.commandIDs.finish(command.id)
+	command.results.send(CommandResult{ID: command.id, Command: command.word, Err: err})
+}
+
+// execute sends the VFD frame of a single control command. It returns why the command was
+// not sent or not acknowledged.
+func (o *HyInverter) execute(queued queuedCommand) error {
+	cmd := queued.word
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	// The word counts for QueueDepth until it was sent.
+	defer atomic.AddInt32(&o.commandQueue, -1)
+	if preempts(lineWord{cmd, queued.internal}) {
+		atomic.AddInt32(&o.preemptions, -1)
+	}
+	if o.ReadOnly() {
+		return ErrReadOnly
+	}
+	cmd = strings.TrimSpace(strings.ToLower(cmd))
+	if command, ok := controlCommand(cmd); ok && command == CommandStop {
+		err := o.sendStop()
+		o.setModalSpindle(command)
+		return err
+	} else if err := o.checkEStop(cmd); err != nil {
+		// Queued concurrently with EStop
+		return err
+	} else if err := o.checkReverse(cmd); err != nil {
+		// Queued before the lockout was enabled
+		return err
+	} else if jog, isJog := jogCommand(lineWord{cmd, queued.internal}); isJog {
+		// Jog, not restored after a reconnect
+		if err := o.checkReverseCommand(jog, cmd); err != nil {
+			// Queued before the lockout was enabled
+			return err
+		}
+		if o.jogEndedAfter(queued.enqueued) {
+			// The jog ended before its word was sent.
+			return nil
+		}
+		return o.currentDriver().jog(o.wiredDirection(jog))
+	} else if ok {
+		// Run forward or backward
+		if err := o.sendDeferredSpeed(command); err != nil {
+			return err
+		}
+		err := o.sendRun(command)
+		o.setModalSpindle(command)
+		return err
+	} else if queued.internal && strings.HasPrefix(cmd, hertzWordPrefix) {
+		return o.executeHertz(cmd)
+	} else if strings.HasPrefix(cmd, "s") {
+		return o.executeSpeed(cmd)
+	}
+	return nil
+}
+
+// executeSpeed executes an S word. It is deferred while the spindle is stopped if
+// SetDeferSpeed is enabled.
+func (o *HyInverter) executeSpeed(cmd string) error {
+	outputRpm, err := parseSpeed(cmd)
+	if err != nil {
+		o.emit(Event{Type: CommandRejected, Err: err})
+		return err
+	}
+	if o.deferSpeed(outputRpm) {
+		return nil
+	}
+	return o.sendSpeed(outputRpm)
+}
+
+// sendSpeed sends the frequency of a speed in RPM after applying the limits.
+func (o *HyInverter) sendSpeed(outputRpm float64) error {
+	requested := outputRpm
+	if minRpm, clamped := o.raiseToMinRpm(outputRpm); clamped {
+		frequency, _ := o.rpmToFrequency(minRpm)
+		o.emit(Event{Type: Clamped, Frequency: frequency, Rpm: saturateRpm(minRpm),
+			RequestedFrequency: o.requestedFrequency(outputRpm), RequestedRpm: saturateRpm(outputRpm)})
+		outputRpm = minRpm
+	}
+	if err := o.checkMaxRpm(outputRpm); err != nil {
+		// Queued before the action was changed
+		o.emit(Event{Type: CommandRejected, Err: err})
+		return err
+	}
+	if maxRpm, clamped := o.lowerToMaxRpm(outputRpm); clamped {
+		frequency, _ := o.rpmToFrequency(maxRpm)
+		o.emit(Event{Type: Clamped, Frequency: frequency, Rpm: saturateRpm(maxRpm),
+			RequestedFrequency: o.requestedFrequency(outputRpm), RequestedRpm: saturateRpm(outputRpm)})
+		outputRpm = maxRpm
+	}
+	inverterFrequency, saturated := o.rpmToFrequency(outputRpm)
+	if saturated {
+		o.emit(Event{Type: Saturated, Frequency: inverterFrequency, Rpm: o.frequencyToRpm(inverterFrequency),
+			RequestedFrequency: o.requestedFrequency(outputRpm), RequestedRpm: saturateRpm(outputRpm)})
+	}
+	o.setModalSpeed(requested)
+	if !o.rampTo(inverterFrequency) {
+		return nil
+	}
+	return o.sendFrequency(inverterFrequency)
+}
+
+// sendFrequency sends a set frequency command and keeps it for the echo check and reconnects.
+func (o *HyInverter) sendFrequency(inverterFrequency uint16) error {
+	o.pollMutex.Lock()
+	o.setFrequency = inverterFrequency
+	o.pollMutex.Unlock()
+	o.stateMutex.Lock()
+	o.frequencyCommanded = true
+	o.sentFrequency = inverterFrequency
+	o.frequencyConfirmed = false
+	o.clampChecked = false
+	o.speedPending = true
+	o.stateMutex.Unlock()
+	// Set frequency
+	frame := o.frequencyFrame(inverterFrequency)
+	o.stateMutex.Lock()
+	o.lastFrequencyFrame = frame
+	o.stateMutex.Unlock()
+	o.startRampLatency()
+	return o.currentDriver().SetFrequency(inverterFrequency)
+}
+
+// sendRun sends a run command and keeps its frame to restore the state after a reconnect.
+func (o *HyInverter) sendRun(command ControlCommand) error {
+	frame := o.controlFrame(command)
+	o.stateMutex.Lock()
+	o.lastControlFrame = frame
+	o.stateMutex.Unlock()
+	return o.currentDriver().Start(o.wiredDirection(command))
+}
+
+func outFrequencyRequester(handle *HyInverter, pollInterval time.Duration) {
+	for !handle.stopped() {
+		handle.waitForPoll(pollInterval)
+		handle.startPollCycle()
+		handle.requestStatus(handle.currentDriver().pollValues()...)
+		handle.requestStatus(pollCycleEnd)
+	}
+}
+
+func parser(handle *HyInverter) {
+	var modbusRtu []byte = make([]byte, 0)
+	lastData := handle.now()
+	rxBuf := make([]byte, handle.ReadOptions().BufferSize)
+	var timing rxTiming
+	for !handle.stopped() {
+		port := handle.currentPort()
+		n, err := port.Read(rxBuf)
+		read := handle.now()
+		if n > 0 && err == nil {
+			lastData = read
+			atomic.StoreInt32(&handle.portErrors, 0)
+			modbusRtu = append(modbusRtu, rxBuf[:n]...)
+			timing.add(n, read)
+			for {
+				var frame []byte
+				frame, modbusRtu = handle.nextFrame(modbusRtu)
+				if frame == nil {
+					break
+				}
+				if handle.StrictTiming() {
+					if err := timing.check(len(frame), len(modbusRtu), handle.BaudRate()); err != nil {
+						handle.timingViolated(frame, err)
+						continue
+					}
+				}
+				if classic, ok := handle.fromWire(frame); ok && parseModbusRTU(handle, classic) {
+					handle.logFrame(Received, frame, FrameOk)
+				} else {
+					handle.logFrame(Received, frame, FrameUnknown)
+				}
+			}
+			continue
+		}
+		// Incomplete frames are dropped after a silent interval.
+		handle.logFrame(Received, modbusRtu, FrameIncomplete)
+		modbusRtu = modbusRtu[:0]
+		timing.reset()
+		if err != nil && err != io.EOF {
+			// The port reports EOF if the inter character timeout elapsed without data.
+			if !handle.stopped() {
+				handle.setOffline(err)
+				handle.portFailed(port, err)
+			}
+			handle.sleep(time.Millisecond * 100)
+		} else if read.Sub(lastData) > handle.ReadTimeout() {
+			handle.setOffline(ErrReadTimeout)
+		}
+	}
+}
+
+// nextFrame searches buf for a complete message with a valid CRC. Bytes in front of it are
+// skipped. It returns nil and the remaining bytes if more data is required.
+// All messages have the format: address, function, data length, data, 2 byte CRC.
+// A candidate of full length with a wrong CRC counts as one CRC error, candidates starting
+// within it don't.
+func (o *HyInverter) nextFrame(buf []byte) (frame, rest []byte) {
+	registerMap := o.RegisterMap()
+	skip, corruptEnd := 0, 0
+	for ; len(buf)-skip >= 3; skip++ {
+		candidate := buf[skip:]
+		if candidate[0] != o.SlaveAddress() {
+			continue
+		}
+		length := registerMap.FrameLength(candidate)
+		if length == 0 {
+			continue
+		}
+		if len(candidate) < length {
+			break
+		}
+		signTest := o.signMessage(candidate[:length-2])
+		if signTest[length-2] == candidate[length-2] && signTest[length-1] == candidate[length-1] {
+			o.logFrame(Received, buf[:skip], FrameDiscarded)
+			return candidate[:length], candidate[length:]
+		}
+		if skip >= corruptEnd {
+			corruptEnd = skip + length
+			o.txStats.mutex.Lock()
+			o.txStats.stats.CRCErrors++
+			o.txStats.mutex.Unlock()
+		}
+	}
+	o.logFrame(Received, buf[:skip], FrameDiscarded)
+	return nil, buf[skip:]
+}
+
+// parseModbusRTU decodes a received message. It returns false if the message is invalid or unknown.
+func parseModbusRTU(handle *HyInverter, msg []byte) (decoded bool) {
+	if len(msg) < registers.FrameLength(0) || msg[0] != handle.SlaveAddress() {
+		return
+	}
+	signTest := handle.signMessage(msg[:len(msg)-2])
+	if signTest[len(msg)-2] != msg[len(msg)-2] || signTest[len(msg)-1] != msg[len(msg)-1] {
+		return
+	}
+	if len(msg) == registers.FrameLength(ReadStatusDataLength) && Function(msg[1]) == FunctionReadStatus && msg[2] == ReadStatusDataLength && msg[3] < statusValueCount {
+		// Read control status
+		// 0x01 0x04 0x03 <status value> <data high> <data low> <crc low> <crc high>
+		value := binary.BigEndian.Uint16(msg[4:6])
+		handle.pollMutex.Lock()
+		handle.status[msg[3]] = value
+		handle.statusReceived[msg[3]]++
+		if StatusValue(msg[3]) == StatusOutputFrequency {
+			handle.outputFrequency = value
+			handle.outputRpm = handle.frequencyToRpm(value)
+		}
+		handle.pollMutex.Unlock()
+		if StatusValue(msg[3]) == StatusSetFrequency {
+			handle.checkSetFrequency(value)
+		}
+		if StatusValue(msg[3]) == StatusTemperature {
+			handle.checkTemperature(float64(value))
+		}
+		if StatusValue(msg[3]) == StatusOutputFrequency {
+			handle.measureRampLatency(value)
+			handle.checkSpeedReached(value)
+			handle.countUsage(value, handle.now())
+		}
+	} else if len(msg) == registers.FrameLength(SetFrequencyDataLength) && Function(msg[1]) == FunctionSetFrequency && msg[2] == SetFrequencyDataLength {
+		// Set frequency echo
+		// 0x01 0x05 0x02 <frequency high> <frequency low> <crc low> <crc high>
+		handle.checkEcho(binary.BigEndian.Uint16(msg[3:5]))
+	} else if len(msg) == registers.FrameLength(ReadParameterDataLength) && (Function(msg[1]) == FunctionReadParameter || Function(msg[1]) == FunctionWriteParameter) && msg[2] == ReadParameterDataLength {
+		// Read parameter or write parameter echo
+		// 0x01 0x01 0x03 <parameter> <data high> <data low> <crc low> <crc high>
+		handle.parameterAnswered(Function(msg[1]), msg[3], binary.BigEndian.Uint16(msg[4:6]))
+	} else if len(msg) == registers.FrameLength(ControlDataLength) && Function(msg[1]) == FunctionControl && msg[2] == ControlDataLength {
+		// Control command acknowledgment
+		// 0x01 0x03 0x01 <status> <crc low> <crc high>
+		atomic.AddUint32(&handle.controlAcks, 1)
+		handle.controlAnswered(ControlStatus(msg[3]))
+	} else {
+		return
+	}
+	received := handle.now()
+	handle.pollMutex.Lock()
+	handle.lastReceived = received
+	handle.pollMutex.Unlock()
+	handle.markAnswered(Function(msg[1]))
+	handle.setOnline()
+	return true
+}
+
+// AcceptedFrequency returns the frequency echoed by the VFD for the last S command and
+// whether it equals the sent frequency. Some drives echo a value truncated to their
+// PD limits instead of the requested one.
+func (o *HyInverter) AcceptedFrequency() (frequency uint16, confirmed bool) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.acceptedFrequency, o.frequencyConfirmed
+}
+
+// TargetFrequency returns the frequency of the last S command sent to the VFD in 0.01 Hz,
+// after the limits and the software ramp were applied. Use it with OutputFrequency to show the
+// commanded and the actual speed.
+func (o *HyInverter) TargetFrequency() uint16 {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	return o.setFrequency
+}
+
+// TargetRpm returns TargetFrequency converted to RPM, see OutputRpm.
+func (o *HyInverter) TargetRpm() uint16 {
+	return o.frequencyToRpm(o.TargetFrequency())
+}
+
+// OutputFrequency returns the raw value from the VFD in 0.01 Hz, see OutputHertz.
+// Please also check Online() to see if the value is valid.
+func (o *HyInverter) OutputFrequency() uint16 {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	return o.outputFrequency
+}
+
+// OutputRpm returns the converted output frequency (rpm := output_frequency / rpm-to-hertz).
+// Please also check Online() to see if the value is valid.
+func (o *HyInverter) OutputRpm() uint16 {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	return o.outputRpm
+}
+
+// Online returns true if the last message of the VFD was received within the threshold set
+// by SetOnlineThreshold.
+func (o *HyInverter) Online() bool {
+	threshold := o.OnlineThreshold()
+	return o.now().Sub(o.LastSeen()) < threshold
+}
+
+// LastSeen returns the time of the last valid message of the VFD, zero if none was received.
+func (o *HyInverter) LastSeen() time.Time {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	return o.lastReceived
+}
+
+// SetOnlineThreshold sets the time after the last received message for which Online
+// returns true. 0 selects the default of two poll intervals. See WithOnlineThreshold.
+func (o *HyInverter) SetOnlineThreshold(threshold time.Duration) {
+	o.stateMutex.Lock()
+	o.onlineThreshold = threshold
+	o.stateMutex.Unlock()
+}
+
+// OnlineThreshold returns the threshold of Online.
+func (o *HyInverter) OnlineThreshold() time.Duration {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.onlineThresholdLocked()
+}
+
+// onlineThresholdLocked returns the threshold of Online. The caller holds stateMutex.
+func (o *HyInverter) onlineThresholdLocked() time.Duration {
+	if o.onlineThreshold == 0 {
+		return time.Duration(2 * o.pollIntervalSec * float64(time.Second))
+	}
+	return o.onlineThreshold
+}
+
+// SetReadTimeout sets the time without any received data after which the VFD is reported
+// offline and LastError returns ErrReadTimeout. Default: two poll intervals.
+func (o *HyInverter) SetReadTimeout(timeout time.Duration) {
+	o.stateMutex.Lock()
+	o.readTimeout = timeout
+	o.stateMutex.Unlock()
+}
+
+// ReadTimeout returns the time without received data after which the VFD is reported offline.
+func (o *HyInverter) ReadTimeout() time.Duration {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if o.readTimeout == 0 {
+		return time.Duration(2 * o.pollIntervalSec * float64(time.Second))
+	}
+	return o.readTimeout
+}
+
+// LastError returns the reason why the connection was reported offline, e.g. ErrReadTimeout
+// or an error of the serial port. It is reset to nil as soon as a valid message is received.
+func (o *HyInverter) LastError() error {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.lastError
+}
+
+// setOffline records a read failure and raises an Offline event on the first one.
+func (o *HyInverter) setOffline(err error) {
+	o.stateMutex.Lock()
+	wasOffline := o.offline
+	o.offline = true
+	o.lastError = err
+	o.stateMutex.Unlock()
+	if !wasOffline {
+		o.emit(Event{Type: Offline, Err: err})
+	}
+}
+
+// setOnline clears the read failure and raises an Online event if the VFD was offline.
+func (o *HyInverter) setOnline() {
+	o.stateMutex.Lock()
+	wasOffline := o.offline
+	o.offline = false
+	o.lastError = nil
+	o.stateMutex.Unlock()
+	if wasOffline {
+		o.emit(Event{Type: Online})
+	}
+}
+
+// SetBaudRate sets the baud rate used by Open. It has to match PD164 of the VFD. Default: 9600.
+func (o *HyInverter) SetBaudRate(baudRate uint) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	o.baudRate = baudRate
+}
+
+// BaudRate returns the baud rate used by Open.
+func (o *HyInverter) BaudRate() uint {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if o.baudRate == 0 {
+		return 9600
+	}
+	return o.baudRate
+}
+
+// ReadOptions configures how the serial port is read. MinimumReadSize and InterCharacterTimeout
+// are passed to go-serial, see serial.OpenOptions for the exact semantics.
+type ReadOptions struct {
+	// BufferSize is the number of bytes requested per read. Default: 64.
+	BufferSize int
+	// MinimumReadSize is the number of bytes a read waits for. Default: 0.
+	// If it is not 0, a silent VFD blocks the reads and is not reported offline.
+	MinimumReadSize uint
+	// InterCharacterTimeout ends a read after a silent interval. Most systems round it to
+	// multiples of 100 ms. Default: 100 ms.
+	InterCharacterTimeout time.Duration
+}
+
+// SetReadOptions changes the read settings used by Open.
+func (o *HyInverter) SetReadOptions(opts ReadOptions) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	o.readOptions = opts
+}
+
+// ReadOptions returns the read settings used by Open, with defaults applied.
+func (o *HyInverter) ReadOptions() ReadOptions {
+	o.stateMutex.Lock()
+	opts := o.readOptions
+	o.stateMutex.Unlock()
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+	if opts.InterCharacterTimeout == 0 && opts.MinimumReadSize == 0 {
+		opts.InterCharacterTimeout = 100 * time.Millisecond
+	}
+	return opts
+}
+
+// SetSlaveAddress sets the RS485 address of the VFD. It has to match PD163. Default: 1.
+func (o *HyInverter) SetSlaveAddress(address byte) {
+	o.stateMutex.Lock()
+	o.slaveAddress = address
+	o.stateMutex.Unlock()
+}
+
+// SlaveAddress returns the RS485 address of the VFD.
+func (o *HyInverter) SlaveAddress() byte {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if o.slaveAddress == 0 {
+		return 1
+	}
+	return o.slaveAddress
+}
+
+// Processed returns true if all commands were processed and
+// the output frequency is within 10% of the set frequency.
+// Use AtSpeed for a different tolerance and WaitProcessed instead of polling it.
+func (o *HyInverter) Processed() (processed, outputFrequencyOk, commandsProcessed bool) {
+	outputFrequencyOk = o.outputWithin(DefaultAtSpeedTolerance)
+	if atomic.LoadInt32(&o.commandQueue) == 0 {
+		commandsProcessed = true
+	}
+	processed = outputFrequencyOk && commandsProcessed
+	return
+}
+
+// signMessage returns a copy of data with the CRC appended. The capacity of data is
+// limited so the CRC of a received message never overwrites the received CRC.
+func (o *HyInverter) signMessage(data []byte) []byte {
+	crc := crc16.Checksum(crc16.Modbus, data)
+	return append(data[:len(data):len(data)], byte(crc), byte(crc>>8))
+}
@@ -0,0 +1,473 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestModbusCrc16(t *testing.T) {
+	hy := &HyInverter{}
+	msg := hy.signMessage([]byte{0x01, 0x03, 0x01, 0x08})
+	if len(msg) != 6 {
+		t.FailNow()
+	}
+	if msg[4] != 0xF1 {
+		t.FailNow()
+	}
+	if msg[5] != 0x8E {
+		t.FailNow()
+	}
+}
+
+// testPort records all frames written by the processor. Reads return io.EOF like a
+// serial port whose inter character timeout elapsed.
+type testPort struct {
+	mutex  sync.Mutex
+	tx, rx bytes.Buffer
+}
+
+func (p *testPort) Read(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.rx.Read(b)
+}
+
+func (p *testPort) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.tx.Write(b)
+}
+
+func (p *testPort) Close() error {
+	return nil
+}
+
+// Bytes returns all written data.
+func (p *testPort) Bytes() []byte {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]byte(nil), p.tx.Bytes()...)
+}
+
+// Reply queues data returned by the next reads.
+func (p *testPort) Reply(b []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.rx.Write(b)
+}
+
+func newTestInverter() (*HyInverter, *testPort) {
+	port := &testPort{}
+	hy := &HyInverter{
+		port:            port,
+		frequencyPerRpm: 3.47222,
+		cmdChannel:      make(chan queuedCommand, DefaultQueueSize),
+		pollChannel:     make(chan StatusValue, pollValueCount),
+		lifecycle:       opened,
+	}
+	return hy, port
+}
+
+func TestControlCommandsPreemptPolls(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.requestStatus(StatusOutputFrequency, StatusOutputCurrent)
+	hy.requestStatus(StatusOutputFrequency)
+	if len(hy.pollChannel) != 2 {
+		t.Fatalf("expected two pending status requests, got %d", len(hy.pollChannel))
+	}
+	if !hy.GCode("M3 S300") {
+		t.FailNow()
+	}
+	for i := 0; i < 4; i++ {
+		hy.processNext()
+	}
+	frames := port.Bytes()
+	// M3 and S300 are sent first (6 and 7 bytes), followed by the status reads (8 bytes each).
+	if len(frames) != 6+7+8+8 {
+		t.Fatalf("unexpected frame data % X", frames)
+	}
+	if frames[1] != 0x03 || frames[6+1] != 0x05 {
+		t.Fatalf("control commands were not sent first: % X", frames)
+	}
+	if frames[13+3] != byte(StatusOutputFrequency) || frames[21+3] != byte(StatusOutputCurrent) {
+		t.Fatalf("status reads out of order: % X", frames)
+	}
+	if _, _, commandsProcessed := hy.Processed(); !commandsProcessed {
+		t.Fatal("command queue counter not drained")
+	}
+}
+
+func TestParseStatusResponse(t *testing.T) {
+	hy, _ := newTestInverter()
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputCurrent), 0x00, 0x2A}))
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x9C, 0x40}))
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusACVoltage), 0x08, 0x98}))
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusDCVoltage), 0x0C, 0x26}))
+	if hy.RawStatus(StatusOutputCurrent) != 42 {
+		t.Fatalf("unexpected current %d", hy.RawStatus(StatusOutputCurrent))
+	}
+	if hy.OutputCurrentAmps() != 4.2 {
+		t.Fatalf("unexpected current %v A", hy.OutputCurrentAmps())
+	}
+	if hy.OutputVoltage() != 220 {
+		t.Fatalf("unexpected output voltage %v V", hy.OutputVoltage())
+	}
+	if hy.DCBusVoltage() != 311 {
+		t.Fatalf("unexpected DC bus voltage %v V", hy.DCBusVoltage())
+	}
+	if hy.OutputFrequency() != 40000 || hy.OutputRpm() != 11520 {
+		t.Fatalf("unexpected output frequency %d / rpm %d", hy.OutputFrequency(), hy.OutputRpm())
+	}
+}
+
+func TestExternalChangeEvent(t *testing.T) {
+	hy, _ := newTestInverter()
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	report := func(frequency uint16) {
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusSetFrequency), byte(frequency >> 8), byte(frequency)}))
+	}
+	report(5000) // nothing commanded yet
+	hy.GCode("S300")
+	hy.processNext()
+	report(1042)
+	report(20000)
+	report(20000)
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %v", events)
+	}
+	if events[0].Type != ExternalChange || events[0].Frequency != 20000 || events[0].Rpm != 5760 {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetReadTimeout(30 * time.Millisecond)
+	type observation struct {
+		event     Event
+		lastError error
+	}
+	observations := make(chan observation, 10)
+	hy.Subscribe(func(e Event) { observations <- observation{e, hy.LastError()} })
+	go parser(hy)
+	defer func() { atomic.StoreInt32(&hy.stop, 1) }()
+	select {
+	case o := <-observations:
+		if o.event.Type != Offline || o.event.Err != ErrReadTimeout || o.lastError != ErrReadTimeout {
+			t.Fatalf("unexpected event %+v", o)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no Offline event")
+	}
+	port.Reply(hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	select {
+	case o := <-observations:
+		if o.event.Type != Online || o.lastError != nil {
+			t.Fatalf("unexpected event %+v", o)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no Online event")
+	}
+}
+
+func TestReadTimeoutClock(t *testing.T) {
+	hy, _ := newTestInverter()
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	hy.SetClock(clock)
+	hy.SetReadTimeout(time.Second)
+	events := make(chan Event, 10)
+	hy.Subscribe(func(e Event) { events <- e })
+	go parser(hy)
+	defer func() { atomic.StoreInt32(&hy.stop, 1) }()
+	// The timeout is measured on the clock, not in real time.
+	time.Sleep(50 * time.Millisecond)
+	if len(events) != 0 {
+		t.Fatalf("offline before the clock advanced: %+v", <-events)
+	}
+	timeout := start.Add(1001 * time.Millisecond)
+	clock.Set(timeout)
+	select {
+	case e := <-events:
+		if e.Type != Offline || e.Err != ErrReadTimeout || !e.Time.Equal(timeout) {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no Offline event")
+	}
+}
+
+func TestParseRejectsBadCrc(t *testing.T) {
+	hy, _ := newTestInverter()
+	msg := make([]byte, 0, 10)
+	msg = append(msg, 0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x9C, 0x40, 0x00, 0x00)
+	parseModbusRTU(hy, msg)
+	if hy.OutputFrequency() != 0 {
+		t.Fatal("message with bad CRC accepted")
+	}
+}
+
+func TestNextFrame(t *testing.T) {
+	hy, _ := newTestInverter()
+	status := hy.signMessage([]byte{0x01, 0x04, 0x03, 0x01, 0x9C, 0x40})
+	control := hy.signMessage([]byte{0x01, 0x03, 0x01, 0x01})
+	frequency := hy.signMessage([]byte{0x01, 0x05, 0x02, 0x9C, 0x40})
+	var buf []byte
+	buf = append(buf, 0xFF, 0x00) // noise
+	buf = append(buf, status...)
+	buf = append(buf, 0x01, 0x04, 0x03, 0x01, 0x03, 0x01, 0x12, 0x34) // bad CRC, a control frame at offset 3
+	buf = append(buf, control...)
+	buf = append(buf, frequency...)
+	buf = append(buf, status[:5]...) // incomplete
+	var frames [][]byte
+	for {
+		var frame []byte
+		frame, buf = hy.nextFrame(buf)
+		if frame == nil {
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) != 3 || !bytes.Equal(frames[0], status) || !bytes.Equal(frames[1], control) || !bytes.Equal(frames[2], frequency) {
+		t.Fatalf("unexpected frames % X", frames)
+	}
+	if !bytes.Equal(buf, status[:5]) {
+		t.Fatalf("unexpected rest % X", buf)
+	}
+	if crcErrors := hy.Stats().CRCErrors; crcErrors != 1 {
+		t.Errorf("expected 1 CRC error, got %d", crcErrors)
+	}
+}
+
+func TestFrequencyEcho(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.GCode("S11520")
+	hy.processNext()
+	sent := port.Bytes()
+	if len(sent) != 7 || sent[1] != 0x05 {
+		t.Fatalf("unexpected frame % X", sent)
+	}
+	if _, confirmed := hy.AcceptedFrequency(); confirmed {
+		t.Fatal("confirmed without echo")
+	}
+	parseModbusRTU(hy, sent)
+	if frequency, confirmed := hy.AcceptedFrequency(); frequency != uint16(sent[3])<<8|uint16(sent[4]) || !confirmed {
+		t.Fatalf("echo not confirmed: %d", frequency)
+	}
+	// A drive limited to 300 Hz
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x05, 0x02, 0x75, 0x30}))
+	if frequency, confirmed := hy.AcceptedFrequency(); frequency != 30000 || confirmed {
+		t.Fatalf("mismatch not detected: %d", frequency)
+	}
+}
+
+func TestClampedEvent(t *testing.T) {
+	hy, _ := newTestInverter()
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	// Echo of a drive limited to 300 Hz
+	hy.GCode("S11520")
+	hy.processNext()
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x05, 0x02, 0x75, 0x30}))
+	// The readback shows the same value and must not be reported as front panel change.
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusSetFrequency), 0x75, 0x30}))
+	// A drive which does not echo: detected by the readback.
+	hy.GCode("S11000")
+	hy.processNext()
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusSetFrequency), 0x75, 0x30}))
+	if len(events) != 2 {
+		t.Fatalf("expected two events, got %+v", events)
+	}
+	for _, e := range events {
+		if e.Type != Clamped || e.Frequency != 30000 || e.Rpm != 8640 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if events[0].RequestedRpm != 11520 || events[1].RequestedRpm != 11000 {
+		t.Errorf("unexpected requested RPM %d, %d", events[0].RequestedRpm, events[1].RequestedRpm)
+	}
+}
+
+func TestSaturatedFrequency(t *testing.T) {
+	hy, port := newTestInverter()
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.GCode("S70000")
+	hy.processNext()
+	sent := port.Bytes()
+	if len(sent) != 7 || sent[3] != 0xFF || sent[4] != 0xFF {
+		t.Fatalf("expected saturated frequency, got % X", sent)
+	}
+	if len(events) != 1 || events[0].Type != Saturated || events[0].RequestedRpm != 65535 || events[0].Rpm != 18874 ||
+		events[0].RequestedFrequency != 243055 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestConversionRounding(t *testing.T) {
+	hy, _ := newTestInverter()
+	tests := []struct {
+		rpm       float64
+		frequency uint16
+		saturated bool
+	}{{11520, 40000, false}, {300, 1042, false}, {0, 0, false}, {-5, 0, false}, {18874, 65535, false}, {18875, 65535, true}}
+	for _, test := range tests {
+		if frequency, saturated := hy.rpmToFrequency(test.rpm); frequency != test.frequency || saturated != test.saturated {
+			t.Errorf("%v RPM: expected %d/%v, got %d/%v", test.rpm, test.frequency, test.saturated, frequency, saturated)
+		}
+	}
+	if rpm := hy.frequencyToRpm(40000); rpm != 11520 {
+		t.Errorf("expected 11520 RPM, got %d", rpm)
+	}
+	hy.frequencyPerRpm = 0
+	if rpm := hy.frequencyToRpm(40000); rpm != 0 {
+		t.Errorf("expected 0 RPM for an invalid factor, got %d", rpm)
+	}
+}
+
+func TestHertzConversion(t *testing.T) {
+	tests := []struct {
+		hertz     float64
+		frequency uint16
+		saturated bool
+	}{{400, 40000, false}, {287.505, 28751, false}, {0.004, 0, false}, {-1, 0, false}, {655.35, 65535, false}, {655.36, 65535, true}}
+	for _, test := range tests {
+		if frequency, saturated := HertzToFrequency(test.hertz); frequency != test.frequency || saturated != test.saturated {
+			t.Errorf("%v Hz: expected %d/%v, got %d/%v", test.hertz, test.frequency, test.saturated, frequency, saturated)
+		}
+	}
+	if hertz := FrequencyToHertz(28751); math.Abs(hertz-287.51) > 1e-9 {
+		t.Errorf("expected 287.51 Hz, got %v", hertz)
+	}
+	hy, _ := newTestInverter()
+	hy.outputFrequency = 10417
+	if hertz := hy.OutputHertz(); math.Abs(hertz-104.17) > 1e-9 {
+		t.Errorf("expected 104.17 Hz, got %v", hertz)
+	}
+}
+
+func TestOpenState(t *testing.T) {
+	for _, state := range []OpenState{LeaveOnOpen, StopOnOpen} {
+		port := &testPort{}
+		hy := NewVfd()
+		hy.SetOpenState(state)
+		hy.SetStopEscalation(0, nil)
+		// A drive which does not answer the PD005 read keeps the configured maximum.
+		if err := hy.OpenPort(port, WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second)); err != nil || hy.MaxRpm() != 11520 {
+			t.Fatalf("open failed: %v, max RPM %d", err, hy.MaxRpm())
+		}
+		time.Sleep(300 * time.Millisecond)
+		hy.Close()
+		// Open reads PD005 first, the test port does not answer.
+		sent := port.Bytes()
+		if len(sent) < 8 || sent[1] != byte(FunctionReadParameter) || sent[3] != ParameterMaxFrequency {
+			t.Fatalf("PD005 not read: % X", sent)
+		}
+		sent = sent[8:]
+		if state == LeaveOnOpen && len(sent) != 0 {
+			t.Errorf("LeaveOnOpen: unexpected frames % X", sent)
+		}
+		if state == StopOnOpen && (len(sent) != 13 || sent[1] != 0x03 || sent[3] != 0x08 || sent[7] != 0x05 || sent[9] != 0 || sent[10] != 0) {
+			t.Errorf("StopOnOpen: unexpected frames % X", sent)
+		}
+	}
+}
+
+func TestHighTemperatureEvent(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetTemperatureLimit(60)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	for _, temperature := range []byte{45, 61, 65, 59, 62} {
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusTemperature), 0x00, temperature}))
+	}
+	if hy.Temperature() != 62 {
+		t.Fatalf("unexpected temperature %v", hy.Temperature())
+	}
+	if len(events) != 2 || events[0].Type != HighTemperature || events[0].Temperature != 61 || events[1].Temperature != 62 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	hy, _ := newTestInverter()
+	if hy.QueueDepth() != 0 || hy.QueueCapacity() != DefaultQueueSize {
+		t.Fatalf("unexpected depth %d and capacity %d", hy.QueueDepth(), hy.QueueCapacity())
+	}
+	if err := hy.Enqueue("M3 S300 ?"); err != nil {
+		t.Fatal(err)
+	}
+	if depth := hy.QueueDepth(); depth != 2 {
+		t.Fatalf("expected 2 queued words, got %d", depth)
+	}
+	for hy.QueueCapacity()-hy.QueueDepth() > 0 {
+		if err := hy.Enqueue("S300"); err != nil {
+			t.Fatalf("queue full at depth %d: %v", hy.QueueDepth(), err)
+		}
+	}
+	if err := hy.Enqueue("S300"); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	hy.processNext()
+	if depth := hy.QueueDepth(); depth != hy.QueueCapacity()-1 {
+		t.Errorf("expected %d queued words after sending one, got %d", hy.QueueCapacity()-1, depth)
+	}
+
+	// The word being sent is included
+	hy, _ = newTestInverter()
+	hy.maxRpm = 12000
+	sending := -1
+	hy.Subscribe(func(e Event) {
+		if e.Type == Clamped {
+			sending = hy.QueueDepth()
+		}
+	})
+	hy.GCode("S99999")
+	hy.processNext()
+	if sending != 1 || hy.QueueDepth() != 0 {
+		t.Errorf("expected depth 1 while sending and 0 after, got %d and %d", sending, hy.QueueDepth())
+	}
+}
+
+func TestTargetRpm(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 24000
+	if hy.TargetRpm() != 0 {
+		t.Fatalf("target %d before an S command", hy.TargetRpm())
+	}
+	hy.GCode("S12000")
+	hy.processNext()
+	frequency, _ := hy.rpmToFrequency(12000)
+	if hy.TargetFrequency() != frequency || hy.TargetRpm() != hy.frequencyToRpm(frequency) {
+		t.Errorf("expected %d (%d RPM), got %d (%d RPM)", frequency, hy.frequencyToRpm(frequency), hy.TargetFrequency(), hy.TargetRpm())
+	}
+}
+
+func TestOnlineThreshold(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.pollIntervalSec = 1
+	if hy.Online() || !hy.LastSeen().IsZero() {
+		t.Fatal("online before a message was received")
+	}
+	if hy.OnlineThreshold() != 2*time.Second {
+		t.Fatalf("expected a default of two poll intervals, got %v", hy.OnlineThreshold())
+	}
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, byte(ControlStatusRunning)}))
+	if !hy.Online() || time.Since(hy.LastSeen()) > time.Second {
+		t.Fatalf("offline after a message, last seen %v", hy.LastSeen())
+	}
+	hy.SetOnlineThreshold(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if hy.Online() || hy.Status().Online {
+		t.Error("online after the threshold")
+	}
+}
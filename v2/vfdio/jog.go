@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"strconv"
+	"time"
+)
+
+// Direction is the sense of rotation of the spindle.
+type Direction int
+
+const (
+	// Forward is the direction of M3.
+	Forward Direction = iota
+	// Backward is the direction of M4.
+	Backward
+)
+
+// JogTimeout is the time a jog lasts without being refreshed by another call of Jog.
+const JogTimeout = 500 * time.Millisecond
+
+// Internal command words queued by Jog, see lineWord.
+const (
+	jogForwardWord  = "jog-forward"
+	jogBackwardWord = "jog-backward"
+)
+
+// Jog runs the spindle like a held jog key: it turns as long as Jog is called again within
+// JogTimeout and stops afterwards. The speed and jog command are only sent for the first call
+// or if direction or speed changed, repeated calls just extend the jog. Depending on the drive,
+// the jog frequency parameter may be used instead of rpm. A jog is not restored after a reconnect.
+// Returns false if the command queue is full or the connection is not open.
+func (o *HyInverter) Jog(direction Direction, rpm uint16) bool {
+	o.stateMutex.Lock()
+	changed := o.jogTimer == nil || o.jogDirection != direction || o.jogRpm != rpm
+	o.stateMutex.Unlock()
+	if changed {
+		jog := lineWord{text: jogForwardWord, internal: true}
+		if direction == Backward {
+			jog.text = jogBackwardWord
+		}
+		if o.enqueueWords(lineWord{text: "s" + strconv.Itoa(int(rpm))}, jog) != nil {
+			return false
+		}
+	} else {
+		o.Keepalive()
+	}
+	// The jog is recorded after its words were queued, a rejected jog leaves no state behind.
+	o.stateMutex.Lock()
+	if o.jogTimer == nil {
+		o.jogTimer = o.clockLocked().AfterFunc(JogTimeout, o.endJog)
+	}
+	o.jogDirection, o.jogRpm = direction, rpm
+	o.jogRefreshed = o.clockLocked().Now()
+	o.stateMutex.Unlock()
+	return true
+}
+
+// endJog stops the spindle if the jog was not refreshed in time. The stop is sent directly
+// and escalated by sendStop, so a full or held back queue can't keep the spindle turning.
+func (o *HyInverter) endJog() {
+	o.stateMutex.Lock()
+	if remaining := JogTimeout - o.clockLocked().Now().Sub(o.jogRefreshed); remaining > 0 {
+		o.jogTimer.Reset(remaining)
+		o.stateMutex.Unlock()
+		return
+	}
+	o.jogTimer = nil
+	o.jogEnded = o.clockLocked().Now()
+	o.stateMutex.Unlock()
+	if o.checkOpen() != nil || o.ReadOnly() {
+		return
+	}
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	o.sendStop()
+	o.setModalSpindle(CommandStop)
+}
+
+// jogEndedAfter returns true if a jog timed out after the time, e.g. while its jog word
+// was queued. The word may be sent before Jog armed the timer.
+func (o *HyInverter) jogEndedAfter(t time.Time) bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.jogEnded.After(t)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJog(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetStopEscalation(0, nil)
+	for i := 0; i < 5; i++ {
+		if !hy.Jog(Backward, 3000) {
+			t.Fatal("jog not queued")
+		}
+		time.Sleep(JogTimeout / 2)
+	}
+	// Refreshing the jog queues nothing, so it ends after the timeout.
+	if len(hy.cmdChannel) != 2 {
+		t.Fatalf("expected speed and jog command, got %d", len(hy.cmdChannel))
+	}
+	if cmd := (<-hy.cmdChannel).word; cmd != "s3000" {
+		t.Fatalf("unexpected speed %q", cmd)
+	}
+	if cmd := (<-hy.cmdChannel).word; cmd != jogBackwardWord {
+		t.Fatalf("unexpected jog %q", cmd)
+	}
+	time.Sleep(JogTimeout + 200*time.Millisecond)
+	if len(hy.cmdChannel) != 0 || !bytes.Equal(port.Bytes(), hy.controlFrame(CommandStop)) {
+		t.Fatalf("jog not stopped after the timeout: % X", port.Bytes())
+	}
+
+	// A jog word sent after the jog ended is skipped.
+	hy.Jog(Forward, 3000)
+	hy.stateMutex.Lock()
+	hy.jogTimer.Stop()
+	hy.jogTimer = nil
+	hy.jogEnded = time.Now()
+	hy.stateMutex.Unlock()
+	hy.processNext()
+	sent := len(port.Bytes())
+	hy.processNext()
+	if len(port.Bytes()) != sent {
+		t.Fatalf("jog started after it ended: % X", port.Bytes()[sent:])
+	}
+
+	// A jog word sent before the timer was armed starts the jog.
+	hy.Jog(Backward, 3000)
+	hy.processNext()
+	sent = len(port.Bytes())
+	hy.processNext()
+	if frames := port.Bytes()[sent:]; !bytes.Equal(frames, hy.controlFrame(ControlJogReverse)) {
+		t.Fatalf("unexpected jog frame % X", frames)
+	}
+}
+
+func TestJogRejected(t *testing.T) {
+	hy, _ := newTestInverter()
+	for hy.GCode("S3000") {
+	}
+	if hy.Jog(Forward, 3000) {
+		t.Fatal("jog accepted with a full queue")
+	}
+	hy.stateMutex.Lock()
+	armed := hy.jogTimer != nil
+	hy.stateMutex.Unlock()
+	if armed {
+		t.Fatal("jog timer armed for a rejected jog")
+	}
+
+	// Jog words are internal, the caller can't send them.
+	hy, _ = newTestInverter()
+	hy.SetStrictWords(true)
+	if err := hy.Enqueue(jogForwardWord); !errors.Is(err, ErrUnsupportedWord) {
+		t.Fatalf("expected ErrUnsupportedWord, got %v", err)
+	}
+	hy.SetStrictWords(false)
+	if frames, err := hy.EncodeCommand(jogBackwardWord); err != nil || len(frames) != 0 {
+		t.Fatalf("jog word of the caller encoded: % X, %v", frames, err)
+	}
+	hy.GCode(jogForwardWord)
+	hy.processNext()
+	if hy.jogTimer != nil || hy.lastControlFrame != nil {
+		t.Fatal("jog word of the caller executed")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrKeepaliveExpired is the result of commands discarded because the keepalive window
+// expired, see SetKeepalive and EnqueueResults.
+var ErrKeepaliveExpired = errors.New("vfdio: keepalive expired")
+
+// SetKeepalive enables the host watchdog: if the application neither calls Keepalive nor
+// queues a command within the window, the queued commands are discarded, the spindle is
+// stopped with M5 and a KeepaliveExpired event is raised. It protects against a crashed host
+// leaving the spindle running. After an expiry, the watchdog restarts with the next call.
+// GCode, Enqueue, Jog and StreamProgram count as calls. A window of 0 disables the watchdog.
+// Default: 0.
+func (o *HyInverter) SetKeepalive(window time.Duration) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	o.keepaliveWindow = window
+	o.keepaliveAt = o.clockLocked().Now()
+	if o.keepaliveTimer != nil {
+		o.keepaliveTimer.Stop()
+		o.keepaliveTimer = nil
+	}
+	if window > 0 {
+		o.keepaliveTimer = o.clockLocked().AfterFunc(window, o.keepaliveExpired)
+	}
+}
+
+// KeepaliveWindow returns the window set by SetKeepalive.
+func (o *HyInverter) KeepaliveWindow() time.Duration {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.keepaliveWindow
+}
+
+// Keepalive signals that the application is alive, see SetKeepalive.
+func (o *HyInverter) Keepalive() {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	o.keepaliveAt = o.clockLocked().Now()
+	if o.keepaliveWindow > 0 && o.keepaliveTimer == nil {
+		o.keepaliveTimer = o.clockLocked().AfterFunc(o.keepaliveWindow, o.keepaliveExpired)
+	}
+}
+
+// keepaliveExpired stops the spindle if Keepalive was not called in time.
+func (o *HyInverter) keepaliveExpired() {
+	o.stateMutex.Lock()
+	if o.keepaliveTimer == nil {
+		o.stateMutex.Unlock()
+		return
+	}
+	if remaining := o.keepaliveWindow - o.clockLocked().Now().Sub(o.keepaliveAt); remaining > 0 {
+		o.keepaliveTimer.Reset(remaining)
+		o.stateMutex.Unlock()
+		return
+	}
+	o.keepaliveTimer = nil
+	o.stateMutex.Unlock()
+	if o.checkOpen() != nil {
+		return
+	}
+	// The commands of a crashed host must not run after the stop.
+	o.flushCommands(ErrKeepaliveExpired)
+	o.queue(lineWord{text: "M5"}, nil)
+	o.emit(Event{Type: KeepaliveExpired})
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepalive(t *testing.T) {
+	hy, _ := newTestInverter()
+	var expired int32
+	hy.Subscribe(func(e Event) {
+		if e.Type == KeepaliveExpired {
+			atomic.AddInt32(&expired, 1)
+		}
+	})
+	hy.SetKeepalive(50 * time.Millisecond)
+	defer hy.SetKeepalive(0)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		hy.Keepalive()
+	}
+	if atomic.LoadInt32(&expired) != 0 {
+		t.Fatal("expired although refreshed")
+	}
+	hy.GCode("S1000 M3")
+	time.Sleep(150 * time.Millisecond)
+	if atomic.LoadInt32(&expired) != 1 {
+		t.Fatalf("expired %d times", atomic.LoadInt32(&expired))
+	}
+	if len(hy.cmdChannel) != 1 || (<-hy.cmdChannel).word != "M5" {
+		t.Fatal("spindle not stopped")
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Errors of the life cycle. All public methods are safe to call concurrently and in any
+// order. Methods which need an open connection return ErrNotOpen before Open and
+// ErrClosed after Close, or false if they return a bool.
+var (
+	ErrNotOpen     = errors.New("vfdio: not open")
+	ErrAlreadyOpen = errors.New("vfdio: already open")
+	ErrClosed      = errors.New("vfdio: closed")
+	ErrQueueFull   = errors.New("vfdio: command queue full")
+)
+
+type lifecycle int
+
+const (
+	notOpened lifecycle = iota
+	opened
+	closed
+)
+
+// stateError returns nil if the connection is open. The caller holds lifecycleMutex.
+func (o *HyInverter) stateError() error {
+	switch o.lifecycle {
+	case notOpened:
+		return ErrNotOpen
+	case closed:
+		return ErrClosed
+	}
+	return nil
+}
+
+// checkOpen returns ErrNotOpen or ErrClosed if the connection is not open.
+func (o *HyInverter) checkOpen() error {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	return o.stateError()
+}
+
+// stopped returns true after Close, the goroutines end.
+func (o *HyInverter) stopped() bool {
+	return atomic.LoadInt32(&o.stop) != 0
+}
+
+// open connects a port and starts the goroutines. If the port can't be opened, Open may
+// be called again. The dial function is kept for reconnects if reconnect is set. ctx only
+// limits the first dial.
+func (o *HyInverter) open(ctx context.Context, dial func() (io.ReadWriteCloser, error), reconnect bool, settings openSettings) error {
+	o.connectMutex.Lock()
+	defer o.connectMutex.Unlock()
+	o.lifecycleMutex.Lock()
+	if o.lifecycle == opened {
+		o.lifecycleMutex.Unlock()
+		return ErrAlreadyOpen
+	}
+	settings.apply(o)
+	port, err := dialContext(ctx, dial)
+	if err != nil {
+		o.lifecycleMutex.Unlock()
+		return err
+	}
+	o.reset()
+	if reconnect {
+		o.dial = dial
+	}
+	if err := o.start(port, settings); err != nil {
+		o.dial = nil
+		o.lifecycleMutex.Unlock()
+		return err
+	}
+	o.lifecycle = opened
+	o.lifecycleMutex.Unlock()
+	o.opened(settings)
+	return nil
+}
+
+// opened logs the connection and establishes the state selected by SetOpenState. It is
+// called after the lifecycle was set to opened, see open and OpenDriver.
+func (o *HyInverter) opened(settings openSettings) {
+	o.log(slog.LevelInfo, "vfdio: opened", "version", Version(), "max_rpm", o.MaxRpm(), "poll_interval", settings.pollInterval)
+	if o.OpenState() == StopOnOpen && !o.ReadOnly() {
+		o.GCode("M5 S0")
+	}
+}
+
+// Enqueue works like GCode, but returns ErrNotOpen, ErrClosed or ErrQueueFull if a word
+// was not queued, or ErrReadOnly, ErrEStopped, ErrInvalidSpeed, ErrInvalidDwell,
+// ErrBelowMinimum, ErrAboveMaximum, ErrReverseLocked or ErrUnsupportedWord if the line was
+// rejected, see SetReadOnly, EStop, SetMinRpm, SetAboveMaximum, SetReverseLockout and
+// SetStrictWords.
+func (o *HyInverter) Enqueue(cmd string) (err error) {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	if err := o.stateError(); err != nil {
+		return err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return err
+	}
+	for _, subCmd := range words {
+		if subCmd.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		if !o.queue(subCmd, nil) && err == nil {
+			err = ErrQueueFull
+		}
+	}
+	return
+}
+
+// checkWords rejects a line if one of its words can't be queued, see Enqueue.
+func (o *HyInverter) checkWords(words []lineWord) error {
+	texts := wordTexts(words)
+	if err := o.checkReadOnlyWords(texts); err != nil {
+		return err
+	}
+	if err := o.checkEStopWords(texts); err != nil {
+		return err
+	}
+	if err := checkSpeedWords(texts); err != nil {
+		return err
+	}
+	if err := checkDwellWords(words); err != nil {
+		return err
+	}
+	if err := o.checkMinRpmWords(texts); err != nil {
+		return err
+	}
+	if err := o.checkMaxRpmWords(texts); err != nil {
+		return err
+	}
+	return o.checkReverseWords(words)
+}
+
+// Close closes all handles and returns after all goroutines ended. It returns ErrNotOpen
+// before Open and ErrClosed if it was already closed. Queued commands are discarded with
+// ErrClosed, see EnqueueResults. The HyInverter can be opened again and keeps its settings,
+// e.g. to recover from an adapter swap. Close must not be called from an event handler,
+// which runs on one of the goroutines; call it from another goroutine in that case.
+func (o *HyInverter) Close() error {
+	o.connectMutex.Lock()
+	defer o.connectMutex.Unlock()
+	o.lifecycleMutex.Lock()
+	if err := o.stateError(); err != nil {
+		o.lifecycleMutex.Unlock()
+		return err
+	}
+	o.lifecycle = closed
+	atomic.StoreInt32(&o.stop, 1)
+	close(o.done)
+	o.lifecycleMutex.Unlock()
+	o.stateMutex.Lock()
+	if o.jogTimer != nil {
+		o.jogTimer.Stop()
+		o.jogTimer = nil
+	}
+	if o.keepaliveTimer != nil {
+		o.keepaliveTimer.Stop()
+		o.keepaliveTimer = nil
+	}
+	o.stateMutex.Unlock()
+	o.closeStatusUpdates()
+	o.flushCommands(ErrClosed)
+	err := o.currentDriver().close()
+	o.goroutines.Wait()
+	o.log(slog.LevelInfo, "vfdio: closed")
+	return err
+}
+
+// reset clears the state of a previous connection before Open: the queue, the circuit
+// breaker, Stats and the values received from the VFD. The settings and the EStop latch
+// are kept. The caller holds connectMutex, the goroutines of the previous connection ended.
+func (o *HyInverter) reset() {
+	atomic.StoreInt32(&o.commandQueue, 0)
+	atomic.StoreInt32(&o.preemptions, 0)
+	for i := range o.pollPending {
+		atomic.StoreInt32(&o.pollPending[i], 0)
+	}
+	atomic.StoreInt32(&o.portErrors, 0)
+	atomic.StoreInt32(&o.reconnecting, 0)
+	o.driver = nil
+	o.dial = nil
+	o.reconnectChannel = nil
+	o.dwellCommand = nil
+	o.paused = false
+	o.heldCommands = nil
+	o.pauseLowered = false
+	o.modal = ModalState{}
+	o.txStats.mutex.Lock()
+	o.txStats.stats = Stats{}
+	o.txStats.waiting = false
+	o.txStats.failures = 0
+	o.txStats.open = false
+	o.txStats.command = nil
+	o.txStats.mutex.Unlock()
+	o.pollMutex.Lock()
+	o.status = [statusValueCount]uint16{}
+	o.statusReceived = [statusValueCount]uint32{}
+	o.setFrequency = 0
+	o.outputFrequency = 0
+	o.outputRpm = 0
+	o.lastReceived = time.Time{}
+	o.pollMutex.Unlock()
+	o.stateMutex.Lock()
+	o.offline = false
+	o.lastError = nil
+	o.temperatureHigh = false
+	o.faultCode = 0
+	o.controlStatus = 0
+	o.controlStatusReceived = false
+	o.stalled = false
+	o.lastControlFrame = nil
+	o.lastFrequencyFrame = nil
+	o.stateMutex.Unlock()
+	o.eventMutex.Lock()
+	o.updatesClosed = false
+	o.eventMutex.Unlock()
+}
+
+// launch starts a supervised goroutine which is waited for by Close.
+func (o *HyInverter) launch(name string, run func(*HyInverter)) {
+	o.goroutines.Add(1)
+	go func() {
+		defer o.goroutines.Done()
+		o.supervise(name, run)
+	}()
+}
+
+// sleep waits for d on the current clock and returns false if Close was called first.
+func (o *HyInverter) sleep(d time.Duration) bool {
+	timer := o.currentClock().NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-o.done:
+		return false
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence []string
+		errors   []error
+	}{
+		{"GCode before Open", []string{"gcode", "open", "gcode"}, []error{ErrNotOpen, nil, nil}},
+		{"Close before Open", []string{"close", "open", "close"}, []error{ErrNotOpen, nil, nil}},
+		{"Open twice", []string{"open", "open", "close"}, []error{nil, ErrAlreadyOpen, nil}},
+		{"Close twice", []string{"open", "close", "close"}, []error{nil, nil, ErrClosed}},
+		{"GCode after Close", []string{"open", "close", "gcode"}, []error{nil, nil, ErrClosed}},
+		{"Open after Close", []string{"open", "close", "open", "close"}, []error{nil, nil, nil, nil}},
+		{"Open failed", []string{"open-missing", "gcode", "close", "open", "close"}, []error{nil, ErrNotOpen, ErrNotOpen, nil, nil}},
+	}
+	for _, test := range tests {
+		hy := NewVfd()
+		for i, step := range test.sequence {
+			var err error
+			switch step {
+			case "open":
+				err = hy.OpenPort(simulator.New(), WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second))
+			case "open-missing":
+				if hy.Open("/dev/missing-huanyango-port", WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second)) == nil {
+					t.Errorf("%s: opening a missing port succeeded", test.name)
+				}
+			case "gcode":
+				err = hy.Enqueue("M5")
+				if hy.GCode("M5") != (err == nil) {
+					t.Errorf("%s: GCode and Enqueue differ", test.name)
+				}
+			case "close":
+				err = hy.Close()
+			}
+			if err != test.errors[i] {
+				t.Errorf("%s, step %d (%s): expected %v, got %v", test.name, i, step, test.errors[i], err)
+			}
+		}
+		hy.Close()
+	}
+}
+
+func TestLifecycleConcurrent(t *testing.T) {
+	hy := NewVfd()
+	hy.SetStopEscalation(0, nil)
+	var wg sync.WaitGroup
+	unexpected := make(chan error, 100)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				err := hy.Enqueue("S1000 M3 ?")
+				if err != nil && err != ErrNotOpen && err != ErrClosed && err != ErrQueueFull {
+					unexpected <- err
+				}
+				hy.Jog(Forward, 1000)
+				hy.RawStatus(StatusOutputFrequency)
+			}
+		}()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := hy.OpenPort(simulator.New(), WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(50*time.Millisecond)); err != nil && err != ErrClosed {
+			unexpected <- err
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := hy.Close(); err != nil && err != ErrNotOpen {
+			unexpected <- err
+		}
+	}()
+	wg.Wait()
+	hy.Close()
+	close(unexpected)
+	var messages []string
+	for err := range unexpected {
+		messages = append(messages, err.Error())
+	}
+	if len(messages) > 0 {
+		t.Fatal(strings.Join(messages, ", "))
+	}
+	if err := hy.Enqueue("M5"); err != ErrClosed && err != ErrNotOpen {
+		t.Fatalf("unexpected state after Close: %v", err)
+	}
+}
+
+func TestCloseEndsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	vfd := simulator.New()
+	hy := NewVfd()
+	if err := hy.OpenPort(vfd, WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	hy.GCode("M3 S1000")
+	time.Sleep(300 * time.Millisecond)
+	start := time.Now()
+	if err := hy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v", elapsed)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines before Open, %d after Close:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+	if err := hy.Close(); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := hy.write(hy.controlFrame(CommandStop)); err != ErrClosed {
+		t.Errorf("write after Close: expected ErrClosed, got %v", err)
+	}
+}
+
+func TestOpenFactorUnknown(t *testing.T) {
+	vfd := simulator.New()
+	vfd.SetSilent(true)
+	hy := NewVfd()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); !errors.Is(err, ErrParameterTimeout) {
+		t.Fatalf("expected ErrParameterTimeout, got %v", err)
+	}
+	if _, err := vfd.Write([]byte{0x01}); err == nil {
+		t.Error("port not closed")
+	}
+	if err := hy.Enqueue("S6000"); err != ErrNotOpen {
+		t.Errorf("expected ErrNotOpen, got %v", err)
+	}
+	if err := hy.OpenPort(simulator.New(), WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if hy.frequencyPerRpm <= 0 {
+		t.Errorf("conversion factor not derived: %v", hy.frequencyPerRpm)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	hy := NewVfd()
+	hy.SetMinRpm(3000, RejectBelowMinimum)
+	if err := hy.OpenPort(simulator.New(), WithMaxRpm(24000), WithPollInterval(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	first := hy.StatusUpdates()
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		hy.countFailure()
+	}
+	if err := hy.EStop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-first; ok {
+		t.Fatal("channel of the first connection is open")
+	}
+
+	vfd := simulator.New()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if hy.CircuitOpen() || hy.LastError() != nil {
+		t.Errorf("circuit breaker of the first connection kept: %v", hy.LastError())
+	}
+	if !hy.EStopped() {
+		t.Error("emergency stop cleared by reopening")
+	}
+	hy.ClearEStop()
+	if err := hy.Enqueue("S1000"); !errors.Is(err, ErrBelowMinimum) {
+		t.Error("minimum speed lost by reopening")
+	}
+	updates := hy.StatusUpdates()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hy.ExecuteGCode(ctx, "M3 S6000"); err != nil {
+		t.Fatal(err)
+	}
+	if running, _ := vfd.Running(); !running {
+		t.Error("spindle not started after reopening")
+	}
+	select {
+	case <-updates:
+	case <-ctx.Done():
+		t.Fatal("no status update after reopening")
+	}
+}
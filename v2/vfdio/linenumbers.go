@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrNoReply is returned by StreamProgram if StreamOptions.LineNumbers is set without Reply.
+var ErrNoReply = errors.New("vfdio: line numbers require StreamOptions.Reply")
+
+// lineNumbers is the state of the line number and checksum protocol of a stream, see
+// StreamOptions.LineNumbers.
+type lineNumbers struct {
+	reply io.Writer
+	// last is the number of the last accepted line.
+	last int
+}
+
+// accept checks the line number and checksum of a line and returns the line without them.
+// Skip is set if the line must not be processed: a resend was requested because the line
+// is corrupted, or it was the M110 which set the line number and was acknowledged already.
+func (n *lineNumbers) accept(line string) (body string, skip bool, err error) {
+	body, number, numbered, reason := splitLineNumber(line)
+	reset := false
+	if reason == "" {
+		var resetNumber int
+		var resetNumbered bool
+		reset, resetNumber, resetNumbered = lineNumberReset(body)
+		if resetNumbered {
+			number, numbered = resetNumber, true
+		}
+		if !reset && numbered && number != n.last+1 {
+			reason = fmt.Sprintf("line number %d, expected %d", number, n.last+1)
+		}
+	}
+	if reason != "" {
+		_, err = fmt.Fprintf(n.reply, "Error:%s\nResend: %d\nok\n", reason, n.last+1)
+		return "", true, err
+	}
+	if numbered {
+		n.last = number
+	}
+	if reset {
+		return "", true, n.ok()
+	}
+	return body, false, nil
+}
+
+// ok acknowledges an accepted line, the host sends the next one.
+func (n *lineNumbers) ok() error {
+	_, err := io.WriteString(n.reply, "ok\n")
+	return err
+}
+
+// splitLineNumber removes a leading N word and a trailing *checksum from a line. The
+// checksum is the XOR of the bytes before the asterisk. Reason describes a checksum
+// mismatch.
+func splitLineNumber(line string) (body string, number int, numbered bool, reason string) {
+	body = line
+	if i := strings.LastIndexByte(body, '*'); i >= 0 {
+		expected, err := strconv.Atoi(strings.TrimSpace(body[i+1:]))
+		var checksum byte
+		for j := 0; j < i; j++ {
+			checksum ^= body[j]
+		}
+		if err != nil || expected != int(checksum) {
+			return "", 0, false, "checksum mismatch"
+		}
+		body = body[:i]
+	}
+	trimmed := strings.TrimLeft(body, " \t")
+	if len(trimmed) < 2 || (trimmed[0] != 'N' && trimmed[0] != 'n') || !isDigit(trimmed[1]) {
+		return body, 0, false, ""
+	}
+	end := 1
+	for end < len(trimmed) && isDigit(trimmed[end]) {
+		end++
+	}
+	number, err := strconv.Atoi(trimmed[1:end])
+	if err != nil {
+		return "", 0, false, "malformed line number"
+	}
+	return trimmed[end:], number, true, ""
+}
+
+// lineNumberReset returns true for M110, which sets the number of the line to the value of
+// its N word. Numbered is false without N word.
+func lineNumberReset(body string) (reset bool, number int, numbered bool) {
+	for _, token := range scanGCode(body) {
+		word := token.word
+		switch {
+		case word.Letter == 'M' && word.Value == 110:
+			reset = true
+		case word.Letter == 'N':
+			if value, err := strconv.Atoi(word.Text[1:]); err == nil {
+				number, numbered = value, true
+			}
+		}
+	}
+	return reset, number, reset && numbered
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withChecksum appends the checksum of the line number protocol.
+func withChecksum(line string) string {
+	var checksum byte
+	for i := 0; i < len(line); i++ {
+		checksum ^= line[i]
+	}
+	return fmt.Sprintf("%s*%d", line, checksum)
+}
+
+func TestStreamLineNumbers(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 24000
+	corrupted := strings.Replace(withChecksum("N2 S12000"), "S12000", "S19000", 1)
+	program := strings.Join([]string{
+		withChecksum("N0 M110 N0"),
+		withChecksum("N1 M3"),
+		corrupted,
+		withChecksum("N3 G0 X1"),
+		withChecksum("N2 S12000"),
+		"M5",
+	}, "\n")
+	var reply, tee bytes.Buffer
+	err := hy.StreamProgram(context.Background(), strings.NewReader(program), StreamOptions{LineNumbers: true, Reply: &reply, Tee: &tee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "ok\nok\n" +
+		"Error:checksum mismatch\nResend: 2\nok\n" +
+		"Error:line number 3, expected 2\nResend: 2\nok\n" +
+		"ok\nok\n"
+	if reply.String() != expected {
+		t.Errorf("unexpected replies:\n%s", reply.String())
+	}
+	for _, word := range []string{"M3", "S12000", "M5"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != word {
+			t.Fatalf("expected %q, got %q", word, cmd)
+		}
+	}
+	if len(hy.cmdChannel) != 0 || tee.Len() != 0 {
+		t.Errorf("unexpected commands %d or forwarded lines %q", len(hy.cmdChannel), tee.String())
+	}
+
+	if err := hy.StreamProgram(context.Background(), strings.NewReader("M3"), StreamOptions{LineNumbers: true}); err != ErrNoReply {
+		t.Errorf("expected ErrNoReply, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+)
+
+// ParameterRatedCurrent is PD142, the rated motor current in 0.1 A.
+const ParameterRatedCurrent byte = registers.PD142
+
+// SetRatedCurrent sets the rated motor current in ampere used by Load. 0 disables Load.
+// Default: 0.
+func (o *HyInverter) SetRatedCurrent(amps float64) {
+	o.stateMutex.Lock()
+	o.ratedCurrent = amps
+	o.stateMutex.Unlock()
+}
+
+// RatedCurrent returns the rated motor current in ampere used by Load.
+func (o *HyInverter) RatedCurrent() float64 {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.ratedCurrent
+}
+
+// ReadRatedCurrent sets the rated motor current to the value configured in the VFD (PD142).
+// It has to be called after Open. The value is unchanged if PD142 can't be read.
+func (o *HyInverter) ReadRatedCurrent() error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	current, err := o.readParameter(ParameterRatedCurrent)
+	if err != nil {
+		return err
+	}
+	o.SetRatedCurrent(float64(current) / 10)
+	return nil
+}
+
+// Load returns the spindle load estimated from the output current in percent of the rated
+// current, e.g. as chip load signal of an adaptive feed. It is 0 if the rated current is not
+// known, see SetRatedCurrent and ReadRatedCurrent. Values above 100 are possible while
+// accelerating or overloaded. Add StatusOutputCurrent to the poll values to keep it up to date.
+func (o *HyInverter) Load() float64 {
+	rated := o.RatedCurrent()
+	if rated <= 0 {
+		return 0
+	}
+	return o.OutputCurrentAmps() / rated * 100
+}
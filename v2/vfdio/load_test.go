@@ -0,0 +1,33 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	vfd := simulator.New()
+	vfd.Parameters[ParameterRatedCurrent] = 80
+	hy := NewVfd()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.pollMutex.Lock()
+	hy.status[StatusOutputCurrent] = 20
+	hy.pollMutex.Unlock()
+	if load := hy.Load(); load != 0 {
+		t.Fatalf("load without rated current: %v", load)
+	}
+	if err := hy.ReadRatedCurrent(); err != nil {
+		t.Fatal(err)
+	}
+	if hy.RatedCurrent() != 8 || hy.Load() != 25 {
+		t.Fatalf("rated current %v A, load %v %%", hy.RatedCurrent(), hy.Load())
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SetLogger sets a structured logger for frames, parse failures, retries, events and the
+// life cycle. Sent and decoded frames are logged at debug level, frames which could not be
+// decoded, retries and events with an error as warnings, other events at info level. nil
+// disables logging (default). See WithLogger.
+func (o *HyInverter) SetLogger(logger *slog.Logger) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	o.logger = logger
+}
+
+// Logger returns the logger set by SetLogger, nil if logging is disabled.
+func (o *HyInverter) Logger() *slog.Logger {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.logger
+}
+
+// log writes a message if a logger is set. The caller must not hold stateMutex.
+func (o *HyInverter) log(level slog.Level, msg string, args ...any) {
+	logger := o.Logger()
+	if logger == nil || !logger.Enabled(context.Background(), level) {
+		return
+	}
+	logger.Log(context.Background(), level, msg, args...)
+}
+
+// logFrameEntry logs a frame of the frame log, see logFrame.
+func (o *HyInverter) logFrameEntry(direction FrameDirection, data []byte, status string) {
+	level := slog.LevelDebug
+	if status != "" && status != FrameOk {
+		level = slog.LevelWarn
+	}
+	args := []any{"direction", direction.String(), "data", fmt.Sprintf("% X", data)}
+	if status != "" {
+		args = append(args, "status", status)
+	}
+	o.log(level, "vfdio: frame", args...)
+}
+
+// logEvent logs an event before it is passed to the handlers.
+func (o *HyInverter) logEvent(event Event) {
+	if event.Err != nil {
+		o.log(slog.LevelWarn, "vfdio: event", "type", event.Type.String(), "err", event.Err)
+		return
+	}
+	o.log(slog.LevelInfo, "vfdio: event", "type", event.Type.String())
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"context"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	hy := NewVfd()
+	if err := hy.OpenPort(simulator.New(), WithMaxRpm(24000), WithPollInterval(10*time.Second), WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if hy.Logger() != logger {
+		t.Error("logger not set by WithLogger")
+	}
+	hy.GCode("M3 S6000")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hy.waitFor(ctx, func() bool { return hy.QueueDepth() == 0 }); err != nil {
+		t.Fatal(err)
+	}
+	hy.Close()
+	hy.emit(Event{Type: Offline, Err: ErrReadTimeout})
+	output := buf.String()
+	for _, expected := range []string{
+		`msg="vfdio: opened" version=`,
+		`max_rpm=24000`,
+		`direction=TX data="01 03 01`,
+		`direction=RX`,
+		`status=ok`,
+		`msg="vfdio: closed"`,
+		`level=WARN msg="vfdio: event" type=Offline err=`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("%q not logged:\n%s", expected, output)
+		}
+	}
+
+	buf.Reset()
+	hy.SetLogger(nil)
+	hy.emit(Event{Type: Offline, Err: ErrReadTimeout})
+	if buf.Len() != 0 {
+		t.Errorf("logged without logger: %s", buf.String())
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrAboveMaximum is returned for S commands above MaxRpm if they are rejected, see
+// SetAboveMaximum.
+var ErrAboveMaximum = errors.New("vfdio: speed above maximum")
+
+// AboveMaximum selects how S commands above MaxRpm are handled.
+type AboveMaximum int
+
+const (
+	// ClampToMaximum lowers the speed to MaxRpm and reports a Clamped event.
+	ClampToMaximum AboveMaximum = iota
+	// RejectAboveMaximum rejects the command: GCode returns false, Enqueue and EncodeCommand
+	// return ErrAboveMaximum and StreamProgram a *LineError. Nothing of the line is queued.
+	RejectAboveMaximum
+)
+
+// SetAboveMaximum selects how S commands above MaxRpm are handled, see WithMaxRpm. A
+// command is never sent with a higher speed, so a bug of the sender can't over-speed the
+// spindle. Default: ClampToMaximum.
+func (o *HyInverter) SetAboveMaximum(action AboveMaximum) {
+	o.stateMutex.Lock()
+	o.aboveMaximum = action
+	o.stateMutex.Unlock()
+}
+
+// AboveMaximumAction returns how S commands above MaxRpm are handled.
+func (o *HyInverter) AboveMaximumAction() AboveMaximum {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.aboveMaximum
+}
+
+// lowerToMaxRpm returns MaxRpm if rpm is above it and commands are clamped.
+func (o *HyInverter) lowerToMaxRpm(rpm float64) (float64, bool) {
+	maxRpm := o.MaxRpm()
+	if o.AboveMaximumAction() != ClampToMaximum || maxRpm == 0 || rpm <= float64(maxRpm) {
+		return rpm, false
+	}
+	return float64(maxRpm), true
+}
+
+// checkMaxRpm returns an error if rpm is above MaxRpm and commands are rejected.
+func (o *HyInverter) checkMaxRpm(rpm float64) error {
+	maxRpm := o.MaxRpm()
+	if o.AboveMaximumAction() != RejectAboveMaximum || maxRpm == 0 || rpm <= float64(maxRpm) {
+		return nil
+	}
+	return fmt.Errorf("%w: S%v > %d", ErrAboveMaximum, rpm, maxRpm)
+}
+
+// checkMaxRpmWords checks the S words of a line, see checkMaxRpm.
+func (o *HyInverter) checkMaxRpmWords(words []string) error {
+	for _, word := range words {
+		if !strings.HasPrefix(word, "s") && !strings.HasPrefix(word, "S") {
+			continue
+		}
+		if rpm, err := strconv.ParseFloat(word[1:], 64); err == nil {
+			if err := o.checkMaxRpm(rpm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaxRpmClamped(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 12000
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.GCode("S99999")
+	hy.processNext()
+	expected, _ := hy.rpmToFrequency(12000)
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 1 || frequencies[0] != expected {
+		t.Fatalf("speed not clamped: %v", frequencies)
+	}
+	if len(events) != 1 || events[0].Type != Clamped || events[0].Rpm != 12000 || events[0].RequestedRpm != 65535 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if frames, err := hy.EncodeCommand("S99999"); err != nil || len(frames) != 1 || sentFrequencies(frames[0])[0] != expected {
+		t.Fatalf("encoded speed not clamped: % X, %v", frames, err)
+	}
+}
+
+func TestMaxRpmRejected(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 12000
+	hy.SetAboveMaximum(RejectAboveMaximum)
+	if err := hy.Enqueue("M3 S12001"); !errors.Is(err, ErrAboveMaximum) {
+		t.Fatalf("expected ErrAboveMaximum, got %v", err)
+	}
+	if len(hy.cmdChannel) != 0 {
+		t.Fatalf("rejected line queued")
+	}
+	if _, err := hy.EncodeCommand("S12001"); !errors.Is(err, ErrAboveMaximum) {
+		t.Fatalf("expected ErrAboveMaximum, got %v", err)
+	}
+	// Queued before the action was changed
+	hy.SetAboveMaximum(ClampToMaximum)
+	hy.GCode("S20000")
+	hy.SetAboveMaximum(RejectAboveMaximum)
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 0 {
+		t.Fatalf("rejected speed sent: %v", frequencies)
+	}
+	if !hy.GCode("M3 S12000") {
+		t.Fatal("maximum speed rejected")
+	}
+}
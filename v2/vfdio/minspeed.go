@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"strconv"
+	"strings"
+)
+
+// ParameterMinFrequency is PD011, the lowest operating frequency in 0.01 Hz.
+const ParameterMinFrequency byte = registers.PD011
+
+// ErrBelowMinimum is returned for S commands below the minimum set by SetMinRpm.
+var ErrBelowMinimum = errors.New("vfdio: speed below minimum")
+
+// BelowMinimum selects how S commands below the minimum speed are handled.
+type BelowMinimum int
+
+const (
+	// ClampToMinimum raises the speed to the minimum and reports a Clamped event.
+	ClampToMinimum BelowMinimum = iota
+	// RejectBelowMinimum rejects the command: GCode returns false, Enqueue and EncodeCommand
+	// return ErrBelowMinimum and StreamProgram a *LineError. Nothing of the line is queued.
+	RejectBelowMinimum
+)
+
+// SetMinRpm sets the lowest speed accepted by S commands, e.g. for water-cooled spindles
+// whose bearings are damaged far below their rated speed. S0 is always accepted.
+// 0 disables the limit. Default: 0.
+func (o *HyInverter) SetMinRpm(rpm uint16, action BelowMinimum) {
+	o.stateMutex.Lock()
+	o.minRpm = rpm
+	o.belowMinimum = action
+	o.stateMutex.Unlock()
+}
+
+// MinRpm returns the minimum speed and how commands below it are handled.
+func (o *HyInverter) MinRpm() (rpm uint16, action BelowMinimum) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.minRpm, o.belowMinimum
+}
+
+// ReadMinRpm sets the minimum speed to the lowest operating frequency of the VFD (PD011).
+// It has to be called after Open. The minimum is unchanged if PD011 can't be read.
+func (o *HyInverter) ReadMinRpm(action BelowMinimum) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	minFrequency, err := o.readParameter(ParameterMinFrequency)
+	if err != nil {
+		return err
+	}
+	o.SetMinRpm(o.frequencyToRpm(minFrequency), action)
+	return nil
+}
+
+// raiseToMinRpm returns the minimum if rpm is below it and commands are clamped.
+func (o *HyInverter) raiseToMinRpm(rpm float64) (float64, bool) {
+	minRpm, action := o.MinRpm()
+	if action != ClampToMinimum || rpm == 0 || rpm >= float64(minRpm) {
+		return rpm, false
+	}
+	return float64(minRpm), true
+}
+
+// checkMinRpm returns an error if rpm is below the minimum and commands are rejected.
+func (o *HyInverter) checkMinRpm(rpm float64) error {
+	minRpm, action := o.MinRpm()
+	if action != RejectBelowMinimum || rpm == 0 || rpm >= float64(minRpm) {
+		return nil
+	}
+	return fmt.Errorf("%w: S%v < %d", ErrBelowMinimum, rpm, minRpm)
+}
+
+// checkMinRpmWords checks the S words of a line, see checkMinRpm.
+func (o *HyInverter) checkMinRpmWords(words []string) error {
+	for _, word := range words {
+		if !strings.HasPrefix(word, "s") && !strings.HasPrefix(word, "S") {
+			continue
+		}
+		if rpm, err := strconv.ParseFloat(word[1:], 64); err == nil {
+			if err := o.checkMinRpm(rpm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
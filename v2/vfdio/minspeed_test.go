@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMinRpmClamped(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetMinRpm(6000, ClampToMinimum)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.GCode("S100")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 1 || frequencies[0] != 20833 {
+		t.Fatalf("speed not clamped: %v", frequencies)
+	}
+	if len(events) != 1 || events[0].Type != Clamped || events[0].Rpm != 6000 || events[0].RequestedRpm != 100 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	// S0 is accepted.
+	hy.GCode("S0")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 2 || frequencies[1] != 0 {
+		t.Fatalf("S0 clamped: %v", frequencies)
+	}
+}
+
+func TestMinRpmRejected(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetMinRpm(6000, RejectBelowMinimum)
+	if err := hy.Enqueue("M3 S100"); !errors.Is(err, ErrBelowMinimum) {
+		t.Fatalf("expected ErrBelowMinimum, got %v", err)
+	}
+	if len(hy.cmdChannel) != 0 {
+		t.Fatalf("rejected line queued")
+	}
+	if _, err := hy.EncodeCommand("S100"); !errors.Is(err, ErrBelowMinimum) {
+		t.Fatalf("expected ErrBelowMinimum, got %v", err)
+	}
+	err := hy.StreamProgram(context.Background(), strings.NewReader("M3 S100\n"), StreamOptions{Name: "job.nc"})
+	if lineErr, ok := err.(*LineError); !ok || lineErr.Reason != "speed below minimum of 6000" {
+		t.Fatalf("expected a *LineError, got %v", err)
+	}
+	if !hy.GCode("M3 S6000") {
+		t.Fatal("minimum speed rejected")
+	}
+}
+
+func TestReadMinRpm(t *testing.T) {
+	vfd := simulator.New()
+	vfd.Parameters[ParameterMinFrequency] = 20000
+	hy := NewVfd()
+	if err := hy.ReadMinRpm(RejectBelowMinimum); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if err := hy.ReadMinRpm(RejectBelowMinimum); err != nil {
+		t.Fatal(err)
+	}
+	if rpm, action := hy.MinRpm(); rpm != 12000 || action != RejectBelowMinimum {
+		t.Fatalf("unexpected minimum %d, %v", rpm, action)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package mockport provides a scripted in-memory port, so applications embedding vfdio can
+// unit-test their spindle logic without hardware. Every write is one request frame. It is
+// matched against the scripted exchanges in order, then against the standing handlers.
+// Example:
+//
+//   port := mockport.New()
+//   // Open reads the maximum frequency (PD005)
+//   port.Expect(mockport.Frame(0x01, 0x01, 0x03, 0x05, 0x00, 0x00), mockport.Frame(0x01, 0x01, 0x03, 0x05, 0x9C, 0x40))
+//   port.Expect(mockport.Frame(0x01, 0x03, 0x01, 0x01), mockport.Frame(0x01, 0x03, 0x01, 0x01))
+//   port.Handle(mockport.Frame(0x01, 0x04, 0x03, 0x01, 0x00, 0x00), mockport.Frame(0x01, 0x04, 0x03, 0x01, 0x9C, 0x40))
+//   handle := vfdio.NewVfd()
+//   handle.OpenPort(port, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond))
+//   handle.GCode("M3")
+//   ...
+//   if err := port.Err(); err != nil {
+//       t.Fatal(err)
+//   }
+//
+package mockport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/npat-efault/crc16"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Read and Write after Close.
+var ErrClosed = errors.New("mockport: port closed")
+
+// Exchange is a request and the response returned for it. A nil response is not answered.
+type Exchange struct {
+	Request  []byte
+	Response []byte
+}
+
+// Port implements io.ReadWriteCloser.
+type Port struct {
+	// ReadTimeout is the time Read waits for a response before it returns io.EOF, like a
+	// serial port with inter character timeout. Default: 100 ms.
+	ReadTimeout time.Duration
+
+	mutex      sync.Mutex
+	script     []Exchange
+	handlers   []Exchange
+	written    [][]byte
+	unexpected [][]byte
+	responses  bytes.Buffer
+	ready      chan struct{}
+	closed     bool
+}
+
+// New creates a port with the given scripted exchanges.
+func New(script ...Exchange) *Port {
+	return &Port{
+		ReadTimeout: 100 * time.Millisecond,
+		script:      script,
+		ready:       make(chan struct{}, 1),
+	}
+}
+
+// Frame returns data with the Modbus CRC appended.
+func Frame(data ...byte) []byte {
+	crc := crc16.Checksum(crc16.Modbus, data)
+	return append(append([]byte(nil), data...), byte(crc), byte(crc>>8))
+}
+
+// Expect appends an exchange to the script. Scripted exchanges are expected in order and used once.
+func (p *Port) Expect(request, response []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.script = append(p.script, Exchange{request, response})
+}
+
+// Handle answers every request equal to the given one, e.g. the periodic status polls.
+func (p *Port) Handle(request, response []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.handlers = append(p.handlers, Exchange{request, response})
+}
+
+// Write receives a request and queues its response.
+func (p *Port) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return 0, ErrClosed
+	}
+	request := append([]byte(nil), b...)
+	p.written = append(p.written, request)
+	var response []byte
+	matched := false
+	if len(p.script) > 0 && bytes.Equal(p.script[0].Request, request) {
+		response, matched = p.script[0].Response, true
+		p.script = p.script[1:]
+	} else {
+		for _, handler := range p.handlers {
+			if bytes.Equal(handler.Request, request) {
+				response, matched = handler.Response, true
+				break
+			}
+		}
+	}
+	if !matched {
+		p.unexpected = append(p.unexpected, request)
+		return len(b), nil
+	}
+	if len(response) > 0 {
+		p.responses.Write(response)
+		select {
+		case p.ready <- struct{}{}:
+		default:
+		}
+	}
+	return len(b), nil
+}
+
+// Read returns queued responses. It waits up to ReadTimeout and returns io.EOF if there is none.
+func (p *Port) Read(b []byte) (int, error) {
+	deadline := time.After(p.ReadTimeout)
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return 0, ErrClosed
+		}
+		if p.responses.Len() > 0 {
+			defer p.mutex.Unlock()
+			return p.responses.Read(b)
+		}
+		p.mutex.Unlock()
+		select {
+		case <-p.ready:
+		case <-deadline:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Close makes all further reads and writes fail.
+func (p *Port) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	return nil
+}
+
+// Written returns all requests in the order they were written.
+func (p *Port) Written() [][]byte {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([][]byte(nil), p.written...)
+}
+
+// Remaining returns the number of scripted exchanges which did not happen yet.
+func (p *Port) Remaining() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.script)
+}
+
+// Err reports requests which matched neither the script nor a handler, and scripted
+// exchanges which did not happen.
+func (p *Port) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.unexpected) > 0 {
+		return fmt.Errorf("mockport: %d unexpected requests, first: % X", len(p.unexpected), p.unexpected[0])
+	}
+	if len(p.script) > 0 {
+		return fmt.Errorf("mockport: %d scripted requests missing, next: % X", len(p.script), p.script[0].Request)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mockport
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"testing"
+	"time"
+)
+
+func TestScriptedSpindle(t *testing.T) {
+	port := New()
+	port.Expect(Frame(0x01, 0x01, 0x03, 0x05, 0x00, 0x00), Frame(0x01, 0x01, 0x03, 0x05, 0x9C, 0x40))
+	port.Expect(Frame(0x01, 0x05, 0x02, 0x27, 0x10), Frame(0x01, 0x05, 0x02, 0x27, 0x10))
+	port.Expect(Frame(0x01, 0x03, 0x01, 0x01), Frame(0x01, 0x03, 0x01, 0x01))
+	port.Handle(Frame(0x01, 0x04, 0x03, 0x01, 0x00, 0x00), Frame(0x01, 0x04, 0x03, 0x01, 0x27, 0x10))
+	handle := vfdio.NewVfd()
+	handle.OpenPort(port, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(50*time.Millisecond))
+	defer handle.Close()
+	handle.GCode("S2880 M3")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if processed, _, _ := handle.Processed(); processed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not processed, written: % X", port.Written())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if frequency, confirmed := handle.AcceptedFrequency(); frequency != 0x2710 || !confirmed {
+		t.Errorf("echo not confirmed: %d", frequency)
+	}
+	if err := port.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnexpectedAndMissingRequests(t *testing.T) {
+	port := New(Exchange{Frame(0x01, 0x03, 0x01, 0x08), nil})
+	port.Write(Frame(0x01, 0x03, 0x01, 0x01))
+	if port.Err() == nil {
+		t.Fatal("unexpected request not reported")
+	}
+	port = New(Exchange{Frame(0x01, 0x03, 0x01, 0x08), nil})
+	if port.Err() == nil || port.Remaining() != 1 {
+		t.Fatal("missing request not reported")
+	}
+	port.Write(Frame(0x01, 0x03, 0x01, 0x08))
+	if err := port.Err(); err != nil || port.Remaining() != 0 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ModalState is the state of the spindle words executed last, see ModalState. Words which
+// were rejected, e.g. because of EStop, are not recorded; words which the VFD did not
+// acknowledge are.
+type ModalState struct {
+	// Spindle is CommandRunForward (M3), CommandRunBackward (M4) or CommandStop (M5), 0
+	// before the first of them was executed.
+	Spindle ControlCommand
+	// Speed is the last executed S value in RPM before the limits were applied, valid if
+	// SpeedSet is true.
+	Speed    float64
+	SpeedSet bool
+	// Deferred is set while the S value waits for the next M3 or M4, see SetDeferSpeed.
+	Deferred bool
+}
+
+// String returns the words of the state, e.g. "M3 S12000".
+func (m ModalState) String() string {
+	var words []string
+	switch m.Spindle {
+	case CommandRunForward:
+		words = append(words, "M3")
+	case CommandRunBackward:
+		words = append(words, "M4")
+	case CommandStop:
+		words = append(words, "M5")
+	}
+	if m.SpeedSet {
+		words = append(words, "S"+strconv.FormatFloat(m.Speed, 'f', -1, 64))
+	}
+	return strings.Join(words, " ")
+}
+
+// ModalState returns the last executed M3, M4 or M5 and S value, e.g. to show
+// "M3 S12000 active".
+func (o *HyInverter) ModalState() ModalState {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.modal
+}
+
+// SetDeferSpeed defers S words while the spindle is stopped until the next M3 or M4, which
+// sends the last S value first. Otherwise the frequency is sent at once, a stopped VFD keeps
+// it for the next start. Default: false.
+func (o *HyInverter) SetDeferSpeed(enabled bool) {
+	o.stateMutex.Lock()
+	o.deferSpeedEnabled = enabled
+	o.stateMutex.Unlock()
+}
+
+// DeferSpeed returns the setting of SetDeferSpeed.
+func (o *HyInverter) DeferSpeed() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.deferSpeedEnabled
+}
+
+// deferSpeed keeps the speed of an S word for the next start and returns true if
+// SetDeferSpeed is enabled and the spindle is stopped.
+func (o *HyInverter) deferSpeed(rpm float64) bool {
+	if !o.DeferSpeed() || o.running() {
+		return false
+	}
+	o.stateMutex.Lock()
+	o.modal.Speed = rpm
+	o.modal.SpeedSet = true
+	o.modal.Deferred = true
+	o.stateMutex.Unlock()
+	return true
+}
+
+// sendDeferredSpeed sends the speed kept by deferSpeed before a run command.
+func (o *HyInverter) sendDeferredSpeed(command ControlCommand) error {
+	if command != CommandRunForward && command != CommandRunBackward {
+		return nil
+	}
+	o.stateMutex.Lock()
+	deferred, rpm := o.modal.Deferred, o.modal.Speed
+	o.modal.Deferred = false
+	o.stateMutex.Unlock()
+	if !deferred {
+		return nil
+	}
+	return o.sendSpeed(rpm)
+}
+
+// setModalSpindle records an executed M3, M4 or M5, even if the VFD did not acknowledge it.
+func (o *HyInverter) setModalSpindle(command ControlCommand) {
+	if command != CommandRunForward && command != CommandRunBackward && command != CommandStop {
+		return
+	}
+	o.stateMutex.Lock()
+	o.modal.Spindle = command
+	o.stateMutex.Unlock()
+}
+
+// setModalSpeed records an executed S value.
+func (o *HyInverter) setModalSpeed(rpm float64) {
+	o.stateMutex.Lock()
+	o.modal.Speed = rpm
+	o.modal.SpeedSet = true
+	o.modal.Deferred = false
+	o.stateMutex.Unlock()
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModalState(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 24000
+	if state := hy.ModalState(); state != (ModalState{}) || state.String() != "" {
+		t.Fatalf("unexpected initial state %+v", state)
+	}
+	hy.GCode("M3 S12000")
+	hy.processNext()
+	hy.processNext()
+	if state := hy.ModalState(); state.String() != "M3 S12000" || state.Deferred {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if text := (ModalState{Spindle: CommandStop, Speed: 2500.5, SpeedSet: true}).String(); text != "M5 S2500.5" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestDeferSpeed(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 24000
+	hy.SetDeferSpeed(true)
+	hy.GCode("S6000")
+	hy.processNext()
+	if state := hy.ModalState(); state.String() != "S6000" || !state.Deferred {
+		t.Fatalf("speed not deferred: %+v", state)
+	}
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 0 {
+		t.Fatalf("deferred speed sent: %v", frequencies)
+	}
+	hy.GCode("M4")
+	hy.processNext()
+	expected, _ := hy.rpmToFrequency(6000)
+	if frequencies := sentFrequencies(port.Bytes()); !reflect.DeepEqual(frequencies, []uint16{expected}) {
+		t.Fatalf("expected the deferred speed with M4, got %v", frequencies)
+	}
+	if state := hy.ModalState(); state.String() != "M4 S6000" || state.Deferred {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	// Sent at once while running.
+	hy.GCode("S9000")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 2 {
+		t.Fatalf("speed not sent while running: %v", frequencies)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"log/slog"
+	"time"
+)
+
+// DefaultPollInterval is the interval of the status polls if WithPollInterval is not given.
+const DefaultPollInterval = 750 * time.Millisecond
+
+// DefaultQueueSize is the number of command words which can be queued if WithQueueSize is
+// not given.
+const DefaultQueueSize = 10
+
+// Option configures Open, OpenContext, OpenPort and OpenDriver. Options not given keep
+// their defaults, so new settings can be added without changing the callers.
+type Option func(*openSettings)
+
+// openSettings collects the options of an Open call.
+type openSettings struct {
+	maxRpm       uint16
+	rpmToHertz   float64
+	pollInterval time.Duration
+	baudRate     uint
+	slaveAddress byte
+	queueSize    int
+	logger       *slog.Logger
+	staleAfter   time.Duration
+	restore      bool
+}
+
+// WithMaxRpm sets the maximum allowed and output RPM, for instance 11520. It is lowered to
+// the maximum frequency of the VFD (PD005) if that is less. Default: PD005, or no limit if
+// the VFD can't be asked.
+func WithMaxRpm(maxRpm uint16) Option {
+	return func(s *openSettings) {
+		s.maxRpm = maxRpm
+	}
+}
+
+// WithRpmToHertz sets the frequency register value per RPM, i.e. in 0.01 Hz per RPM:
+// 3.47222 runs 11520 RPM at 400 Hz. Default: calculated from the rated motor RPM (PD144)
+// and base frequency (PD176) read from the VFD. OpenDriver requires it.
+func WithRpmToHertz(rpmToHertz float64) Option {
+	return func(s *openSettings) {
+		s.rpmToHertz = rpmToHertz
+	}
+}
+
+// WithPollInterval sets the interval of the status polls, e.g. of the output frequency.
+// Default: DefaultPollInterval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *openSettings) {
+		s.pollInterval = interval
+	}
+}
+
+// WithBaudRate sets the baud rate like SetBaudRate.
+func WithBaudRate(baudRate uint) Option {
+	return func(s *openSettings) {
+		s.baudRate = baudRate
+	}
+}
+
+// WithSlaveAddress sets the RS485 address of the VFD like SetSlaveAddress.
+func WithSlaveAddress(address byte) Option {
+	return func(s *openSettings) {
+		s.slaveAddress = address
+	}
+}
+
+// WithQueueSize sets the number of command words which can be queued, see QueueCapacity.
+// Streaming senders may buffer a few hundred S words, interactive applications keep it small
+// so commands are sent soon after they were given. Default: DefaultQueueSize.
+func WithQueueSize(size int) Option {
+	return func(s *openSettings) {
+		s.queueSize = size
+	}
+}
+
+// WithLogger sets a structured logger like SetLogger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *openSettings) {
+		s.logger = logger
+	}
+}
+
+// WithOnlineThreshold sets the threshold of Online like SetOnlineThreshold.
+func WithOnlineThreshold(threshold time.Duration) Option {
+	return func(s *openSettings) {
+		s.staleAfter = threshold
+	}
+}
+
+// WithRestoreRunState sends the last run or stop command again after a reconnect like
+// SetRestoreRunState.
+func WithRestoreRunState() Option {
+	return func(s *openSettings) {
+		s.restore = true
+	}
+}
+
+// newOpenSettings applies opts to the defaults.
+func newOpenSettings(opts []Option) openSettings {
+	settings := openSettings{pollInterval: DefaultPollInterval, queueSize: DefaultQueueSize}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	if settings.pollInterval <= 0 {
+		settings.pollInterval = DefaultPollInterval
+	}
+	if settings.queueSize <= 0 {
+		settings.queueSize = DefaultQueueSize
+	}
+	return settings
+}
+
+// settings applies opts to the configuration passed to NewVfdFromConfig.
+func (o *HyInverter) settings(opts []Option) openSettings {
+	return newOpenSettings(append(append([]Option(nil), o.configOptions...), opts...))
+}
+
+// apply stores the settings which are kept by the HyInverter.
+func (s openSettings) apply(o *HyInverter) {
+	if s.baudRate != 0 {
+		o.SetBaudRate(s.baudRate)
+	}
+	if s.slaveAddress != 0 {
+		o.SetSlaveAddress(s.slaveAddress)
+	}
+	if s.logger != nil {
+		o.SetLogger(s.logger)
+	}
+	if s.staleAfter != 0 {
+		o.SetOnlineThreshold(s.staleAfter)
+	}
+	if s.restore {
+		o.SetRestoreRunState(true)
+	}
+}
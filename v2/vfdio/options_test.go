@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestOpenSettings(t *testing.T) {
+	settings := newOpenSettings(nil)
+	if settings.pollInterval != DefaultPollInterval || settings.queueSize != DefaultQueueSize || settings.maxRpm != 0 || settings.rpmToHertz != 0 {
+		t.Errorf("unexpected defaults: %+v", settings)
+	}
+	settings = newOpenSettings([]Option{
+		WithMaxRpm(11520),
+		WithRpmToHertz(3.47222),
+		WithPollInterval(100 * time.Millisecond),
+		WithBaudRate(19200),
+		WithSlaveAddress(3),
+		WithQueueSize(200),
+		WithRestoreRunState(),
+	})
+	expected := openSettings{11520, 3.47222, 100 * time.Millisecond, 19200, 3, 200, nil, 0, true}
+	if settings != expected {
+		t.Errorf("expected %+v, got %+v", expected, settings)
+	}
+}
+
+func TestOpenPortOptions(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	if hy.QueueCapacity() != 0 {
+		t.Errorf("queue capacity %d before Open", hy.QueueCapacity())
+	}
+	if err := hy.OpenPort(vfd, WithMaxRpm(12000), WithBaudRate(19200), WithPollInterval(10*time.Second), WithQueueSize(300)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	if hy.QueueCapacity() != 300 {
+		t.Errorf("expected queue capacity 300, got %d", hy.QueueCapacity())
+	}
+	if hy.MaxRpm() != 12000 {
+		t.Errorf("expected max RPM 12000, got %d", hy.MaxRpm())
+	}
+	if hy.BaudRate() != 19200 {
+		t.Errorf("expected baud rate 19200, got %d", hy.BaudRate())
+	}
+	if hy.SlaveAddress() != 1 {
+		t.Errorf("slave address changed to %d without WithSlaveAddress", hy.SlaveAddress())
+	}
+}
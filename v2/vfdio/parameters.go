@@ -0,0 +1,180 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"math"
+	"time"
+)
+
+// PDxxx parameters used by vfdio.
+const (
+	// ParameterMaxFrequency is PD005, the maximum operating frequency in 0.01 Hz.
+	ParameterMaxFrequency byte = registers.PD005
+	// ParameterRatedMotorRpm is PD144, the rated motor RPM at the base frequency.
+	ParameterRatedMotorRpm byte = registers.PD144
+	// ParameterBaseFrequency is PD176, the inverter frequency: 0 = 50 Hz, 1 = 60 Hz.
+	ParameterBaseFrequency byte = registers.PD176
+	// ParameterAccelTime is PD014, the acceleration time 1 in 0.1 s.
+	ParameterAccelTime byte = registers.PD014
+	// ParameterDecelTime is PD015, the deceleration time 1 in 0.1 s.
+	ParameterDecelTime byte = registers.PD015
+)
+
+// ErrParameterTimeout is returned if the VFD did not answer a parameter read or write. It is
+// combined with ErrCRC if only corrupted frames were received in the meantime.
+var ErrParameterTimeout = errors.New("vfdio: parameter read not answered")
+
+// ErrOutOfRange is returned for values which don't fit into a parameter or register, e.g. by
+// SetAccelTime, SetDCBrake and ReadStatus.
+var ErrOutOfRange = errors.New("vfdio: value out of range")
+
+// parameterTimeout is the time to wait for the answer of a parameter read or write.
+const parameterTimeout = 500 * time.Millisecond
+
+type parameterValue struct {
+	function  Function
+	parameter byte
+	value     uint16
+}
+
+// readParameter sends a parameter read and waits for the answer.
+func (o *HyInverter) readParameter(parameter byte) (uint16, error) {
+	return o.parameterTransaction(FunctionReadParameter, parameter, 0)
+}
+
+// writeParameter sends a parameter write and checks the echo of the VFD.
+func (o *HyInverter) writeParameter(parameter byte, value uint16) error {
+	if err := o.checkWritable(); err != nil {
+		return err
+	}
+	echo, err := o.parameterTransaction(FunctionWriteParameter, parameter, value)
+	if err == nil && echo != value {
+		err = fmt.Errorf("vfdio: PD%03d: VFD stored %d instead of %d", parameter, echo, value)
+	}
+	return err
+}
+
+// parameterTransaction sends a parameter read or write and waits for the answer. The bus is
+// locked, so the processor goroutine does not send in between. Errors name the parameter.
+func (o *HyInverter) parameterTransaction(function Function, parameter byte, data uint16) (uint16, error) {
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	answer := make(chan parameterValue, 1)
+	o.stateMutex.Lock()
+	o.parameterAnswer = answer
+	o.stateMutex.Unlock()
+	defer func() {
+		o.stateMutex.Lock()
+		o.parameterAnswer = nil
+		o.stateMutex.Unlock()
+	}()
+	dataLength := byte(ReadParameterDataLength)
+	if function == FunctionWriteParameter {
+		dataLength = WriteParameterDataLength
+	}
+	crcErrors := o.Stats().CRCErrors
+	if err := o.write(o.signMessage([]byte{o.SlaveAddress(), byte(function), dataLength, parameter, byte(data >> 8), byte(data)})); err != nil {
+		return 0, fmt.Errorf("PD%03d: %w", parameter, err)
+	}
+	timeout := o.currentClock().NewTimer(parameterTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case received := <-answer:
+			if received.function == function && received.parameter == parameter {
+				return received.value, nil
+			}
+		case <-timeout.C():
+			if o.Stats().CRCErrors != crcErrors {
+				return 0, fmt.Errorf("PD%03d: %w: %w", parameter, ErrParameterTimeout, ErrCRC)
+			}
+			return 0, fmt.Errorf("PD%03d: %w", parameter, ErrParameterTimeout)
+		}
+	}
+}
+
+// parameterAnswered handles the answer of a parameter read or write.
+func (o *HyInverter) parameterAnswered(function Function, parameter byte, value uint16) {
+	o.stateMutex.Lock()
+	answer := o.parameterAnswer
+	o.stateMutex.Unlock()
+	if answer != nil {
+		select {
+		case answer <- parameterValue{function, parameter, value}:
+		default:
+		}
+	}
+	if function == FunctionReadParameter {
+		o.checkFault(parameter, value)
+	}
+}
+
+// deriveFrequencyPerRpm calculates the conversion factor from PD144 and PD176.
+func (o *HyInverter) deriveFrequencyPerRpm() (float64, error) {
+	ratedRpm, err := o.readParameter(ParameterRatedMotorRpm)
+	if err != nil {
+		return 0, err
+	}
+	if ratedRpm == 0 {
+		return 0, fmt.Errorf("vfdio: PD%03d is 0", ParameterRatedMotorRpm)
+	}
+	baseFrequency, err := o.readParameter(ParameterBaseFrequency)
+	if err != nil {
+		return 0, err
+	}
+	hertz := 50.0
+	if baseFrequency == 1 {
+		hertz = 60
+	}
+	return hertz / FrequencyResolution / float64(ratedRpm), nil
+}
+
+// limitMaxRpm lowers maxRpm to the maximum frequency configured in the VFD (PD005).
+// maxRpm is returned unchanged if PD005 can't be read.
+func (o *HyInverter) limitMaxRpm(maxRpm uint16) (uint16, error) {
+	maxFrequency, err := o.readParameter(ParameterMaxFrequency)
+	if err != nil {
+		return maxRpm, err
+	}
+	if limit := o.frequencyToRpm(maxFrequency); limit > 0 && (limit < maxRpm || maxRpm == 0) {
+		return limit, nil
+	}
+	return maxRpm, nil
+}
+
+// MaxRpm returns the RPM limit, the lower of the value passed to Open and the maximum
+// frequency of the VFD (PD005).
+func (o *HyInverter) MaxRpm() uint16 {
+	return o.maxRpm
+}
+
+// maxRampTime is the longest acceleration or deceleration time accepted by the VFD.
+const maxRampTime = 6000.0
+
+// SetAccelTime writes the acceleration time (PD014) in seconds, the time from 0 Hz to the
+// maximum frequency. It waits until the VFD confirmed the value.
+func (o *HyInverter) SetAccelTime(seconds float64) error {
+	return o.writeRampTime(ParameterAccelTime, seconds)
+}
+
+// SetDecelTime writes the deceleration time (PD015) in seconds, the time from the maximum
+// frequency to 0 Hz. It waits until the VFD confirmed the value.
+func (o *HyInverter) SetDecelTime(seconds float64) error {
+	return o.writeRampTime(ParameterDecelTime, seconds)
+}
+
+func (o *HyInverter) writeRampTime(parameter byte, seconds float64) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	if !(seconds > 0 && seconds <= maxRampTime) {
+		return fmt.Errorf("%w: PD%03d: ramp time %v s, allowed (0, %v]", ErrOutOfRange, parameter, seconds, maxRampTime)
+	}
+	return o.writeParameter(parameter, uint16(math.Floor(seconds*10+0.5)))
+}
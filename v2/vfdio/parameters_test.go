@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/mockport"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDeriveRpmToHertz(t *testing.T) {
+	for _, test := range []struct {
+		baseFrequency uint16
+		factor        float64
+	}{{0, 5000.0 / 3000}, {1, 6000.0 / 3000}} {
+		vfd := simulator.New()
+		vfd.Parameters[ParameterBaseFrequency] = test.baseFrequency
+		hy := NewVfd()
+		err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second))
+		hy.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(hy.frequencyPerRpm-test.factor) > 1e-9 {
+			t.Errorf("PD176 = %d: expected %v, got %v", test.baseFrequency, test.factor, hy.frequencyPerRpm)
+		}
+	}
+	// The manual value overrides the drive.
+	hy := NewVfd()
+	hy.OpenPort(simulator.New(), WithMaxRpm(24000), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second))
+	hy.Close()
+	if hy.frequencyPerRpm != 3.47222 {
+		t.Errorf("manual factor replaced by %v", hy.frequencyPerRpm)
+	}
+	vfd := simulator.New()
+	vfd.SetSilent(true)
+	hy = NewVfd()
+	err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second))
+	hy.Close()
+	if !errors.Is(err, ErrParameterTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestLimitMaxRpm(t *testing.T) {
+	for _, test := range []struct {
+		maxRpm, expected uint16
+	}{{24000, 11520}, {10000, 10000}, {0, 11520}} {
+		vfd := simulator.New()
+		hy := NewVfd()
+		err := hy.OpenPort(vfd, WithMaxRpm(test.maxRpm), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second))
+		hy.Close()
+		if err != nil || hy.MaxRpm() != test.expected {
+			t.Errorf("maxRpm %d: expected %d, got %d (%v)", test.maxRpm, test.expected, hy.MaxRpm(), err)
+		}
+	}
+}
+
+func TestSetRampTimes(t *testing.T) {
+	hy := NewVfd()
+	if err := hy.SetAccelTime(2); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+	vfd := simulator.New()
+	hy.OpenPort(vfd, WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second))
+	defer hy.Close()
+	if err := hy.SetAccelTime(2.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := hy.SetDecelTime(12); err != nil {
+		t.Fatal(err)
+	}
+	if vfd.Parameters[ParameterAccelTime] != 25 || vfd.Parameters[ParameterDecelTime] != 120 {
+		t.Fatalf("unexpected parameters %v", vfd.Parameters)
+	}
+	if err := hy.SetDecelTime(0); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange for 0 s, got %v", err)
+	}
+}
+
+func TestParameterTimeoutCRC(t *testing.T) {
+	corrupted := mockport.Frame(0x01, 0x01, 0x03, byte(ParameterMaxFrequency), 0x9C, 0x40)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	read := mockport.Exchange{
+		Request:  mockport.Frame(0x01, 0x01, 0x03, byte(ParameterMaxFrequency), 0x00, 0x00),
+		Response: corrupted,
+	}
+	port := mockport.New(read, read)
+	hy := NewVfd()
+	// Open keeps the maximum RPM if PD005 can't be read.
+	if err := hy.OpenPort(port, WithMaxRpm(11520), WithRpmToHertz(3.47222), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	_, err := hy.readParameter(ParameterMaxFrequency)
+	if !errors.Is(err, ErrParameterTimeout) || !errors.Is(err, ErrCRC) {
+		t.Fatalf("expected ErrParameterTimeout and ErrCRC, got %v", err)
+	}
+	if crcErrors := hy.Stats().CRCErrors; crcErrors != 2 {
+		t.Errorf("expected 2 CRC errors, got %d", crcErrors)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+// Pause holds back the queued commands like a feed hold, so an operator can hold a streamed
+// job without discarding it. A command which is being sent is finished, the following ones
+// stay queued until Resume; the status is still polled. Stop words are sent at once and
+// repeated in their place after Resume, so the held commands can't start the spindle
+// again. EStop stops the spindle at once and discards the queue. The pause is ended by
+// Close.
+func (o *HyInverter) Pause() error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	o.stateMutex.Lock()
+	o.paused = true
+	o.stateMutex.Unlock()
+	return nil
+}
+
+// PauseAt works like Pause, but also lowers the speed to rpm, e.g. to a safe speed while
+// the tool waits in the cut. The limits of the S words apply. A lower speed is kept. Resume
+// restores the speed.
+func (o *HyInverter) PauseAt(rpm uint16) error {
+	if err := o.checkWritable(); err != nil {
+		return err
+	}
+	if err := o.Pause(); err != nil {
+		return err
+	}
+	speed, _ := o.raiseToMinRpm(float64(rpm))
+	speed, _ = o.lowerToMaxRpm(speed)
+	frequency, _ := o.rpmToFrequency(speed)
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	target := o.TargetFrequency()
+	if target <= frequency {
+		return nil
+	}
+	o.stateMutex.Lock()
+	if !o.pauseLowered {
+		o.pauseLowered = true
+		o.pauseFrequency = target
+	}
+	o.stateMutex.Unlock()
+	return o.sendFrequency(frequency)
+}
+
+// Resume sends the commands held back by Pause. The speed lowered by PauseAt is restored
+// first.
+func (o *HyInverter) Resume() error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	o.stateMutex.Lock()
+	lowered, frequency := o.pauseLowered, o.pauseFrequency
+	o.pauseLowered = false
+	o.stateMutex.Unlock()
+	var err error
+	if lowered && !o.EStopped() {
+		o.busMutex.Lock()
+		err = o.sendFrequency(frequency)
+		o.busMutex.Unlock()
+	}
+	o.stateMutex.Lock()
+	o.paused = false
+	o.stateMutex.Unlock()
+	return err
+}
+
+// Paused returns true between Pause and Resume.
+func (o *HyInverter) Paused() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.paused
+}
+
+// holdCommand keeps a command which was taken from the queue while Pause was called, it is
+// executed after Resume, see holdBack. It returns false if the queue is not paused.
+func (o *HyInverter) holdCommand(command queuedCommand) bool {
+	if !o.Paused() {
+		return false
+	}
+	o.holdBack(command)
+	return true
+}
+
+// takeHeldCommand returns the first command kept by holdCommand or holdBack after Resume,
+// nil if there is none.
+func (o *HyInverter) takeHeldCommand() *queuedCommand {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if o.paused || len(o.heldCommands) == 0 {
+		return nil
+	}
+	command := o.heldCommands[0]
+	o.heldCommands = o.heldCommands[1:]
+	return &command
+}
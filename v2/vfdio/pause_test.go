@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPause(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 24000
+	hy.GCode("M3 S12000")
+	hy.processNext()
+	hy.processNext()
+	target := hy.TargetFrequency()
+	hy.GCode("S18000")
+	if err := hy.PauseAt(3000); err != nil {
+		t.Fatal(err)
+	}
+	safe, _ := hy.rpmToFrequency(3000)
+	if !hy.Paused() || hy.TargetFrequency() != safe {
+		t.Fatalf("paused %v at frequency %d", hy.Paused(), hy.TargetFrequency())
+	}
+	// The queued S18000 is held back, status polls continue.
+	hy.requestStatus(StatusOutputFrequency)
+	hy.processNext()
+	hy.processNext()
+	if hy.QueueDepth() != 1 || hy.TargetFrequency() != safe {
+		t.Fatalf("queue processed during the pause: depth %d, frequency %d", hy.QueueDepth(), hy.TargetFrequency())
+	}
+	sent := len(sentFrequencies(port.Bytes()))
+	if err := hy.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	hy.processNext()
+	final, _ := hy.rpmToFrequency(18000)
+	if frequencies := sentFrequencies(port.Bytes())[sent:]; !reflect.DeepEqual(frequencies, []uint16{target, final}) {
+		t.Errorf("expected the speed restored before the held command, got %v", frequencies)
+	}
+	if hy.Paused() || hy.QueueDepth() != 0 {
+		t.Errorf("not resumed: paused %v, depth %d", hy.Paused(), hy.QueueDepth())
+	}
+
+	// A command taken from the queue during Pause is kept.
+	hy.GCode("S6000")
+	command := <-hy.cmdChannel
+	hy.Pause()
+	hy.executeQueued(command)
+	if hy.QueueDepth() != 1 {
+		t.Fatalf("command not held: depth %d", hy.QueueDepth())
+	}
+	hy.EStop()
+	if hy.QueueDepth() != 0 || !hy.Paused() {
+		t.Errorf("held command not discarded by EStop: depth %d", hy.QueueDepth())
+	}
+}
+
+func TestPauseStop(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 24000
+	hy.SetStopEscalation(0, nil)
+	hy.GCode("M3 S12000")
+	hy.processNext()
+	hy.processNext()
+	hy.Pause()
+	hy.GCode("S18000 M5")
+	sent := len(port.Bytes())
+	hy.processNext()
+	hy.processNext()
+	stop := hy.controlFrame(CommandStop)
+	if !bytes.Equal(port.Bytes()[sent:], stop) {
+		t.Fatalf("expected only the stop to be sent during the pause, got % X", port.Bytes()[sent:])
+	}
+	if hy.QueueDepth() != 2 {
+		t.Fatalf("expected S18000 and the stop to be held, depth %d", hy.QueueDepth())
+	}
+	hy.Resume()
+	hy.processNext()
+	sent = len(port.Bytes())
+	hy.processNext()
+	if !bytes.Equal(port.Bytes()[sent:], stop) || hy.QueueDepth() != 0 {
+		t.Errorf("stop not repeated after the held command: % X", port.Bytes()[sent:])
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+)
+
+// ParameterMultiSpeed is PD080, the first multi-speed frequency of the HY series in 0.01 Hz.
+// The drive selects the multi-speed frequencies by its input terminals, see the manual.
+const ParameterMultiSpeed byte = registers.PD080
+
+// ErrUnknownPreset is returned by RunPreset for names not defined by DefinePreset.
+var ErrUnknownPreset = errors.New("vfdio: unknown preset")
+
+// Preset is a named speed, see DefinePreset.
+type Preset struct {
+	Name string
+	Rpm  uint16
+}
+
+// DefinePreset adds a named speed for one-button UIs like pendants, or changes its speed if
+// the name is already defined. Presets keep the order of their definition.
+func (o *HyInverter) DefinePreset(name string, rpm uint16) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	for i := range o.presets {
+		if o.presets[i].Name == name {
+			o.presets[i].Rpm = rpm
+			return
+		}
+	}
+	o.presets = append(o.presets, Preset{name, rpm})
+}
+
+// Presets returns the defined presets in the order of their definition.
+func (o *HyInverter) Presets() []Preset {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return append([]Preset(nil), o.presets...)
+}
+
+// RunPreset runs the spindle forward at the speed of a preset. It returns ErrUnknownPreset
+// if the name is not defined, otherwise it works like Enqueue.
+func (o *HyInverter) RunPreset(name string) error {
+	for _, preset := range o.Presets() {
+		if preset.Name == name {
+			return o.Enqueue(fmt.Sprintf("S%d M3", preset.Rpm))
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownPreset, name)
+}
+
+// WritePresets stores the presets in the multi-speed frequencies of the drive, the first
+// preset in parameter first, e.g. ParameterMultiSpeed, the next ones in the following
+// parameters. Then the drive's input terminals select them without the serial link.
+// It waits until the VFD confirmed each value. It returns ErrOutOfRange if the presets don't
+// fit into the parameters up to PD255.
+func (o *HyInverter) WritePresets(first byte) error {
+	if err := o.checkOpen(); err != nil {
+		return err
+	}
+	presets := o.Presets()
+	if last := int(first) + len(presets) - 1; last > 255 {
+		return fmt.Errorf("%w: %d presets from PD%03d end at PD%03d, allowed up to PD255", ErrOutOfRange, len(presets), first, last)
+	}
+	for i, preset := range presets {
+		frequency, _ := o.rpmToFrequency(float64(preset.Rpm))
+		if err := o.writeParameter(first+byte(i), frequency); err != nil {
+			return fmt.Errorf("preset %q: %w", preset.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestRunPreset(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.DefinePreset("rough", 18000)
+	hy.DefinePreset("finish", 24000)
+	hy.DefinePreset("rough", 12000)
+	if presets := hy.Presets(); len(presets) != 2 || presets[0] != (Preset{"rough", 12000}) {
+		t.Fatalf("unexpected presets %v", presets)
+	}
+	if err := hy.RunPreset("rough"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"S12000", "M3"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != expected {
+			t.Fatalf("expected %q, got %q", expected, cmd)
+		}
+	}
+	if err := hy.RunPreset("drill"); !errors.Is(err, ErrUnknownPreset) {
+		t.Fatalf("expected ErrUnknownPreset, got %v", err)
+	}
+}
+
+func TestWritePresets(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.DefinePreset("rough", 12000)
+	hy.DefinePreset("finish", 24000)
+	if err := hy.WritePresets(ParameterMultiSpeed); err != nil {
+		t.Fatal(err)
+	}
+	if vfd.Parameters[ParameterMultiSpeed] != 20000 || vfd.Parameters[ParameterMultiSpeed+1] != 40000 {
+		t.Fatalf("unexpected parameters %v", vfd.Parameters)
+	}
+}
+
+func TestWritePresetsRange(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.DefinePreset("rough", 12000)
+	hy.DefinePreset("finish", 24000)
+	if err := hy.WritePresets(255); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+	if sent := port.Bytes(); len(sent) != 0 {
+		t.Fatalf("parameters written: % X", sent)
+	}
+}
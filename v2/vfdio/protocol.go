@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+)
+
+// Function is the function code of a Huanyang message (second byte of each frame).
+type Function byte
+
+// Function codes of the Huanyang protocol.
+const (
+	FunctionReadParameter  Function = registers.FunctionReadParameter  // Read a PDxxx parameter
+	FunctionWriteParameter Function = registers.FunctionWriteParameter // Write a PDxxx parameter
+	FunctionControl        Function = registers.FunctionControl        // Write control data, answered with the control status
+	FunctionReadStatus     Function = registers.FunctionReadStatus     // Read control status, see StatusValue
+	FunctionSetFrequency   Function = registers.FunctionSetFrequency   // Write the set frequency in 0.01 Hz
+	FunctionLoopTest       Function = registers.FunctionLoopTest       // Loop back test
+)
+
+// Data lengths of the messages sent by vfdio. Answers use the same length.
+const (
+	ReadParameterDataLength  = registers.ReadParameterDataLength
+	WriteParameterDataLength = registers.WriteParameterDataLength
+	ControlDataLength        = registers.ControlDataLength
+	ReadStatusDataLength     = registers.ReadStatusDataLength
+	SetFrequencyDataLength   = registers.SetFrequencyDataLength
+)
+
+// ControlCommand is the data byte of a FunctionControl message. The bits can be combined.
+type ControlCommand byte
+
+// Control bits of FunctionControl.
+const (
+	ControlRun              ControlCommand = registers.ControlRun
+	ControlForward          ControlCommand = registers.ControlForward
+	ControlReverse          ControlCommand = registers.ControlReverse
+	ControlStop             ControlCommand = registers.ControlStop
+	ControlReverseDirection ControlCommand = registers.ControlReverseDirection
+	ControlJog              ControlCommand = registers.ControlJog
+	ControlJogForward       ControlCommand = registers.ControlJogForward
+	ControlJogReverse       ControlCommand = registers.ControlJogReverse
+)
+
+// ControlStatus is the data byte of the answer to a FunctionControl message.
+type ControlStatus byte
+
+// Status bits of the FunctionControl answer. The Command bits reflect the last command, the
+// others the state of the motor.
+const (
+	ControlStatusRunCommand     ControlStatus = registers.ControlStatusRunCommand
+	ControlStatusJogCommand     ControlStatus = registers.ControlStatusJogCommand
+	ControlStatusReverseCommand ControlStatus = registers.ControlStatusReverseCommand
+	ControlStatusRunning        ControlStatus = registers.ControlStatusRunning
+	ControlStatusJogging        ControlStatus = registers.ControlStatusJogging
+	ControlStatusReverse        ControlStatus = registers.ControlStatusReverse
+	ControlStatusBraking        ControlStatus = registers.ControlStatusBraking
+	ControlStatusTrackStart     ControlStatus = registers.ControlStatusTrackStart
+)
+
+// Control commands sent for M3, M4 and M5.
+const (
+	CommandRunForward  = ControlRun
+	CommandRunBackward = ControlRun | ControlReverseDirection
+	CommandStop        = ControlStop
+)
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ramp defaults. A step can't be shorter than the processing time of a frequency write.
+const (
+	defaultRampInterval = 200 * time.Millisecond
+	minRampInterval     = 110 * time.Millisecond
+)
+
+// RampProfile describes the software ramp of S commands, see SetRamp.
+type RampProfile struct {
+	// Acceleration is the speed change in RPM per second, for acceleration and deceleration.
+	// 0 disables the ramp.
+	Acceleration float64
+	// Interval is the time between two frequency writes. Default: 200 ms.
+	Interval time.Duration
+	// Threshold is the largest speed change in RPM which is sent at once.
+	Threshold uint16
+}
+
+// SetRamp enables the software ramp for spindles whose VFD acceleration settings can't be
+// changed. While the spindle runs, S commands which change the speed by more than the
+// threshold are sent as a sequence of intermediate frequencies, so the speed follows a
+// trapezoidal profile with the given acceleration. The VFD's own ramp (PD014, PD015) still
+// applies between the steps and to M3, M4 and M5. Control commands and status reads wait
+// while a ramp runs. A ramp is cut short at the reached speed by a following S or stop
+// command. Default: disabled.
+func (o *HyInverter) SetRamp(profile RampProfile) {
+	o.stateMutex.Lock()
+	o.ramp = profile
+	o.stateMutex.Unlock()
+}
+
+// Ramp returns the profile set by SetRamp.
+func (o *HyInverter) Ramp() RampProfile {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.ramp
+}
+
+// preempts returns true if a queued word cuts a running ramp short.
+func preempts(word lineWord) bool {
+	text := strings.ToLower(word.text)
+	command, ok := controlCommand(text)
+	return ok && command == CommandStop || strings.HasPrefix(text, "s") || word.internal && strings.HasPrefix(text, hertzWordPrefix)
+}
+
+// running returns true if the last control command started the spindle.
+func (o *HyInverter) running() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.lastControlFrame != nil && ControlCommand(o.lastControlFrame[3]) != CommandStop
+}
+
+// rampTo sends the intermediate frequencies from the last set frequency to target.
+// It returns false if the ramp was cut short by a following command, so target must not
+// be sent anymore.
+func (o *HyInverter) rampTo(target uint16) bool {
+	profile := o.Ramp()
+	if profile.Acceleration <= 0 || !o.running() {
+		return true
+	}
+	o.pollMutex.Lock()
+	from := float64(o.setFrequency)
+	o.pollMutex.Unlock()
+	delta := float64(target) - from
+	threshold, _ := o.rpmToFrequency(float64(profile.Threshold))
+	if math.Abs(delta) <= float64(threshold) {
+		return true
+	}
+	interval := profile.Interval
+	if interval <= 0 {
+		interval = defaultRampInterval
+	} else if interval < minRampInterval {
+		interval = minRampInterval
+	}
+	duration := math.Abs(delta) / (profile.Acceleration * o.frequencyPerRpm)
+	steps := int(math.Ceil(duration / interval.Seconds()))
+	for i := 1; i < steps; i++ {
+		if atomic.LoadInt32(&o.preemptions) > 0 || o.stopped() || o.EStopped() {
+			return false
+		}
+		o.sendFrequency(uint16(math.Floor(from + delta*float64(i)/float64(steps) + 0.5)))
+		o.sleep(interval - minRampInterval)
+	}
+	return atomic.LoadInt32(&o.preemptions) <= 0 && !o.EStopped()
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// sentFrequencies returns the values of the set frequency frames written to the port.
+func sentFrequencies(frames []byte) (frequencies []uint16) {
+	for len(frames) >= 6 {
+		length := int(frames[2]) + 5
+		if Function(frames[1]) == FunctionSetFrequency {
+			frequencies = append(frequencies, binary.BigEndian.Uint16(frames[3:5]))
+		}
+		frames = frames[length:]
+	}
+	return
+}
+
+func TestRamp(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetRamp(RampProfile{Acceleration: 6000, Interval: 110 * time.Millisecond, Threshold: 500})
+	// The test port never answers.
+	hy.SetCircuitBreaker(0, 0)
+	hy.GCode("M3")
+	hy.processNext()
+	// 3000 RPM at 6000 RPM/s take 0.5 s, 5 steps of 110 ms.
+	hy.GCode("S3000")
+	hy.processNext()
+	expected := []uint16{2083, 4167, 6250, 8334, 10417}
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != len(expected) {
+		t.Fatalf("unexpected ramp %v", frequencies)
+	} else {
+		for i := range expected {
+			if frequencies[i] != expected[i] {
+				t.Fatalf("unexpected ramp %v", frequencies)
+			}
+		}
+	}
+	// Changes within the threshold are sent at once.
+	sent := len(port.Bytes())
+	hy.GCode("S3400")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()[sent:]); len(frequencies) != 1 || frequencies[0] != 11806 {
+		t.Fatalf("unexpected ramp %v", frequencies)
+	}
+}
+
+func TestRampNotRunning(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetRamp(RampProfile{Acceleration: 6000})
+	hy.GCode("S3000")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 1 {
+		t.Fatalf("stopped spindle ramped: %v", frequencies)
+	}
+}
+
+func TestRampPreempted(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetRamp(RampProfile{Acceleration: 6000})
+	hy.SetStopEscalation(0, nil)
+	hy.GCode("M3")
+	hy.processNext()
+	hy.GCode("S3000 M5")
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 0 {
+		t.Fatalf("ramp not cut short: %v", frequencies)
+	}
+	hy.processNext()
+	if frames := port.Bytes(); frames[len(frames)-3] != byte(CommandStop) {
+		t.Fatalf("stop not sent: % X", frames)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import "errors"
+
+// ErrReadOnly is returned for commands and parameter writes in read-only mode.
+var ErrReadOnly = errors.New("vfdio: read-only mode")
+
+// SetReadOnly selects the monitoring mode, e.g. for a dashboard watching a VFD which is
+// commanded by another controller. Only status values, parameters and, with
+// SetRunStatePolling, the run state are read. GCode returns false, Enqueue, StreamProgram,
+// EStop and the parameter writes return ErrReadOnly, and any other frame is dropped.
+// SetOpenState is ignored. Call it before Open. Default: false.
+func (o *HyInverter) SetReadOnly(enabled bool) {
+	o.stateMutex.Lock()
+	o.readOnly = enabled
+	o.stateMutex.Unlock()
+}
+
+// ReadOnly returns true in monitoring mode, see SetReadOnly.
+func (o *HyInverter) ReadOnly() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.readOnly
+}
+
+// checkWritable returns ErrReadOnly in monitoring mode.
+func (o *HyInverter) checkWritable() error {
+	if o.ReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// checkReadOnlyWords returns ErrReadOnly in monitoring mode if the words of a line contain
+// anything but status requests.
+func (o *HyInverter) checkReadOnlyWords(words []string) error {
+	for _, word := range words {
+		if word != "?" {
+			return o.checkWritable()
+		}
+	}
+	return nil
+}
+
+// isReadFrame returns true for frames which do not change the state of the VFD. A control
+// frame without command bits only reads the control status.
+func isReadFrame(frame []byte) bool {
+	switch Function(frame[1]) {
+	case FunctionReadStatus, FunctionReadParameter:
+		return true
+	case FunctionControl:
+		return frame[3] == 0
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetReadOnly(true)
+	hy.SetRunStatePolling(true)
+	if err := hy.Enqueue("S1000 M3"); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := hy.Enqueue("?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hy.StreamProgram(context.Background(), strings.NewReader("M3\n"), StreamOptions{}); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := hy.EStop(); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := hy.writeParameter(ParameterAccelTime, 10); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	// Queued before, e.g. by the keepalive watchdog.
+	hy.queue(lineWord{text: "M5"}, nil)
+	hy.processNext()
+	if len(port.Bytes()) != 0 {
+		t.Fatalf("control frame sent: % X", port.Bytes())
+	}
+	hy.readStatus(StatusOutputFrequency)
+	hy.readStatus(pollRunState)
+	if frames := port.Bytes(); len(frames) != 8+6 {
+		t.Fatalf("reads not sent: % X", frames)
+	}
+}
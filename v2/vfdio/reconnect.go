@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Consecutive read or write errors which trigger a reconnect.
+const reconnectErrorThreshold = 3
+
+// Backoff between attempts to open the port again.
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 5 * time.Second
+)
+
+func (o *HyInverter) currentPort() io.ReadWriteCloser {
+	o.portMutex.Lock()
+	defer o.portMutex.Unlock()
+	return o.port
+}
+
+// ErrPortClosed is returned if a frame can't be written to the serial port, e.g. because the
+// adapter was unplugged or the port is reopened. It wraps the error of the port.
+var ErrPortClosed = errors.New("vfdio: serial port closed")
+
+// write sends a frame through the driver.
+func (o *HyInverter) write(frame []byte) error {
+	if o.stopped() {
+		// The port is closed.
+		return ErrClosed
+	}
+	return o.currentDriver().writeFrame(frame)
+}
+
+// portFailed counts an error of the given port and requests a reconnect if they persist.
+// Errors of a port which was already replaced are ignored.
+func (o *HyInverter) portFailed(port io.ReadWriteCloser, err error) {
+	if port != o.currentPort() || o.reconnectChannel == nil {
+		return
+	}
+	if atomic.AddInt32(&o.portErrors, 1) < reconnectErrorThreshold {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&o.reconnecting, 0, 1) {
+		o.emit(Event{Type: Disconnected, Err: err})
+		o.reconnectChannel <- struct{}{}
+	}
+}
+
+// SetRestoreRunState selects whether the last run or stop command is sent again after a
+// reconnect. The VFD may have been stopped at its panel or by an interlock meanwhile, so
+// only the set frequency is restored by default. See WithRestoreRunState.
+func (o *HyInverter) SetRestoreRunState(restore bool) {
+	o.stateMutex.Lock()
+	o.restoreRunState = restore
+	o.stateMutex.Unlock()
+}
+
+// RestoreRunState returns true if the run state is restored after a reconnect.
+func (o *HyInverter) RestoreRunState() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.restoreRunState
+}
+
+// reconnector closes a failed port and opens it again with an increasing delay between the tries.
+// The last set frequency is sent again after a successful reconnect, the run state only if
+// SetRestoreRunState is enabled.
+func reconnector(handle *HyInverter) {
+	for !handle.stopped() {
+		select {
+		case <-handle.reconnectChannel:
+		case <-handle.done:
+			return
+		}
+		handle.currentPort().Close()
+		delay := minReconnectDelay
+		for handle.sleep(delay) {
+			port, err := handle.dial()
+			if err == nil {
+				handle.portMutex.Lock()
+				handle.port = port
+				handle.portMutex.Unlock()
+				atomic.StoreInt32(&handle.portErrors, 0)
+				atomic.StoreInt32(&handle.reconnecting, 0)
+				break
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+		if handle.stopped() {
+			return
+		}
+		// Commands of the processor wait until the state is restored.
+		handle.txMutex.Lock()
+		handle.stateMutex.Lock()
+		frames := [][]byte{handle.lastFrequencyFrame}
+		if handle.restoreRunState {
+			frames = append(frames, handle.lastControlFrame)
+		}
+		handle.stateMutex.Unlock()
+		port := handle.currentPort()
+		for _, frame := range frames {
+			if frame == nil {
+				continue
+			}
+			if wire, err := handle.toWire(frame); err == nil {
+				port.Write(wire)
+				handle.logFrame(Transmitted, wire, "")
+				handle.sleep(time.Millisecond * 110)
+			}
+		}
+		handle.txMutex.Unlock()
+		handle.emit(Event{Type: Reconnected})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unpluggedPort fails like the device node of a removed USB adapter.
+type unpluggedPort struct{}
+
+var errUnplugged = errors.New("input/output error")
+
+func (unpluggedPort) Read(b []byte) (int, error)  { return 0, errUnplugged }
+func (unpluggedPort) Write(b []byte) (int, error) { return 0, errUnplugged }
+func (unpluggedPort) Close() error                { return nil }
+
+func TestReconnect(t *testing.T) {
+	for _, restoreRunState := range []bool{false, true} {
+		testReconnect(t, restoreRunState)
+	}
+}
+
+func testReconnect(t *testing.T, restoreRunState bool) {
+	hy, port := newTestInverter()
+	hy.SetRestoreRunState(restoreRunState)
+	hy.reconnectChannel = make(chan struct{}, 1)
+	hy.GCode("S3000 M3")
+	hy.processNext()
+	// Only the set frequency is restored by default.
+	restore := port.Bytes()
+	hy.processNext()
+	if restoreRunState {
+		restore = port.Bytes()
+	}
+
+	replugged := &testPort{}
+	dials := 0
+	hy.dial = func() (io.ReadWriteCloser, error) {
+		if dials++; dials < 3 {
+			return nil, errUnplugged
+		}
+		return replugged, nil
+	}
+	events := make(chan Event, 10)
+	hy.Subscribe(func(e Event) {
+		if e.Type == Disconnected || e.Type == Reconnected {
+			events <- e
+		}
+	})
+	hy.port = unpluggedPort{}
+	go parser(hy)
+	go reconnector(hy)
+	defer func() { atomic.StoreInt32(&hy.stop, 1) }()
+
+	for _, expected := range []EventType{Disconnected, Reconnected} {
+		select {
+		case e := <-events:
+			if e.Type != expected {
+				t.Fatalf("expected %v, got %v", expected, e.Type)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no %v event", expected)
+		}
+	}
+	if dials != 3 {
+		t.Errorf("expected 3 dials, got %d", dials)
+	}
+	if !bytes.Equal(replugged.Bytes(), restore) {
+		t.Errorf("state not restored: % X, expected % X", replugged.Bytes(), restore)
+	}
+}
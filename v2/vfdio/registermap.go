@@ -0,0 +1,290 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+)
+
+// ErrUnsupported is returned for requests which the register map of the drive can't express,
+// e.g. PDxxx parameters of the classic protocol on a standard Modbus drive.
+var ErrUnsupported = errors.New("vfdio: not supported by the register map")
+
+// RegisterMap translates between the classic Huanyang protocol, which the library uses
+// internally, and the frames of a drive series. Frames are passed without CRC. Select it
+// with SetRegisterMap.
+type RegisterMap interface {
+	// Name identifies the register map.
+	Name() string
+	// Request converts a classic request into the request of the drive. It returns
+	// ErrUnsupported if the drive has no equivalent.
+	Request(classic []byte) ([]byte, error)
+	// Response converts the answer of the drive to the classic answer of the classic
+	// request. Ok is false if the answer does not belong to the request.
+	Response(classic, answer []byte) (converted []byte, ok bool)
+	// FrameLength returns the length including CRC of the answer at the start of buf, or 0
+	// if buf does not start with an answer. buf holds at least 3 bytes.
+	FrameLength(buf []byte) int
+}
+
+// ClassicMap is the custom protocol of the HY02D223B and similar drives. It is the default.
+type ClassicMap struct{}
+
+// Name returns "classic".
+func (ClassicMap) Name() string {
+	return "classic"
+}
+
+// Request returns the classic request unchanged.
+func (ClassicMap) Request(classic []byte) ([]byte, error) {
+	return classic, nil
+}
+
+// Response returns the answer unchanged.
+func (ClassicMap) Response(classic, answer []byte) ([]byte, bool) {
+	return answer, true
+}
+
+// FrameLength reads the length byte of the answer.
+func (ClassicMap) FrameLength(buf []byte) int {
+	if buf[2] > registers.MaxDataLength {
+		return 0
+	}
+	return registers.FrameLength(int(buf[2]))
+}
+
+// Standard Modbus function codes used by ModbusMap.
+const (
+	modbusReadRegisters = 0x03
+	modbusWriteRegister = 0x06
+)
+
+// Control words and run states of ModbusMap.
+const (
+	ModbusControlForward    = 1
+	ModbusControlReverse    = 2
+	ModbusControlJogForward = 3
+	ModbusControlJogReverse = 4
+	ModbusControlStop       = 5
+
+	ModbusStateForward = 1
+	ModbusStateReverse = 2
+	ModbusStateStopped = 3
+)
+
+// ModbusMap is the standard Modbus RTU protocol of the newer Huanyang series. Commands are
+// written with function 0x06 and the status is read with function 0x03. The set frequency
+// is written in 0.01 % of the maximum frequency. PDxxx parameters are not supported, so
+// pass the RPM conversion factor to Open. Check the register addresses with the manual
+// of the drive.
+type ModbusMap struct {
+	// MaxFrequency is the maximum frequency of the drive in 0.01 Hz, the 100 % of the
+	// frequency register.
+	MaxFrequency uint16
+	// ControlRegister takes the control words, e.g. ModbusControlForward.
+	ControlRegister uint16
+	// FrequencyRegister takes the set frequency in 0.01 %.
+	FrequencyRegister uint16
+	// StateRegister holds the run state, e.g. ModbusStateForward.
+	StateRegister uint16
+	// StatusRegister holds the first status value, StatusSetFrequency. The others follow
+	// in the order of StatusValue.
+	StatusRegister uint16
+}
+
+// NewModbusMap returns the register map of the newer series with the default registers:
+// control 0x2000, frequency 0x1000, run state 0x3000 and status values from 0x3001.
+func NewModbusMap(maxFrequency uint16) *ModbusMap {
+	return &ModbusMap{
+		MaxFrequency:      maxFrequency,
+		ControlRegister:   0x2000,
+		FrequencyRegister: 0x1000,
+		StateRegister:     0x3000,
+		StatusRegister:    0x3001,
+	}
+}
+
+// Name returns "modbus".
+func (m *ModbusMap) Name() string {
+	return "modbus"
+}
+
+// Request converts control, set frequency and status requests.
+func (m *ModbusMap) Request(classic []byte) ([]byte, error) {
+	address := classic[0]
+	switch Function(classic[1]) {
+	case FunctionControl:
+		command := ControlCommand(classic[3])
+		if command == 0 {
+			return modbusFrame(address, modbusReadRegisters, m.StateRegister, 1), nil
+		}
+		word, ok := modbusControlWord(command)
+		if !ok {
+			return nil, fmt.Errorf("%w: control command %#02x", ErrUnsupported, byte(command))
+		}
+		return modbusFrame(address, modbusWriteRegister, m.ControlRegister, word), nil
+	case FunctionSetFrequency:
+		if m.MaxFrequency == 0 {
+			return nil, fmt.Errorf("%w: maximum frequency not set", ErrUnsupported)
+		}
+		return modbusFrame(address, modbusWriteRegister, m.FrequencyRegister, m.percent(binary.BigEndian.Uint16(classic[3:5]))), nil
+	case FunctionReadStatus:
+		return modbusFrame(address, modbusReadRegisters, m.StatusRegister+uint16(classic[3]), 1), nil
+	}
+	return nil, fmt.Errorf("%w: function %#02x", ErrUnsupported, classic[1])
+}
+
+// Response converts the answers of Request.
+func (m *ModbusMap) Response(classic, answer []byte) ([]byte, bool) {
+	address := classic[0]
+	read := len(answer) == 5 && answer[1] == modbusReadRegisters && answer[2] == 2
+	written := len(answer) == 6 && answer[1] == modbusWriteRegister
+	switch Function(classic[1]) {
+	case FunctionControl:
+		command := ControlCommand(classic[3])
+		if command == 0 && read {
+			return []byte{address, byte(FunctionControl), ControlDataLength, byte(modbusRunState(binary.BigEndian.Uint16(answer[3:5])))}, true
+		}
+		if command != 0 && written {
+			return []byte{address, byte(FunctionControl), ControlDataLength, byte(commandedState(command))}, true
+		}
+	case FunctionSetFrequency:
+		if written {
+			frequency := binary.BigEndian.Uint16(classic[3:5])
+			if value := binary.BigEndian.Uint16(answer[4:6]); value != m.percent(frequency) {
+				frequency = uint16(uint32(value) * uint32(m.MaxFrequency) / 10000)
+			}
+			return []byte{address, byte(FunctionSetFrequency), SetFrequencyDataLength, byte(frequency >> 8), byte(frequency)}, true
+		}
+	case FunctionReadStatus:
+		if read {
+			return []byte{address, byte(FunctionReadStatus), ReadStatusDataLength, classic[3], answer[3], answer[4]}, true
+		}
+	}
+	return nil, false
+}
+
+// FrameLength knows the answers of function 0x03 and 0x06 and exceptions.
+func (m *ModbusMap) FrameLength(buf []byte) int {
+	switch {
+	case buf[1] == modbusReadRegisters && buf[2] <= registers.MaxDataLength:
+		return registers.FrameLength(int(buf[2]))
+	case buf[1] == modbusWriteRegister:
+		return 8
+	case buf[1]&0x80 != 0:
+		return 5
+	}
+	return 0
+}
+
+// percent converts a frequency in 0.01 Hz to 0.01 % of the maximum frequency.
+func (m *ModbusMap) percent(frequency uint16) uint16 {
+	value := (uint32(frequency)*10000 + uint32(m.MaxFrequency)/2) / uint32(m.MaxFrequency)
+	if value > 10000 {
+		value = 10000
+	}
+	return uint16(value)
+}
+
+func modbusFrame(address, function byte, register, value uint16) []byte {
+	return []byte{address, function, byte(register >> 8), byte(register), byte(value >> 8), byte(value)}
+}
+
+func modbusControlWord(command ControlCommand) (uint16, bool) {
+	switch command {
+	case CommandRunForward:
+		return ModbusControlForward, true
+	case CommandRunBackward:
+		return ModbusControlReverse, true
+	case ControlJogForward:
+		return ModbusControlJogForward, true
+	case ControlJogReverse:
+		return ModbusControlJogReverse, true
+	case CommandStop:
+		return ModbusControlStop, true
+	}
+	return 0, false
+}
+
+// modbusRunState converts the run state register to the classic control status.
+func modbusRunState(state uint16) ControlStatus {
+	switch state {
+	case ModbusStateForward:
+		return ControlStatusRunCommand | ControlStatusRunning
+	case ModbusStateReverse:
+		return ControlStatusRunCommand | ControlStatusReverseCommand | ControlStatusRunning | ControlStatusReverse
+	}
+	return 0
+}
+
+// commandedState returns the control status expected after a command, the write echo of
+// the drive does not report it.
+func commandedState(command ControlCommand) ControlStatus {
+	switch command {
+	case CommandRunForward:
+		return modbusRunState(ModbusStateForward)
+	case CommandRunBackward:
+		return modbusRunState(ModbusStateReverse)
+	case ControlJogForward:
+		return ControlStatusJogCommand | ControlStatusJogging
+	case ControlJogReverse:
+		return ControlStatusJogCommand | ControlStatusReverseCommand | ControlStatusJogging | ControlStatusReverse
+	}
+	return 0
+}
+
+// SetRegisterMap selects the protocol of the drive, e.g. NewModbusMap for the newer series.
+// nil selects ClassicMap. Call it before Open. Default: ClassicMap.
+func (o *HyInverter) SetRegisterMap(m RegisterMap) {
+	o.stateMutex.Lock()
+	o.registerMap = m
+	o.stateMutex.Unlock()
+}
+
+// RegisterMap returns the register map selected by SetRegisterMap.
+func (o *HyInverter) RegisterMap() RegisterMap {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if o.registerMap == nil {
+		return ClassicMap{}
+	}
+	return o.registerMap
+}
+
+// toWire converts a signed classic frame into the signed frame of the drive.
+func (o *HyInverter) toWire(frame []byte) ([]byte, error) {
+	m := o.RegisterMap()
+	if _, classic := m.(ClassicMap); classic {
+		return frame, nil
+	}
+	request, err := m.Request(frame[:len(frame)-2])
+	if err != nil {
+		return nil, err
+	}
+	return o.signMessage(request), nil
+}
+
+// fromWire converts a signed answer of the drive into the signed classic answer of the
+// last request.
+func (o *HyInverter) fromWire(frame []byte) ([]byte, bool) {
+	m := o.RegisterMap()
+	if _, classic := m.(ClassicMap); classic {
+		return frame, true
+	}
+	o.stateMutex.Lock()
+	request := o.pendingRequest
+	o.stateMutex.Unlock()
+	if request == nil {
+		return nil, false
+	}
+	converted, ok := m.Response(request[:len(request)-2], frame[:len(frame)-2])
+	if !ok {
+		return nil, false
+	}
+	return o.signMessage(converted), true
+}
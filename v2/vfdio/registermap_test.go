@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestModbusMapRequests(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetRegisterMap(NewModbusMap(40000))
+	hy.frequencyPerRpm = 10000.0 / 6000
+	frames, err := hy.EncodeCommand("M3 S12000 M5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{
+		{0x01, 0x06, 0x20, 0x00, 0x00, 0x01},
+		// 12000 RPM = 200 Hz = 50 % of 400 Hz
+		{0x01, 0x06, 0x10, 0x00, 0x13, 0x88},
+		{0x01, 0x06, 0x20, 0x00, 0x00, 0x05},
+	}
+	for i, frame := range frames {
+		if !bytes.Equal(frame, hy.signMessage(expected[i])) {
+			t.Fatalf("frame %d: unexpected % X", i, frame)
+		}
+	}
+	if _, err := hy.readParameter(ParameterMaxFrequency); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestModbusMapResponses(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.SetRegisterMap(NewModbusMap(40000))
+	hy.SetRunStatePolling(true)
+	hy.frequencyPerRpm = 10000.0 / 6000
+	// Set frequency echo
+	hy.GCode("S12000")
+	hy.processNext()
+	echo := port.Bytes()
+	frame, rest := hy.nextFrame(echo)
+	if len(frame) != 8 || len(rest) != 0 {
+		t.Fatalf("echo not framed: % X", echo)
+	}
+	classic, ok := hy.fromWire(frame)
+	if !ok || !parseModbusRTU(hy, classic) {
+		t.Fatalf("echo not converted: % X", classic)
+	}
+	if frequency, confirmed := hy.AcceptedFrequency(); frequency != 20000 || !confirmed {
+		t.Fatalf("echo %d, confirmed %v", frequency, confirmed)
+	}
+	// Run state
+	hy.readStatus(pollRunState)
+	classic, ok = hy.fromWire(hy.signMessage([]byte{0x01, 0x03, 0x02, 0x00, ModbusStateReverse}))
+	if !ok || !parseModbusRTU(hy, classic) || !hy.Running() || hy.Direction() {
+		t.Fatalf("run state not converted: % X", classic)
+	}
+	// Status value
+	hy.readStatus(StatusOutputCurrent)
+	classic, ok = hy.fromWire(hy.signMessage([]byte{0x01, 0x03, 0x02, 0x00, 0x2A}))
+	if !ok || !parseModbusRTU(hy, classic) || hy.OutputCurrentAmps() != 4.2 {
+		t.Fatalf("status not converted: % X", classic)
+	}
+}
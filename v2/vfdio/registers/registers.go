@@ -0,0 +1,207 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package registers names the function codes, control bits, status values and PDxxx
+// parameters of the Huanyang protocol and describes their scaling, units and access.
+// It has no dependencies, so drivers, simulators and tools can share the numbers with vfdio.
+//
+// All messages have the format: address, function, data length, data, 2 byte CRC.
+//
+//   p, _ := registers.Parameter(registers.PD005)
+//   fmt.Println(p.Name, p.Value(40000), p.Unit) // max frequency 400 Hz
+//
+package registers
+
+import (
+	"fmt"
+)
+
+// Function codes.
+const (
+	FunctionReadParameter  = 0x01 // Read a PDxxx parameter
+	FunctionWriteParameter = 0x02 // Write a PDxxx parameter
+	FunctionControl        = 0x03 // Write control data, answered with the control status
+	FunctionReadStatus     = 0x04 // Read a status value
+	FunctionSetFrequency   = 0x05 // Write the set frequency in 0.01 Hz
+	FunctionLoopTest       = 0x08 // Loop back test
+)
+
+// Data lengths of the requests. Answers use the same length.
+const (
+	ReadParameterDataLength  = 0x03
+	WriteParameterDataLength = 0x03
+	ControlDataLength        = 0x01
+	ReadStatusDataLength     = 0x03
+	SetFrequencyDataLength   = 0x02
+
+	// MaxDataLength is the longest data field of the protocol's messages.
+	MaxDataLength = 8
+)
+
+// FrameLength returns the length of a message with the given data length: address,
+// function and length byte, the data and the CRC.
+func FrameLength(dataLength int) int {
+	return dataLength + 5
+}
+
+// Control bits of FunctionControl. The bits can be combined.
+const (
+	ControlRun              = 0x01
+	ControlForward          = 0x02
+	ControlReverse          = 0x04
+	ControlStop             = 0x08
+	ControlReverseDirection = 0x10
+	ControlJog              = 0x20
+	ControlJogForward       = 0x40
+	ControlJogReverse       = 0x80
+)
+
+// Status bits of the FunctionControl answer. The Command bits reflect the last command, the
+// others the state of the motor.
+const (
+	ControlStatusRunCommand     = 0x01
+	ControlStatusJogCommand     = 0x02
+	ControlStatusReverseCommand = 0x04
+	ControlStatusRunning        = 0x08
+	ControlStatusJogging        = 0x10
+	ControlStatusReverse        = 0x20
+	ControlStatusBraking        = 0x40
+	ControlStatusTrackStart     = 0x80
+)
+
+// Status values of FunctionReadStatus.
+const (
+	StatusSetFrequency    = 0x00
+	StatusOutputFrequency = 0x01
+	StatusOutputCurrent   = 0x02
+	StatusRpm             = 0x03
+	StatusDCVoltage       = 0x04
+	StatusACVoltage       = 0x05
+	StatusCounter         = 0x06
+	StatusTemperature     = 0x07
+
+	// StatusValueCount is the number of status values.
+	StatusValueCount = 8
+)
+
+// PDxxx parameters of the HY series used by vfdio.
+const (
+	PD005 = 5   // Maximum operating frequency
+	PD011 = 11  // Minimum operating frequency
+	PD014 = 14  // Acceleration time 1
+	PD015 = 15  // Deceleration time 1
+	PD026 = 26  // Stop mode
+	PD028 = 28  // DC braking time at stop
+	PD029 = 29  // DC braking start frequency
+	PD030 = 30  // DC braking voltage
+	PD080 = 80  // First multi-speed frequency
+	PD141 = 141 // Rated motor voltage
+	PD142 = 142 // Rated motor current
+	PD143 = 143 // Number of motor poles
+	PD144 = 144 // Rated motor RPM
+	PD176 = 176 // Inverter (base) frequency
+)
+
+// Access tells whether a register can be read, written or both.
+type Access int
+
+// Access modes.
+const (
+	ReadOnly Access = iota + 1
+	WriteOnly
+	ReadWrite
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadOnly:
+		return "r"
+	case WriteOnly:
+		return "w"
+	case ReadWrite:
+		return "rw"
+	}
+	return fmt.Sprintf("Access(%d)", int(a))
+}
+
+// Register describes a value of the protocol.
+type Register struct {
+	// Function is the function code which reads or writes the register.
+	Function byte
+	// Number is the status value or the parameter number. 0 for registers without a number.
+	Number byte
+	Name   string
+	// Scale converts the raw value to Unit: value = raw * Scale.
+	Scale float64
+	// Unit of the scaled value, empty for codes and counts.
+	Unit   string
+	Access Access
+}
+
+// Value returns the raw value scaled to the register's unit.
+func (r Register) Value(raw uint16) float64 {
+	return float64(raw) * r.Scale
+}
+
+// Raw returns the raw value of a value in the register's unit, rounded to the nearest step.
+func (r Register) Raw(value float64) uint16 {
+	return uint16(value/r.Scale + 0.5)
+}
+
+func (r Register) String() string {
+	if r.Function == FunctionReadParameter {
+		return fmt.Sprintf("PD%03d %s", r.Number, r.Name)
+	}
+	return r.Name
+}
+
+// Control is the control data written with FunctionControl, see the Control bits.
+var Control = Register{Function: FunctionControl, Name: "control", Scale: 1, Access: WriteOnly}
+
+// SetFrequency is the set frequency written with FunctionSetFrequency.
+var SetFrequency = Register{Function: FunctionSetFrequency, Name: "set frequency", Scale: 0.01, Unit: "Hz", Access: WriteOnly}
+
+var statusValues = [StatusValueCount]Register{
+	{FunctionReadStatus, StatusSetFrequency, "set frequency", 0.01, "Hz", ReadOnly},
+	{FunctionReadStatus, StatusOutputFrequency, "output frequency", 0.01, "Hz", ReadOnly},
+	{FunctionReadStatus, StatusOutputCurrent, "output current", 0.1, "A", ReadOnly},
+	{FunctionReadStatus, StatusRpm, "rpm", 1, "rpm", ReadOnly},
+	{FunctionReadStatus, StatusDCVoltage, "DC voltage", 0.1, "V", ReadOnly},
+	{FunctionReadStatus, StatusACVoltage, "AC voltage", 0.1, "V", ReadOnly},
+	{FunctionReadStatus, StatusCounter, "counter", 1, "", ReadOnly},
+	{FunctionReadStatus, StatusTemperature, "temperature", 1, "°C", ReadOnly},
+}
+
+// Status returns the description of a status value. Ok is false for unknown values.
+func Status(value byte) (r Register, ok bool) {
+	if value >= StatusValueCount {
+		return Register{}, false
+	}
+	return statusValues[value], true
+}
+
+var parameters = map[byte]Register{
+	PD005: {FunctionReadParameter, PD005, "max frequency", 0.01, "Hz", ReadWrite},
+	PD011: {FunctionReadParameter, PD011, "min frequency", 0.01, "Hz", ReadWrite},
+	PD014: {FunctionReadParameter, PD014, "acceleration time", 0.1, "s", ReadWrite},
+	PD015: {FunctionReadParameter, PD015, "deceleration time", 0.1, "s", ReadWrite},
+	PD026: {FunctionReadParameter, PD026, "stop mode", 1, "", ReadWrite},
+	PD028: {FunctionReadParameter, PD028, "DC braking time", 0.1, "s", ReadWrite},
+	PD029: {FunctionReadParameter, PD029, "DC braking frequency", 0.01, "Hz", ReadWrite},
+	PD030: {FunctionReadParameter, PD030, "DC braking voltage", 1, "%", ReadWrite},
+	PD080: {FunctionReadParameter, PD080, "multi-speed frequency 1", 0.01, "Hz", ReadWrite},
+	PD141: {FunctionReadParameter, PD141, "rated voltage", 1, "V", ReadWrite},
+	PD142: {FunctionReadParameter, PD142, "rated current", 0.1, "A", ReadWrite},
+	PD143: {FunctionReadParameter, PD143, "motor poles", 1, "", ReadWrite},
+	PD144: {FunctionReadParameter, PD144, "rated rpm", 1, "rpm", ReadWrite},
+	PD176: {FunctionReadParameter, PD176, "base frequency", 1, "", ReadWrite},
+}
+
+// Parameter returns the description of a PDxxx parameter. Ok is false for parameters
+// not described by this package. They are read and written with FunctionReadParameter and
+// FunctionWriteParameter, the Function field holds the read function.
+func Parameter(number byte) (r Register, ok bool) {
+	r, ok = parameters[number]
+	return
+}
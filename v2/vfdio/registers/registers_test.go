@@ -0,0 +1,36 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package registers
+
+import (
+	"testing"
+)
+
+func TestRegisters(t *testing.T) {
+	p, ok := Parameter(PD005)
+	if !ok || p.Value(40000) != 400 || p.Unit != "Hz" || p.Access != ReadWrite || p.String() != "PD005 max frequency" {
+		t.Fatalf("unexpected PD005 %+v", p)
+	}
+	if p.Raw(287.5) != 28750 {
+		t.Fatalf("unexpected raw value %d", p.Raw(287.5))
+	}
+	if _, ok := Parameter(1); ok {
+		t.Fatal("undescribed parameter found")
+	}
+	for value := byte(0); value < StatusValueCount; value++ {
+		if r, ok := Status(value); !ok || r.Number != value || r.Access != ReadOnly {
+			t.Fatalf("unexpected status value %d: %+v", value, r)
+		}
+	}
+	if r, _ := Status(StatusOutputCurrent); r.Value(25) != 2.5 || r.Unit != "A" {
+		t.Fatalf("unexpected output current %+v", r)
+	}
+	if _, ok := Status(StatusValueCount); ok {
+		t.Fatal("unknown status value found")
+	}
+	if FrameLength(ReadStatusDataLength) != 8 || FrameLength(ControlDataLength) != 6 {
+		t.Fatal("unexpected frame length")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// CommandID identifies a queued command word, see EnqueueIDs and Done. IDs increase in the
+// order the words were queued, 0 is not used.
+type CommandID uint64
+
+// CommandResult is the outcome of a queued command word.
+type CommandResult struct {
+	ID      CommandID
+	Command string
+	// Err tells why the command failed, e.g. a write error of the port, ErrStopNotAcknowledged
+	// for an unacknowledged stop, ErrInvalidSpeed, or ErrEStopped and ErrKeepaliveExpired for
+	// commands discarded from the queue. Nil if the command was sent.
+	Err error
+}
+
+// ErrNotAcknowledged is the result of a word passed to ExecuteGCode whose frame was sent,
+// but not answered by the VFD.
+var ErrNotAcknowledged = errors.New("vfdio: command not acknowledged by the VFD")
+
+// resultSink collects the results of the words of one line.
+type resultSink struct {
+	results chan CommandResult
+	// pending counts the queued words plus one until all words were queued.
+	pending int32
+	// acknowledged is set by ExecuteGCode: words whose frame was not answered fail with
+	// ErrNotAcknowledged.
+	acknowledged bool
+}
+
+// send passes the result of a word and closes the channel after the last one.
+func (r *resultSink) send(result CommandResult) {
+	if r == nil {
+		return
+	}
+	r.results <- result
+	r.done()
+}
+
+func (r *resultSink) done() {
+	if atomic.AddInt32(&r.pending, -1) == 0 {
+		close(r.results)
+	}
+}
+
+// EnqueueResults works like Enqueue and returns a channel receiving the result of every
+// queued word once it was executed, so write errors of a broken adapter are noticed while a
+// job runs. The channel is buffered for all words and closed after the last result, words
+// which were not queued because of an error produce no result. Status requests ("?") are
+// not reported.
+func (o *HyInverter) EnqueueResults(cmd string) (<-chan CommandResult, error) {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	if err := o.stateError(); err != nil {
+		return nil, err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return nil, err
+	}
+	sink := &resultSink{results: make(chan CommandResult, len(words)), pending: 1}
+	var err error
+	for _, word := range words {
+		if word.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		atomic.AddInt32(&sink.pending, 1)
+		if !o.queue(word, sink) {
+			atomic.AddInt32(&sink.pending, -1)
+			if err == nil {
+				err = ErrQueueFull
+			}
+		}
+	}
+	sink.done()
+	return sink.results, err
+}
+
+// EnqueueIDs works like Enqueue and returns the IDs of the queued words, so a sender can
+// check with Done which of them were executed. Words which were not queued because of an
+// error have no ID. Status requests ("?") are not counted.
+func (o *HyInverter) EnqueueIDs(cmd string) ([]CommandID, error) {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	if err := o.stateError(); err != nil {
+		return nil, err
+	}
+	o.Keepalive()
+	words := o.lineWords(cmd)
+	if err := o.checkGCodeLine(cmd, words); err != nil {
+		return nil, err
+	}
+	ids := make([]CommandID, 0, len(words))
+	var err error
+	for _, word := range words {
+		if word.text == "?" {
+			o.requestStatus(o.PollValues()...)
+			continue
+		}
+		if id := o.queueID(word, nil); id != 0 {
+			ids = append(ids, id)
+		} else if err == nil {
+			err = ErrQueueFull
+		}
+	}
+	return ids, err
+}
+
+// Done returns true if the command word was executed or discarded, e.g. by EStop or Close.
+// Its result is passed to the channel of EnqueueResults, see CommandResult.ID.
+func (o *HyInverter) Done(id CommandID) bool {
+	return o.commandIDs.done(id)
+}
+
+// commandIDs assigns the IDs of queued words and keeps the ones which are not done.
+type commandIDs struct {
+	mutex   sync.Mutex
+	last    CommandID
+	pending map[CommandID]struct{}
+}
+
+func (c *commandIDs) next() CommandID {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[CommandID]struct{})
+	}
+	c.last++
+	c.pending[c.last] = struct{}{}
+	return c.last
+}
+
+func (c *commandIDs) finish(id CommandID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *commandIDs) done(id CommandID) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, pending := c.pending[id]
+	return id != 0 && id <= c.last && !pending
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnqueueResults(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetStopEscalation(0, nil)
+	hy.port = unpluggedPort{}
+	results, err := hy.EnqueueResults("M3 S1000 G0 ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		hy.processNext()
+	}
+	var received []CommandResult
+	for result := range results {
+		received = append(received, result)
+	}
+	if len(received) != 3 || received[0].Command != "M3" || !errors.Is(received[0].Err, errUnplugged) ||
+		!errors.Is(received[1].Err, ErrPortClosed) || received[2].Command != "G0" || received[2].Err != nil {
+		t.Fatalf("unexpected results %+v", received)
+	}
+	hy.port = &testPort{}
+	results, _ = hy.EnqueueResults("M4")
+	hy.EStop()
+	if result := <-results; !errors.Is(result.Err, ErrEStopped) {
+		t.Fatalf("expected ErrEStopped, got %+v", result)
+	}
+	if _, open := <-results; open {
+		t.Fatal("results not closed")
+	}
+}
+
+func TestCommandLogErrors(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetCommandLog(2)
+	// Enqueue rejects invalid speeds, but another front end may queue them.
+	hy.commandQueue++
+	hy.cmdChannel <- queuedCommand{"S-1", time.Now(), nil, 0, false}
+	hy.processNext()
+	if log := hy.CommandLog(); len(log) != 1 || !errors.Is(log[0].Err, ErrInvalidSpeed) {
+		t.Fatalf("unexpected log %v", log)
+	}
+}
+
+func TestCommandIDs(t *testing.T) {
+	hy, _ := newTestInverter()
+	ids, err := hy.EnqueueIDs("M3 S1000 ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[1] <= ids[0] || ids[0] == 0 {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if hy.Done(ids[0]) || hy.Done(ids[1]) || hy.Done(0) || hy.Done(ids[1]+1) {
+		t.Fatal("commands done before they were executed")
+	}
+	hy.processNext()
+	if !hy.Done(ids[0]) || hy.Done(ids[1]) {
+		t.Fatal("only the first command is done")
+	}
+	results, _ := hy.EnqueueResults("M5")
+	hy.flushCommands(ErrClosed)
+	result := <-results
+	if !hy.Done(ids[1]) || !hy.Done(result.ID) || result.ID <= ids[1] {
+		t.Errorf("discarded commands not done, result %+v", result)
+	}
+}
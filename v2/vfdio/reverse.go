@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReverseLocked is returned for M4 and reverse jogs while SetReverseLockout is enabled.
+var ErrReverseLocked = errors.New("vfdio: reverse rotation locked out")
+
+// SetReverseLockout rejects commands which run the spindle backwards, e.g. for spindles with
+// ER collets whose nut unscrews in reverse. GCode returns false, Enqueue and EncodeCommand
+// return ErrReverseLocked and StreamProgram a *LineError for M4 and reverse jogs. Nothing of
+// the line is queued. Reverse commands which were queued before are dropped. Default: false.
+func (o *HyInverter) SetReverseLockout(enabled bool) {
+	o.stateMutex.Lock()
+	o.reverseLockout = enabled
+	o.stateMutex.Unlock()
+}
+
+// ReverseLockout returns true if commands which run the spindle backwards are rejected.
+func (o *HyInverter) ReverseLockout() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.reverseLockout
+}
+
+// isReverse returns true if the control command runs the spindle backwards.
+func isReverse(command ControlCommand) bool {
+	return command&(ControlReverseDirection|ControlJogReverse) != 0
+}
+
+// checkReverse returns ErrReverseLocked for a reverse command word while the lockout is
+// enabled.
+func (o *HyInverter) checkReverse(word string) error {
+	if command, ok := controlCommand(strings.ToLower(word)); ok {
+		return o.checkReverseCommand(command, word)
+	}
+	return nil
+}
+
+// checkReverseCommand returns ErrReverseLocked for the control command of a word if it runs
+// the spindle backwards while the lockout is enabled.
+func (o *HyInverter) checkReverseCommand(command ControlCommand, word string) error {
+	if isReverse(command) && o.ReverseLockout() {
+		return fmt.Errorf("%w: %s", ErrReverseLocked, word)
+	}
+	return nil
+}
+
+// checkReverseWords checks the words of a line and internal jog words, see checkReverse.
+func (o *HyInverter) checkReverseWords(words []lineWord) error {
+	for _, word := range words {
+		if command, ok := jogCommand(word); ok {
+			if err := o.checkReverseCommand(command, word.text); err != nil {
+				return err
+			}
+		} else if err := o.checkReverse(word.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReverseLockout(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.GCode("M4")
+	hy.SetReverseLockout(true)
+	if err := hy.Enqueue("S1000 M4"); !errors.Is(err, ErrReverseLocked) {
+		t.Fatalf("expected ErrReverseLocked, got %v", err)
+	}
+	if hy.Jog(Backward, 1000) {
+		t.Fatal("reverse jog accepted")
+	}
+	if _, err := hy.EncodeCommand("m04"); !errors.Is(err, ErrReverseLocked) {
+		t.Fatalf("expected ErrReverseLocked, got %v", err)
+	}
+	err := hy.StreamProgram(context.Background(), strings.NewReader("S1000\nM4\n"), StreamOptions{Name: "job.nc"})
+	if lineErr, ok := err.(*LineError); !ok || lineErr.Line != 2 || lineErr.Reason != "reverse rotation locked out" {
+		t.Fatalf("expected a *LineError, got %v", err)
+	}
+	// The M4 queued before the lockout and the S1000 of the program.
+	if len(hy.cmdChannel) != 2 {
+		t.Fatalf("expected 2 queued commands, got %d", len(hy.cmdChannel))
+	}
+	hy.processNext()
+	if len(port.Bytes()) != 0 {
+		t.Fatalf("queued M4 sent: % X", port.Bytes())
+	}
+	if !hy.GCode("M3") {
+		t.Fatal("M3 rejected")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+// pollRunState is queued like a status value and reads the control status.
+const pollRunState StatusValue = statusValueCount + 1
+
+// pollValueCount is the number of values which can be pending in the poll queue.
+const pollValueCount = statusValueCount + 4
+
+// SetRunStatePolling enables reading the control status in every polling cycle. It is read
+// with a control message without command bits, which leaves the drive's state unchanged.
+// The answers of M3, M4 and M5 update it as well. Default: false.
+func (o *HyInverter) SetRunStatePolling(enabled bool) {
+	o.stateMutex.Lock()
+	o.runStatePolling = enabled
+	o.stateMutex.Unlock()
+}
+
+// RunStatePolling returns true if the control status is read in every polling cycle.
+func (o *HyInverter) RunStatePolling() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.runStatePolling
+}
+
+// ControlStatus returns the last control status reported by the VFD. Ok is false if none
+// was received yet.
+func (o *HyInverter) ControlStatus() (status ControlStatus, ok bool) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.controlStatus, o.controlStatusReceived
+}
+
+// Running returns true if the VFD reports that the motor runs, e.g. to verify that the drive
+// entered the run state after M3. See SetRunStatePolling.
+func (o *HyInverter) Running() bool {
+	status, _ := o.ControlStatus()
+	return status&ControlStatusRunning != 0
+}
+
+// Direction returns true if the VFD reports forward (clockwise, M3) rotation, false for
+// reverse (M4). The VFD's direction is inverted if SetInvertDirection is enabled.
+// See SetRunStatePolling.
+func (o *HyInverter) Direction() (cw bool) {
+	status, _ := o.ControlStatus()
+	return (status&ControlStatusReverse == 0) != o.InvertDirection()
+}
+
+// Braking returns true if the VFD reports that it brakes the motor. See SetRunStatePolling.
+func (o *HyInverter) Braking() bool {
+	status, _ := o.ControlStatus()
+	return status&ControlStatusBraking != 0
+}
+
+// readRunState sends a control message without command bits to read the control status.
+func (o *HyInverter) readRunState() {
+	o.write(o.controlFrame(0))
+}
+
+// controlAnswered stores the control status of a FunctionControl answer.
+func (o *HyInverter) controlAnswered(status ControlStatus) {
+	o.stateMutex.Lock()
+	previous, known := o.controlStatus, o.controlStatusReceived
+	o.controlStatus = status
+	o.controlStatusReceived = true
+	o.stateMutex.Unlock()
+	o.checkRunState(previous, known, status)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestControlStatus(t *testing.T) {
+	hy, port := newTestInverter()
+	if _, ok := hy.ControlStatus(); ok || hy.Running() {
+		t.Fatal("control status reported before an answer")
+	}
+	hy.readStatus(pollRunState)
+	if frames := port.Bytes(); len(frames) != 6 || Function(frames[1]) != FunctionControl || frames[3] != 0 {
+		t.Fatalf("unexpected request % X", frames)
+	}
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, byte(ControlStatusRunCommand | ControlStatusRunning | ControlStatusReverse)}))
+	if !hy.Running() || hy.Direction() || hy.Braking() {
+		t.Fatalf("unexpected run state: running %v, cw %v, braking %v", hy.Running(), hy.Direction(), hy.Braking())
+	}
+}
+
+func TestRunStatePolling(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	hy.SetRunStatePolling(true)
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.GCode("M3 S6000")
+	time.Sleep(500 * time.Millisecond)
+	if !hy.Running() || !hy.Direction() {
+		t.Fatalf("run forward not reported: %v", hy.Running())
+	}
+	// Changed at the front panel.
+	vfd.PanelStop()
+	time.Sleep(500 * time.Millisecond)
+	if hy.Running() {
+		t.Fatal("stop not reported")
+	}
+}
+
+func TestRunStateEvents(t *testing.T) {
+	hy, _ := newTestInverter()
+	var events []Event
+	hy.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	for _, status := range []ControlStatus{
+		0,
+		ControlStatusRunCommand | ControlStatusRunning,
+		ControlStatusRunCommand | ControlStatusRunning,
+		ControlStatusRunCommand | ControlStatusRunning | ControlStatusReverse,
+		0,
+	} {
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, byte(status)}))
+	}
+	expected := []Event{{Type: Started, Forward: true}, {Type: DirectionChanged}, {Type: Stopped}}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %v", len(expected), events)
+	}
+	for i, e := range events {
+		if e.Type != expected[i].Type || e.Forward != expected[i].Forward {
+			t.Errorf("event %d: expected %v forward %v, got %v forward %v", i, expected[i].Type, expected[i].Forward, e.Type, e.Forward)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock is the time source of the library: the poll scheduling, the gaps between frames,
+// timeouts, timers like those of Jog, SetKeepalive and G4, and the timestamps of events and
+// statistics. Tests can replace it to run polling cycles without waiting and to check
+// timeouts deterministically.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks on its channel like a time.Ticker, see Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer fires once like a time.Timer, see Clock. The channel of a timer created by
+// AfterFunc is nil.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// systemClock is the default Clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+// systemTicker is the Ticker of systemClock.
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// systemTimer is the Timer of systemClock.
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// SetClock replaces the time source, nil restores the system clock. It has to be called
+// before Open. Close does not wait for a Sleep of the clock to return. Default: the system
+// clock.
+func (o *HyInverter) SetClock(clock Clock) {
+	o.stateMutex.Lock()
+	o.clock = clock
+	o.stateMutex.Unlock()
+}
+
+// currentClock returns the clock set by SetClock or the system clock.
+func (o *HyInverter) currentClock() Clock {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.clockLocked()
+}
+
+// clockLocked works like currentClock. The caller holds stateMutex.
+func (o *HyInverter) clockLocked() Clock {
+	if o.clock == nil {
+		return systemClock{}
+	}
+	return o.clock
+}
+
+// now returns the time of the current clock.
+func (o *HyInverter) now() time.Time {
+	return o.currentClock().Now()
+}
+
+// since returns the time elapsed on the current clock since t.
+func (o *HyInverter) since(t time.Time) time.Duration {
+	return o.now().Sub(t)
+}
+
+// SetPollJitter adds a random delay between 0 and jitter to every poll interval, so several
+// instances sharing a gateway or CPU don't send their status reads at the same time.
+// Default: 0.
+func (o *HyInverter) SetPollJitter(jitter time.Duration) {
+	o.stateMutex.Lock()
+	o.pollJitter = jitter
+	o.stateMutex.Unlock()
+}
+
+// pollDelay returns the time until the next polling cycle.
+func (o *HyInverter) pollDelay(pollInterval time.Duration) time.Duration {
+	o.stateMutex.Lock()
+	jitter := o.pollJitter
+	o.stateMutex.Unlock()
+	if jitter <= 0 {
+		return pollInterval
+	}
+	return pollInterval + time.Duration(rand.Int63n(int64(jitter)+1))
+}
+
+// waitForPoll blocks until the next polling cycle is due.
+func (o *HyInverter) waitForPoll(pollInterval time.Duration) {
+	o.stateMutex.Lock()
+	clock := o.clock
+	o.stateMutex.Unlock()
+	if clock == nil {
+		o.sleep(o.pollDelay(pollInterval))
+		return
+	}
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(o.pollDelay(pollInterval))
+		close(slept)
+	}()
+	select {
+	case <-slept:
+	case <-o.done:
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock records the requested delays and returns immediately.
+type fakeClock struct {
+	systemClock
+	mutex  sync.Mutex
+	delays []time.Duration
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	c.delays = append(c.delays, d)
+	c.mutex.Unlock()
+	time.Sleep(time.Millisecond)
+}
+
+func (c *fakeClock) Delays() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration(nil), c.delays...)
+}
+
+func TestPollJitter(t *testing.T) {
+	clock := &fakeClock{}
+	hy, _ := newTestInverter()
+	hy.SetClock(clock)
+	hy.SetPollJitter(50 * time.Millisecond)
+	go outFrequencyRequester(hy, 750*time.Millisecond)
+	var delays []time.Duration
+	for i := 0; i < 100 && len(delays) < 20; i++ {
+		time.Sleep(5 * time.Millisecond)
+		delays = clock.Delays()
+	}
+	atomic.StoreInt32(&hy.stop, 1)
+	if len(delays) < 20 {
+		t.Fatalf("polling did not use the clock: %v", delays)
+	}
+	varied := false
+	for _, d := range delays {
+		if d < 750*time.Millisecond || d > 800*time.Millisecond {
+			t.Fatalf("delay %v out of range", d)
+		}
+		varied = varied || d != delays[0]
+	}
+	if !varied {
+		t.Fatal("no jitter applied")
+	}
+	// The output frequency and the end of the cycle.
+	if len(hy.pollChannel) != 2 {
+		t.Fatalf("expected two pending status reads, got %d", len(hy.pollChannel))
+	}
+}
+
+// manualClock is set by the test, its tickers never tick.
+type manualClock struct {
+	fakeClock
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Set(now time.Time) {
+	c.mutex.Lock()
+	c.now = now
+	c.mutex.Unlock()
+}
+
+func TestClock(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.pollIntervalSec = 1
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	hy.SetClock(clock)
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, byte(ControlStatusRunning)}))
+	if !hy.LastSeen().Equal(start) {
+		t.Fatalf("last message at %v, expected the time of the clock", hy.LastSeen())
+	}
+	clock.Set(start.Add(1999 * time.Millisecond))
+	if !hy.Online() || !hy.Status().Online {
+		t.Error("offline within two poll intervals")
+	}
+	clock.Set(start.Add(2 * time.Second))
+	if hy.Online() || hy.Status().Online {
+		t.Error("online after two poll intervals")
+	}
+	hy.SetClock(nil)
+	if hy.Online() {
+		t.Error("online with the system clock")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Errors of Open and OpenContext if the serial port can't be opened. They wrap the error of
+// the operating system, compare them with errors.Is:
+//
+//   if err := handle.Open("/dev/ttyUSB0"); errors.Is(err, vfdio.ErrPermissionDenied) {
+//       fmt.Println("Add the user to the dialout group.")
+//   }
+//
+var (
+	ErrPortNotFound     = errors.New("vfdio: serial port not found")
+	ErrPermissionDenied = errors.New("vfdio: permission denied for serial port")
+	ErrPortBusy         = errors.New("vfdio: serial port busy")
+	ErrNotSerialPort    = errors.New("vfdio: not a serial port")
+)
+
+// serialOpenError wraps an error of opening the serial port with one of the errors above.
+func serialOpenError(portName string, err error) error {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w: %s: %w", ErrPortNotFound, portName, err)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%w: %s: %w", ErrPermissionDenied, portName, err)
+	case errors.Is(err, syscall.EBUSY):
+		return fmt.Errorf("%w: %s: %w", ErrPortBusy, portName, err)
+	case errors.Is(err, syscall.ENOTTY):
+		return fmt.Errorf("%w: %s: %w", ErrNotSerialPort, portName, err)
+	}
+	return fmt.Errorf("vfdio: opening serial port %s: %w", portName, err)
+}
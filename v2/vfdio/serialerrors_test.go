@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSerialOpenError(t *testing.T) {
+	hy := NewVfd()
+	err := hy.Open("/nonexistent/ttyUSB9")
+	if !errors.Is(err, ErrPortNotFound) || !errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), "/nonexistent/ttyUSB9") {
+		t.Fatalf("expected ErrPortNotFound with the port name, got %v", err)
+	}
+	if err := hy.Close(); err != ErrNotOpen {
+		t.Errorf("opened after an error: %v", err)
+	}
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{&os.PathError{Op: "open", Path: "/dev/ttyUSB0", Err: syscall.EACCES}, ErrPermissionDenied},
+		{&os.PathError{Op: "open", Path: "/dev/ttyUSB0", Err: syscall.EBUSY}, ErrPortBusy},
+		{os.NewSyscallError("SYS_IOCTL", syscall.ENOTTY), ErrNotSerialPort},
+	}
+	for _, test := range tests {
+		if err := serialOpenError("/dev/ttyUSB0", test.err); !errors.Is(err, test.expected) || !errors.Is(err, test.err) {
+			t.Errorf("%v: expected %v, got %v", test.err, test.expected, err)
+		}
+	}
+	other := errors.New("invalid setting for StopBits")
+	if err := serialOpenError("/dev/ttyUSB0", other); !errors.Is(err, other) || errors.Is(err, ErrPortNotFound) {
+		t.Errorf("unexpected wrapping: %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package simulator emulates a Huanyang VFD behind a serial port. It answers the frames
+// written by vfdio and ramps its output frequency like a drive, so programs can be tried
+// without hardware. Example:
+//
+//   vfd := simulator.New()
+//   handle := vfdio.NewVfd()
+//   handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(250*time.Millisecond))
+//   defer handle.Close()
+//   handle.GCode("M3 S300")
+//
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"github.com/npat-efault/crc16"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Read and Write after Close.
+var ErrClosed = errors.New("simulator: port closed")
+
+// Control commands of function 0x03.
+const (
+	controlRunForward  = registers.ControlRun
+	controlStop        = registers.ControlStop
+	controlRunBackward = registers.ControlRun | registers.ControlReverseDirection
+)
+
+// Status bits returned for function 0x03, see vfdio.ControlStatus.
+const (
+	statusRun            = registers.ControlStatusRunCommand
+	statusReverse        = registers.ControlStatusReverseCommand
+	statusRunning        = registers.ControlStatusRunning
+	statusReverseRunning = registers.ControlStatusReverse
+)
+
+// Vfd is a simulated drive. It implements io.ReadWriteCloser.
+type Vfd struct {
+	mutex sync.Mutex
+	// Address is the RS485 slave address (PD163). Default: 1.
+	Address byte
+	// MaxFrequency is the highest accepted frequency in 0.01 Hz (PD005). Default: 40000.
+	MaxFrequency uint16
+	// Acceleration in 0.01 Hz per second. Default: 40000 (0 to 400 Hz in one second).
+	Acceleration float64
+	// ReadTimeout is the time Read waits for an answer before it returns io.EOF, like a serial
+	// port with inter character timeout. Default: 100 ms.
+	ReadTimeout time.Duration
+	// Parameters are the PDxxx values returned by function 0x01 and set by function 0x02.
+	// PD005 is MaxFrequency. Default: PD144 = 3000 (rated RPM of a two pole motor), PD176 = 0 (50 Hz).
+	Parameters map[byte]uint16
+
+	running         bool
+	backward        bool
+	setFrequency    uint16
+	outputFrequency float64
+	updated         time.Time
+	silent          bool
+	closed          bool
+	request         []byte
+	answer          bytes.Buffer
+	answerReady     chan struct{}
+	frames          int
+	// faultParameter holds the code of a trip until it is reset, 0 if not tripped.
+	faultParameter byte
+}
+
+// New creates a stopped drive with default settings.
+func New() *Vfd {
+	return &Vfd{
+		Address:      1,
+		MaxFrequency: 40000,
+		Acceleration: 40000,
+		ReadTimeout:  100 * time.Millisecond,
+		Parameters:   map[byte]uint16{registers.PD144: 3000, registers.PD176: 0},
+		updated:      time.Now(),
+		answerReady:  make(chan struct{}, 1),
+	}
+}
+
+// Write receives request frames. Complete frames are answered, invalid ones are ignored.
+func (v *Vfd) Write(b []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.closed {
+		return 0, ErrClosed
+	}
+	v.request = append(v.request, b...)
+	for len(v.request) >= 3 {
+		length := registers.FrameLength(int(v.request[2]))
+		if len(v.request) < length {
+			break
+		}
+		frame := v.request[:length]
+		v.request = v.request[length:]
+		if answer := v.handle(frame); answer != nil && !v.silent {
+			v.answer.Write(answer)
+			select {
+			case v.answerReady <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// Read returns answers. It blocks up to ReadTimeout and returns io.EOF if no answer is available.
+func (v *Vfd) Read(b []byte) (int, error) {
+	deadline := time.After(v.ReadTimeout)
+	for {
+		v.mutex.Lock()
+		if v.closed {
+			v.mutex.Unlock()
+			return 0, ErrClosed
+		}
+		if v.answer.Len() > 0 {
+			defer v.mutex.Unlock()
+			return v.answer.Read(b)
+		}
+		v.mutex.Unlock()
+		select {
+		case <-v.answerReady:
+		case <-deadline:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Close makes all further reads and writes fail.
+func (v *Vfd) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.closed = true
+	return nil
+}
+
+// SetSilent stops (or resumes) answering, like a drive without power or a broken cable.
+func (v *Vfd) SetSilent(silent bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.silent = silent
+}
+
+// PanelSetFrequency changes the set frequency like an operator at the front panel.
+func (v *Vfd) PanelSetFrequency(frequency uint16) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.update()
+	v.setFrequency = v.limit(frequency)
+}
+
+// PanelStop stops the motor like an operator pressing STOP at the front panel.
+func (v *Vfd) PanelStop() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.update()
+	v.running = false
+}
+
+// Trip stops the motor with a fault, e.g. an overcurrent trip. The code is returned as value
+// of the parameter until a stop command resets the fault, like the STOP/RESET key. Run
+// commands are ignored meanwhile.
+func (v *Vfd) Trip(parameter byte, code uint16) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.update()
+	v.running = false
+	v.Parameters[parameter] = code
+	v.faultParameter = parameter
+}
+
+// Running returns the run state and direction.
+func (v *Vfd) Running() (running, backward bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.running, v.backward
+}
+
+// SetFrequency returns the set frequency in 0.01 Hz.
+func (v *Vfd) SetFrequency() uint16 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.setFrequency
+}
+
+// OutputFrequency returns the current output frequency in 0.01 Hz.
+func (v *Vfd) OutputFrequency() uint16 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.update()
+	return uint16(v.outputFrequency)
+}
+
+// Frames returns the number of valid frames received.
+func (v *Vfd) Frames() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.frames
+}
+
+// update ramps the output frequency towards the target.
+func (v *Vfd) update() {
+	now := time.Now()
+	step := v.Acceleration * now.Sub(v.updated).Seconds()
+	v.updated = now
+	target := 0.0
+	if v.running {
+		target = float64(v.setFrequency)
+	}
+	if v.outputFrequency < target {
+		v.outputFrequency += step
+		if v.outputFrequency > target {
+			v.outputFrequency = target
+		}
+	} else if v.outputFrequency > target {
+		v.outputFrequency -= step
+		if v.outputFrequency < target {
+			v.outputFrequency = target
+		}
+	}
+}
+
+func (v *Vfd) limit(frequency uint16) uint16 {
+	if frequency > v.MaxFrequency {
+		return v.MaxFrequency
+	}
+	return frequency
+}
+
+// handle returns the answer to a request frame, or nil if it is not answered.
+func (v *Vfd) handle(frame []byte) []byte {
+	n := len(frame)
+	if frame[0] != v.Address || crc16.Checksum(crc16.Modbus, frame[:n-2]) != uint16(frame[n-2])|uint16(frame[n-1])<<8 {
+		return nil
+	}
+	v.frames++
+	v.update()
+	switch {
+	case frame[1] == registers.FunctionControl && frame[2] == registers.ControlDataLength:
+		switch {
+		case frame[3] == controlStop:
+			v.running = false
+			if v.faultParameter != 0 {
+				v.Parameters[v.faultParameter] = 0
+				v.faultParameter = 0
+			}
+		case v.faultParameter != 0:
+			// Tripped
+		case frame[3] == controlRunForward:
+			v.running, v.backward = true, false
+		case frame[3] == controlRunBackward:
+			v.running, v.backward = true, true
+		}
+		status := byte(0)
+		if v.running {
+			status |= statusRun | statusRunning
+		}
+		if v.backward {
+			status |= statusReverse | statusReverseRunning
+		}
+		return sign([]byte{v.Address, registers.FunctionControl, registers.ControlDataLength, status})
+	case frame[1] == registers.FunctionReadParameter && frame[2] == registers.ReadParameterDataLength:
+		value := v.Parameters[frame[3]]
+		if frame[3] == registers.PD005 {
+			value = v.MaxFrequency
+		}
+		return sign([]byte{v.Address, registers.FunctionReadParameter, registers.ReadParameterDataLength, frame[3], byte(value >> 8), byte(value)})
+	case frame[1] == registers.FunctionWriteParameter && frame[2] == registers.WriteParameterDataLength:
+		value := uint16(frame[4])<<8 | uint16(frame[5])
+		if frame[3] == registers.PD005 {
+			v.MaxFrequency = value
+		} else {
+			v.Parameters[frame[3]] = value
+		}
+		return sign([]byte{v.Address, registers.FunctionWriteParameter, registers.WriteParameterDataLength, frame[3], frame[4], frame[5]})
+	case frame[1] == registers.FunctionReadStatus && frame[2] == registers.ReadStatusDataLength:
+		value := v.status(frame[3])
+		return sign([]byte{v.Address, registers.FunctionReadStatus, registers.ReadStatusDataLength, frame[3], byte(value >> 8), byte(value)})
+	case frame[1] == registers.FunctionSetFrequency && frame[2] == registers.SetFrequencyDataLength:
+		v.setFrequency = v.limit(uint16(frame[3])<<8 | uint16(frame[4]))
+		return sign([]byte{v.Address, registers.FunctionSetFrequency, registers.SetFrequencyDataLength, byte(v.setFrequency >> 8), byte(v.setFrequency)})
+	}
+	return nil
+}
+
+// status returns a value of the "read control status" function.
+func (v *Vfd) status(value byte) uint16 {
+	switch value {
+	case registers.StatusSetFrequency:
+		return v.setFrequency
+	case registers.StatusOutputFrequency:
+		return uint16(v.outputFrequency)
+	case registers.StatusOutputCurrent:
+		// Output current in 0.1 A, idle current plus a load proportional to the frequency
+		if v.outputFrequency == 0 {
+			return 0
+		}
+		return uint16(5 + 20*v.outputFrequency/float64(v.MaxFrequency))
+	case registers.StatusRpm:
+		// RPM of a two pole motor
+		return uint16(v.outputFrequency * 60 / 100)
+	case registers.StatusDCVoltage:
+		// DC bus voltage in 0.1 V
+		return 3110
+	case registers.StatusACVoltage:
+		// Output voltage in 0.1 V, proportional to the frequency
+		return uint16(2200 * v.outputFrequency / float64(v.MaxFrequency))
+	case registers.StatusTemperature:
+		// Temperature in °C
+		return 35
+	}
+	return 0
+}
+
+func sign(data []byte) []byte {
+	crc := crc16.Checksum(crc16.Modbus, data)
+	return append(data, byte(crc), byte(crc>>8))
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package simulator
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"testing"
+	"time"
+)
+
+func TestSimulatedSpindle(t *testing.T) {
+	vfd := New()
+	handle := vfdio.NewVfd()
+	handle.OpenPort(vfd, vfdio.WithMaxRpm(11520), vfdio.WithRpmToHertz(3.47222), vfdio.WithPollInterval(100*time.Millisecond))
+	defer handle.Close()
+	handle.GCode("M4 S5760")
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if processed, _, _ := handle.Processed(); processed && handle.Online() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("spindle not at speed, output frequency %d", handle.OutputFrequency())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if running, backward := vfd.Running(); !running || !backward {
+		t.Errorf("expected backward run, got running=%v backward=%v", running, backward)
+	}
+	if vfd.SetFrequency() != 20000 {
+		t.Errorf("unexpected set frequency %d", vfd.SetFrequency())
+	}
+}
+
+func TestLimitAndSilence(t *testing.T) {
+	vfd := New()
+	vfd.MaxFrequency = 30000
+	vfd.Write(sign([]byte{0x01, 0x05, 0x02, 0x9C, 0x40}))
+	answer := make([]byte, 16)
+	n, err := vfd.Read(answer)
+	if err != nil || n != 7 || answer[3] != 0x75 || answer[4] != 0x30 {
+		t.Fatalf("expected echo of the limited frequency, got % X, %v", answer[:n], err)
+	}
+	vfd.SetSilent(true)
+	vfd.Write(sign([]byte{0x01, 0x04, 0x03, 0x00, 0x00, 0x00}))
+	vfd.ReadTimeout = 10 * time.Millisecond
+	if n, err = vfd.Read(answer); n != 0 || err == nil {
+		t.Fatalf("silent drive answered % X", answer[:n])
+	}
+}
+
+func TestTrip(t *testing.T) {
+	vfd := New()
+	answer := make([]byte, 16)
+	transact := func(frame []byte) []byte {
+		vfd.Write(sign(frame))
+		n, err := vfd.Read(answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return answer[:n]
+	}
+	transact([]byte{0x01, 0x03, 0x01, controlRunForward})
+	vfd.Trip(100, 4)
+	if running, _ := vfd.Running(); running {
+		t.Fatal("still running after the trip")
+	}
+	if fault := transact([]byte{0x01, 0x01, 0x03, 100, 0x00, 0x00}); fault[5] != 4 {
+		t.Fatalf("unexpected fault parameter % X", fault)
+	}
+	transact([]byte{0x01, 0x03, 0x01, controlRunForward})
+	if running, _ := vfd.Running(); running {
+		t.Fatal("started while tripped")
+	}
+	transact([]byte{0x01, 0x03, 0x01, controlStop})
+	if fault := transact([]byte{0x01, 0x01, 0x03, 100, 0x00, 0x00}); fault[5] != 0 {
+		t.Fatalf("fault not reset by the stop command: % X", fault)
+	}
+	transact([]byte{0x01, 0x03, 0x01, controlRunForward})
+	if running, _ := vfd.Running(); !running {
+		t.Fatal("not started after the reset")
+	}
+}
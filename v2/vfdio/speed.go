@@ -0,0 +1,40 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSpeed is returned for S words without a valid speed, e.g. "S-100" or "S.".
+var ErrInvalidSpeed = errors.New("vfdio: invalid speed")
+
+// parseSpeed returns the RPM of an S word. Decimal values like S7500.5, as emitted by most
+// CAM post processors, are accepted. They are not rounded to whole RPM, the frequency is
+// rounded to the register step instead, see FrequencyResolution.
+func parseSpeed(word string) (float64, error) {
+	rpm, err := strconv.ParseFloat(word[1:], 64)
+	if err != nil || rpm < 0 || math.IsInf(rpm, 0) || math.IsNaN(rpm) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSpeed, word)
+	}
+	return rpm, nil
+}
+
+// checkSpeedWords checks the S words of a line, see parseSpeed.
+func checkSpeedWords(words []string) error {
+	for _, word := range words {
+		if !strings.HasPrefix(word, "s") && !strings.HasPrefix(word, "S") {
+			continue
+		}
+		if _, err := parseSpeed(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
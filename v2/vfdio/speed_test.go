@@ -0,0 +1,38 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecimalSpeed(t *testing.T) {
+	hy, port := newTestInverter()
+	if err := hy.Enqueue("S7500.5"); err != nil {
+		t.Fatal(err)
+	}
+	hy.processNext()
+	// 7500.5 * 3.47222 = 26043.3, 7500 would be 26041.7
+	if expected := hy.signMessage([]byte{0x01, 0x05, 0x02, 0x65, 0xBB}); !bytes.Equal(port.Bytes(), expected) {
+		t.Fatalf("expected % X, sent % X", expected, port.Bytes())
+	}
+	for _, cmd := range []string{"M3 S-100", "S."} {
+		if err := hy.Enqueue(cmd); !errors.Is(err, ErrInvalidSpeed) {
+			t.Errorf("%s: expected ErrInvalidSpeed, got %v", cmd, err)
+		}
+	}
+	if queued := hy.commandQueue; queued != 0 {
+		t.Fatalf("%d commands queued", queued)
+	}
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.commandQueue++
+	hy.execute(queuedCommand{word: "S1.2.3"})
+	if len(events) != 1 || events[0].Type != CommandRejected || !errors.Is(events[0].Err, ErrInvalidSpeed) {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// hertzWordPrefix starts the command words queued by SetFrequencyHz, followed by the
+// frequency in Hz.
+const hertzWordPrefix = "hz"
+
+// SetSpeedRpm queues the speed like an S word, without formatting and splitting a G-code
+// line. It returns the errors of Enqueue, e.g. ErrBelowMinimum, see SetMinRpm.
+//
+//   handle.SetSpeedRpm(12000)
+//   handle.Start(vfdio.Forward)
+//
+func (o *HyInverter) SetSpeedRpm(rpm uint16) error {
+	return o.enqueueWords(lineWord{text: "S" + strconv.Itoa(int(rpm))})
+}
+
+// SetFrequencyHz queues the frequency in Hz without converting it to RPM, e.g. for motors
+// whose RPM factor is unknown. The frequency is rounded to FrequencyResolution. Frequencies
+// below SetMinRpm or above MaxRpm are clamped or rejected like S commands if the RPM factor
+// is known. It returns ErrInvalidSpeed for negative values, ErrOutOfRange above the
+// frequency register, or the errors of Enqueue.
+func (o *HyInverter) SetFrequencyHz(hz float64) error {
+	if math.IsNaN(hz) || math.IsInf(hz, 0) || hz < 0 {
+		return fmt.Errorf("%w: %v Hz", ErrInvalidSpeed, hz)
+	}
+	frequency, saturated := HertzToFrequency(hz)
+	if saturated {
+		return fmt.Errorf("%w: %v Hz", ErrOutOfRange, hz)
+	}
+	return o.enqueueFrequency(frequency)
+}
+
+// enqueueFrequency queues a frequency in 0.01 Hz as internal hz word, see SetFrequencyHz.
+func (o *HyInverter) enqueueFrequency(frequency uint16) error {
+	if err := o.checkFrequencyLimits(frequency); err != nil {
+		return err
+	}
+	return o.enqueueWords(lineWord{text: hertzWordPrefix + strconv.FormatFloat(FrequencyToHertz(frequency), 'f', 2, 64), internal: true})
+}
+
+// checkFrequencyLimits returns ErrBelowMinimum or ErrAboveMaximum if the frequency exceeds
+// a speed limit and such commands are rejected.
+func (o *HyInverter) checkFrequencyLimits(frequency uint16) error {
+	minFrequency, maxFrequency := o.frequencyLimits()
+	if _, action := o.MinRpm(); action == RejectBelowMinimum && frequency != 0 && frequency < minFrequency {
+		return fmt.Errorf("%w: %v Hz < %v Hz", ErrBelowMinimum, FrequencyToHertz(frequency), FrequencyToHertz(minFrequency))
+	}
+	if o.AboveMaximumAction() == RejectAboveMaximum && maxFrequency != 0 && frequency > maxFrequency {
+		return fmt.Errorf("%w: %v Hz > %v Hz", ErrAboveMaximum, FrequencyToHertz(frequency), FrequencyToHertz(maxFrequency))
+	}
+	return nil
+}
+
+// clampFrequency returns the frequency limited to the speed limits if such commands are
+// clamped.
+func (o *HyInverter) clampFrequency(frequency uint16) uint16 {
+	minFrequency, maxFrequency := o.frequencyLimits()
+	if _, action := o.MinRpm(); action == ClampToMinimum && frequency != 0 && frequency < minFrequency {
+		frequency = minFrequency
+	}
+	if o.AboveMaximumAction() == ClampToMaximum && maxFrequency != 0 && frequency > maxFrequency {
+		frequency = maxFrequency
+	}
+	return frequency
+}
+
+// frequencyLimits converts SetMinRpm and MaxRpm into frequencies, 0 if a limit is not set
+// or the RPM factor is unknown.
+func (o *HyInverter) frequencyLimits() (minFrequency, maxFrequency uint16) {
+	if o.frequencyPerRpm <= 0 {
+		return 0, 0
+	}
+	minRpm, _ := o.MinRpm()
+	minFrequency, _ = o.rpmToFrequency(float64(minRpm))
+	maxFrequency, _ = o.rpmToFrequency(float64(o.MaxRpm()))
+	return minFrequency, maxFrequency
+}
+
+// executeHertz sends the frequency of a word queued by SetFrequencyHz.
+func (o *HyInverter) executeHertz(word string) error {
+	hz, err := strconv.ParseFloat(strings.TrimPrefix(word, hertzWordPrefix), 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidSpeed, word)
+	}
+	requested, _ := HertzToFrequency(hz)
+	if err := o.checkFrequencyLimits(requested); err != nil {
+		// Queued before the limits were changed
+		o.emit(Event{Type: CommandRejected, Err: err})
+		return err
+	}
+	frequency := o.clampFrequency(requested)
+	if frequency != requested {
+		o.emit(Event{Type: Clamped, Frequency: frequency, Rpm: o.frequencyToRpm(frequency),
+			RequestedFrequency: uint32(requested), RequestedRpm: o.frequencyToRpm(requested)})
+	}
+	if o.rampTo(frequency) {
+		return o.sendFrequency(frequency)
+	}
+	return nil
+}
+
+// enqueueWords queues command words like Enqueue. It is used by the methods which don't
+// take G-code, the words are built by the caller.
+func (o *HyInverter) enqueueWords(words ...lineWord) error {
+	o.lifecycleMutex.RLock()
+	defer o.lifecycleMutex.RUnlock()
+	if err := o.stateError(); err != nil {
+		return err
+	}
+	o.Keepalive()
+	if err := o.checkWords(words); err != nil {
+		return err
+	}
+	for _, word := range words {
+		if !o.queue(word, nil) {
+			return ErrQueueFull
+		}
+	}
+	return nil
+}
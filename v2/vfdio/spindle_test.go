@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSpindleMethods(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 24000
+	if err := hy.SetSpeedRpm(12000); err != nil {
+		t.Fatal(err)
+	}
+	if err := hy.Start(Backward); err != nil {
+		t.Fatal(err)
+	}
+	if err := hy.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for len(hy.cmdChannel) > 0 {
+		hy.processNext()
+	}
+	frequency, _ := hy.rpmToFrequency(12000)
+	expected := append(append(hy.frequencyFrame(frequency), hy.controlFrame(CommandRunBackward)...), hy.controlFrame(CommandStop)...)
+	if frames := port.Bytes(); !bytes.HasPrefix(frames, expected) {
+		// The stop is repeated until the VFD confirms it.
+		t.Errorf("expected frames % X, got % X", expected, frames)
+	}
+
+	hy.SetMinRpm(3000, RejectBelowMinimum)
+	if err := hy.SetSpeedRpm(1000); !errors.Is(err, ErrBelowMinimum) {
+		t.Errorf("expected ErrBelowMinimum, got %v", err)
+	}
+	hy.lifecycle = closed
+	if err := hy.Start(Forward); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestSetFrequencyHz(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.frequencyPerRpm = 0
+	if err := hy.SetFrequencyHz(123.45); err != nil {
+		t.Fatal(err)
+	}
+	hy.processNext()
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 1 || frequencies[0] != 12345 {
+		t.Fatalf("expected 12345, got %v", frequencies)
+	}
+	for _, hz := range []float64{-1, math.NaN(), 700} {
+		if err := hy.SetFrequencyHz(hz); err == nil {
+			t.Errorf("%v Hz accepted", hz)
+		}
+	}
+
+	hy.frequencyPerRpm = 3.47222
+	hy.maxRpm = 11520
+	if err := hy.SetFrequencyHz(500); err != nil {
+		t.Fatal(err)
+	}
+	hy.processNext()
+	maxFrequency, _ := hy.rpmToFrequency(11520)
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 2 || frequencies[1] != maxFrequency {
+		t.Fatalf("expected the maximum %d, got %v", maxFrequency, frequencies)
+	}
+	hy.SetAboveMaximum(RejectAboveMaximum)
+	if err := hy.SetFrequencyHz(500); !errors.Is(err, ErrAboveMaximum) {
+		t.Errorf("expected ErrAboveMaximum, got %v", err)
+	}
+	hy.SetMinRpm(6000, RejectBelowMinimum)
+	if err := hy.SetFrequencyHz(100); !errors.Is(err, ErrBelowMinimum) {
+		t.Errorf("expected ErrBelowMinimum, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueStalled is reported with a QueueStalled event.
+var ErrQueueStalled = errors.New("vfdio: command queue not processed")
+
+// defaultStallTimeout is long enough for a stop escalation and a parameter transaction.
+const defaultStallTimeout = 5 * time.Second
+
+// SetStallDetection configures the queue watchdog. If commands are queued, but the processor
+// neither took one nor wrote a frame within the timeout, e.g. because a write blocks, a
+// QueueStalled event is raised. If reset is set, the port is closed and opened again as
+// after a failure, which usually ends a blocked write. Ports passed to OpenPort are not
+// reset. A timeout of 0 disables the watchdog. Default: 5 seconds, no reset.
+func (o *HyInverter) SetStallDetection(timeout time.Duration, reset bool) {
+	o.stateMutex.Lock()
+	o.stallTimeout = timeout
+	o.stallReset = reset
+	o.stallConfigured = true
+	o.stateMutex.Unlock()
+}
+
+// StallDetection returns the settings of SetStallDetection.
+func (o *HyInverter) StallDetection() (timeout time.Duration, reset bool) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if !o.stallConfigured {
+		return defaultStallTimeout, false
+	}
+	return o.stallTimeout, o.stallReset
+}
+
+// markProgress is called when the processor took a command or wrote a frame.
+func (o *HyInverter) markProgress() {
+	o.stateMutex.Lock()
+	o.lastProgress = o.clockLocked().Now()
+	o.stateMutex.Unlock()
+}
+
+// checkStall raises QueueStalled if the queue was not processed within the timeout and
+// QueueResumed once it is processed again. queuedSince is the time the queue was last seen
+// empty.
+func (o *HyInverter) checkStall(now, queuedSince time.Time) {
+	timeout, reset := o.StallDetection()
+	o.stateMutex.Lock()
+	since := o.lastProgress
+	if queuedSince.After(since) {
+		since = queuedSince
+	}
+	stalled := timeout > 0 && len(o.cmdChannel) > 0 && now.Sub(since) > timeout && o.dwellCommand == nil && !o.paused
+	changed := stalled != o.stalled
+	o.stalled = stalled
+	o.stateMutex.Unlock()
+	if !changed {
+		return
+	}
+	if !stalled {
+		o.emit(Event{Type: QueueResumed})
+		return
+	}
+	o.emit(Event{Type: QueueStalled, Err: ErrQueueStalled})
+	if reset && o.reconnectChannel != nil && atomic.CompareAndSwapInt32(&o.reconnecting, 0, 1) {
+		o.emit(Event{Type: Disconnected, Err: ErrQueueStalled})
+		o.reconnectChannel <- struct{}{}
+	}
+}
+
+func stallWatchdog(handle *HyInverter) {
+	queuedSince := handle.now()
+	for handle.sleep(time.Second) {
+		now := handle.now()
+		if len(handle.cmdChannel) == 0 {
+			queuedSince = now
+		}
+		handle.checkStall(now, queuedSince)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStallDetection(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.reconnectChannel = make(chan struct{}, 1)
+	hy.SetStallDetection(time.Second, true)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	start := time.Now()
+	hy.markProgress()
+	hy.GCode("M3")
+	hy.checkStall(start.Add(500*time.Millisecond), start)
+	if len(events) != 0 {
+		t.Fatalf("stall reported early: %+v", events)
+	}
+	hy.checkStall(start.Add(2*time.Second), start)
+	hy.checkStall(start.Add(3*time.Second), start)
+	if len(events) != 2 || events[0].Type != QueueStalled || events[0].Err != ErrQueueStalled || events[1].Type != Disconnected {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if len(hy.reconnectChannel) != 1 {
+		t.Fatal("port not reset")
+	}
+	hy.processNext()
+	hy.checkStall(time.Now(), start)
+	if len(events) != 3 || events[2].Type != QueueResumed {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestStallDetectionIdleQueue(t *testing.T) {
+	hy, _ := newTestInverter()
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.markProgress()
+	// Queued just now after a long idle time.
+	now := time.Now().Add(time.Hour)
+	hy.GCode("M3")
+	hy.checkStall(now, now)
+	if len(events) != 0 {
+		t.Fatalf("idle time reported as stall: %+v", events)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets.
+var LatencyBuckets = [...]time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram counts transaction latencies, the time from writing a request until its
+// answer was decoded.
+type LatencyHistogram struct {
+	// Counts[i] is the number of transactions not slower than LatencyBuckets[i].
+	// The last entry counts the transactions slower than all buckets.
+	Counts [len(LatencyBuckets) + 1]uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// Mean returns the average latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Percentile returns the upper bound of the bucket containing the given percentile (0-100).
+// Max is returned for transactions slower than all buckets.
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(p/100*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, count := range h.Counts[:len(LatencyBuckets)] {
+		if seen += count; seen >= rank {
+			return LatencyBuckets[i]
+		}
+	}
+	return h.Max
+}
+
+func (h *LatencyHistogram) add(latency time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += latency
+	if latency > h.Max {
+		h.Max = latency
+	}
+}
+
+// Stats are the transaction counters of a HyInverter.
+type Stats struct {
+	// Requests is the number of frames written.
+	Requests uint64
+	// Responses is the number of answers matched to a request.
+	Responses uint64
+	// Unanswered is the number of requests without an answer before the next request was sent.
+	Unanswered uint64
+	// TimingViolations is the number of frames rejected by SetStrictTiming.
+	TimingViolations uint64
+	// CRCErrors is the number of received frames which were discarded because of their CRC.
+	CRCErrors uint64
+	Latency   LatencyHistogram
+}
+
+// txStats tracks the outstanding request. The protocol allows only one at a time.
+type txStats struct {
+	mutex   sync.Mutex
+	stats   Stats
+	waiting bool
+	pending Function
+	sentAt  time.Time
+	// failures counts unanswered requests in a row for the circuit breaker.
+	failures int
+	open     bool
+	// command is the record of the command being executed, nil for status reads.
+	command *CommandRecord
+}
+
+// Stats returns the transaction counters and latency histogram since Open or ResetStats.
+// Use them to tune the poll interval and read timeout on marginal links.
+func (o *HyInverter) Stats() Stats {
+	o.txStats.mutex.Lock()
+	defer o.txStats.mutex.Unlock()
+	return o.txStats.stats
+}
+
+// ResetStats clears the transaction counters and the latency histogram.
+func (o *HyInverter) ResetStats() {
+	o.txStats.mutex.Lock()
+	defer o.txStats.mutex.Unlock()
+	o.txStats.stats = Stats{}
+}
+
+// markSent starts the latency measurement of a request.
+func (o *HyInverter) markSent(frame []byte) {
+	s := &o.txStats
+	now := o.now()
+	s.mutex.Lock()
+	unanswered := s.waiting
+	if unanswered {
+		s.stats.Unanswered++
+	}
+	s.stats.Requests++
+	s.waiting = true
+	s.pending = Function(frame[1])
+	s.sentAt = now
+	if s.command != nil && s.command.Transmitted.IsZero() {
+		s.command.Transmitted = s.sentAt
+	}
+	s.mutex.Unlock()
+	if unanswered {
+		o.countFailure()
+	}
+}
+
+// markAnswered records the latency if the answer belongs to the outstanding request.
+func (o *HyInverter) markAnswered(function Function) {
+	s := &o.txStats
+	now := o.now()
+	s.mutex.Lock()
+	if !s.waiting || s.pending != function {
+		s.mutex.Unlock()
+		return
+	}
+	s.waiting = false
+	s.stats.Responses++
+	s.stats.Latency.add(now.Sub(s.sentAt))
+	if s.command != nil {
+		s.command.Acknowledged = now
+	}
+	s.mutex.Unlock()
+	o.countSuccess()
+}
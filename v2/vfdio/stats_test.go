@@ -0,0 +1,54 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	hy, _ := newTestInverter()
+	// The first read is never answered, the second one is.
+	hy.write(hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	hy.write(hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	time.Sleep(15 * time.Millisecond)
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	// A second answer does not match a request.
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x00, 0x00}))
+	stats := hy.Stats()
+	if stats.Requests != 2 || stats.Responses != 1 || stats.Unanswered != 1 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+	h := stats.Latency
+	if h.Count != 1 || h.Max < 15*time.Millisecond || h.Counts[0] != 0 || h.Counts[1] != 0 {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	hy.ResetStats()
+	if hy.Stats().Requests != 0 {
+		t.Fatal("stats not reset")
+	}
+}
+
+func TestLatencyHistogramPercentile(t *testing.T) {
+	var h LatencyHistogram
+	for i := 0; i < 98; i++ {
+		h.add(3 * time.Millisecond)
+	}
+	h.add(70 * time.Millisecond)
+	h.add(3 * time.Second)
+	if p := h.Percentile(50); p != 5*time.Millisecond {
+		t.Fatalf("unexpected median %v", p)
+	}
+	if p := h.Percentile(99); p != 100*time.Millisecond {
+		t.Fatalf("unexpected 99th percentile %v", p)
+	}
+	if p := h.Percentile(100); p != 3*time.Second {
+		t.Fatalf("unexpected maximum %v", p)
+	}
+	if h.Counts[len(LatencyBuckets)] != 1 {
+		t.Fatalf("unexpected overflow count %v", h.Counts)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/registers"
+	"sync/atomic"
+)
+
+// StatusValue selects one of the values returned by the "read control status" function (0x04).
+type StatusValue byte
+
+// Status values of the Huanyang protocol. Frequencies are reported in 0.01 Hz,
+// currents and voltages in 0.1 A and 0.1 V.
+const (
+	StatusSetFrequency    StatusValue = registers.StatusSetFrequency
+	StatusOutputFrequency StatusValue = registers.StatusOutputFrequency
+	StatusOutputCurrent   StatusValue = registers.StatusOutputCurrent
+	StatusRpm             StatusValue = registers.StatusRpm
+	StatusDCVoltage       StatusValue = registers.StatusDCVoltage
+	StatusACVoltage       StatusValue = registers.StatusACVoltage
+	StatusCounter         StatusValue = registers.StatusCounter
+	StatusTemperature     StatusValue = registers.StatusTemperature
+)
+
+const statusValueCount = registers.StatusValueCount
+
+// SetPollValues selects the status values which are read in every polling cycle.
+// The protocol transports one value per frame, so a cycle sends the reads back to back
+// right after the poll interval elapsed. Reads still pending from the last cycle are not
+// queued twice. Default: StatusOutputFrequency.
+func (o *HyInverter) SetPollValues(values ...StatusValue) {
+	selected := make([]StatusValue, 0, len(values))
+	for _, value := range values {
+		if value < statusValueCount {
+			selected = append(selected, value)
+		}
+	}
+	o.pollMutex.Lock()
+	o.pollValues = selected
+	o.pollMutex.Unlock()
+}
+
+// PollValues returns the status values read in every polling cycle.
+func (o *HyInverter) PollValues() []StatusValue {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	if o.pollValues == nil {
+		return []StatusValue{StatusOutputFrequency}
+	}
+	return append([]StatusValue(nil), o.pollValues...)
+}
+
+// RawStatus returns the last value received for the given status value, unscaled.
+// Please also check Online() to see if the value is valid.
+func (o *HyInverter) RawStatus(value StatusValue) uint16 {
+	if value >= statusValueCount {
+		return 0
+	}
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	return o.status[value]
+}
+
+// requestStatus queues status reads. Values which are already pending are skipped.
+func (o *HyInverter) requestStatus(values ...StatusValue) {
+	for _, value := range values {
+		if !atomic.CompareAndSwapInt32(&o.pollPending[value], 0, 1) {
+			continue
+		}
+		select {
+		case o.pollChannel <- value:
+		default:
+			atomic.StoreInt32(&o.pollPending[value], 0)
+		}
+	}
+}
+
+// readStatus reads a single status value, the fault parameter or the control status through
+// the driver. The driver passed to OpenDriver is polled for any value.
+func (o *HyInverter) readStatus(value StatusValue) {
+	o.busMutex.Lock()
+	defer o.busMutex.Unlock()
+	atomic.StoreInt32(&o.pollPending[value], 0)
+	if value == pollCycleEnd {
+		o.finishPollCycle()
+		return
+	}
+	o.currentDriver().read(value)
+}
+
+// OutputHertz returns the output frequency reported by the VFD in Hz.
+// Please also check Online() to see if the value is valid.
+func (o *HyInverter) OutputHertz() float64 {
+	return FrequencyToHertz(o.OutputFrequency())
+}
+
+// OutputCurrentAmps returns the last output current reported by the VFD in ampere.
+// Add StatusOutputCurrent to the poll values to keep it up to date.
+func (o *HyInverter) OutputCurrentAmps() float64 {
+	return float64(o.RawStatus(StatusOutputCurrent)) / 10
+}
+
+// OutputVoltage returns the last output voltage reported by the VFD in volt.
+// Add StatusACVoltage to the poll values to keep it up to date.
+func (o *HyInverter) OutputVoltage() float64 {
+	return float64(o.RawStatus(StatusACVoltage)) / 10
+}
+
+// DCBusVoltage returns the last DC bus voltage reported by the VFD in volt. Logging it
+// helps to find supply sags and surges which trip the drive.
+// Add StatusDCVoltage to the poll values to keep it up to date.
+func (o *HyInverter) DCBusVoltage() float64 {
+	return float64(o.RawStatus(StatusDCVoltage)) / 10
+}
+
+// Temperature returns the last drive temperature reported by the VFD in °C.
+// Add StatusTemperature to the poll values to keep it up to date.
+func (o *HyInverter) Temperature() float64 {
+	return float64(o.RawStatus(StatusTemperature))
+}
+
+// SetTemperatureLimit enables the HighTemperature event above the given drive temperature
+// in °C, e.g. to switch an enclosure fan or to reduce the load. 0 disables the event.
+func (o *HyInverter) SetTemperatureLimit(celsius float64) {
+	o.stateMutex.Lock()
+	o.temperatureLimit = celsius
+	o.stateMutex.Unlock()
+}
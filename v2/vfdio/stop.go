@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrStopNotAcknowledged is reported with a StopFailed event.
+var ErrStopNotAcknowledged = errors.New("vfdio: stop command not acknowledged by the VFD")
+
+// Stop escalation timing. The retry interval covers the answer time plus the default
+// inter character timeout of the port.
+const (
+	defaultStopDeadline = time.Second
+	stopRetryInterval   = 200 * time.Millisecond
+	stopAckPollInterval = 5 * time.Millisecond
+)
+
+// SetStopEscalation configures the delivery of stop commands. A stop frame is repeated until
+// the VFD acknowledges it. If no acknowledgment arrived within the deadline, a StopFailed
+// event is raised and the fallback is called, e.g. to open a relay in the VFD's enable or
+// mains circuit. The fallback may be nil. A deadline of 0 sends the stop frame only once.
+// Default: 1 second, no fallback.
+func (o *HyInverter) SetStopEscalation(deadline time.Duration, fallback func()) {
+	o.stateMutex.Lock()
+	o.stopDeadline = deadline
+	o.stopFallback = fallback
+	o.stopConfigured = true
+	o.stateMutex.Unlock()
+}
+
+func (o *HyInverter) stopEscalation() (deadline time.Duration, fallback func()) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if !o.stopConfigured {
+		return defaultStopDeadline, nil
+	}
+	return o.stopDeadline, o.stopFallback
+}
+
+// isStopWord returns true for M5 and the other words which stop the spindle.
+func isStopWord(word string) bool {
+	command, ok := controlCommand(strings.TrimSpace(strings.ToLower(word)))
+	return ok && command == CommandStop
+}
+
+// sendStop stops the spindle through the driver and keeps the stop frame to restore the
+// state after a reconnect. The driver escalates if the stop fails, see SetStopEscalation.
+func (o *HyInverter) sendStop() error {
+	frame := o.controlFrame(CommandStop)
+	o.stateMutex.Lock()
+	o.lastControlFrame = frame
+	o.stateMutex.Unlock()
+	return o.currentDriver().Stop()
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopEscalation(t *testing.T) {
+	hy, port := newTestInverter()
+	fallbackCalled := false
+	hy.SetStopEscalation(500*time.Millisecond, func() { fallbackCalled = true })
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	hy.GCode("M5")
+	hy.processNext()
+	// Sent at 0, 200 and 400 ms.
+	if frames := port.Bytes(); len(frames) != 3*6 || frames[3] != byte(CommandStop) {
+		t.Fatalf("unexpected frames % X", frames)
+	}
+	if !fallbackCalled || len(events) != 1 || events[0].Type != StopFailed || events[0].Err != ErrStopNotAcknowledged {
+		t.Fatalf("no escalation: fallback %v, events %+v", fallbackCalled, events)
+	}
+}
+
+func TestStopAcknowledged(t *testing.T) {
+	hy, port := newTestInverter()
+	fallbackCalled := false
+	hy.SetStopEscalation(500*time.Millisecond, func() { fallbackCalled = true })
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, 0x00}))
+	}()
+	start := time.Now()
+	hy.GCode("M5")
+	hy.processNext()
+	if len(port.Bytes()) != 6 || fallbackCalled {
+		t.Fatalf("unexpected retries % X", port.Bytes())
+	}
+	if elapsed := time.Since(start); elapsed > stopRetryInterval {
+		t.Fatalf("acknowledgment not detected, took %v", elapsed)
+	}
+}
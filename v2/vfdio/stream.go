@@ -0,0 +1,345 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamOptions configures StreamProgram.
+type StreamOptions struct {
+	// Name is reported in errors, usually the file name of the program.
+	Name string
+	// MaxLineLength limits the bytes of a single line. Longer lines are rejected instead of
+	// being buffered. Default: DefaultMaxLineLength.
+	MaxLineLength int
+	// Feedforward sends the speeds of gradual S ramps, e.g. for thread whirling, early by the
+	// measured RampLatency, so the actual RPM tracks the program more closely. Within a ramp,
+	// each S word is extrapolated along the slope of the previous step, timed by the arrival
+	// of the lines. The lead is limited to one step. Default: false.
+	Feedforward bool
+	// Tee receives every line of the program after its spindle words were queued, so the
+	// stream can be forwarded to a motion controller. The words consumed by the spindle are
+	// removed: S words and M3, M4 and M5. G4 is forwarded with its time, the spindle dwells as
+	// well. Lines holding nothing else are not forwarded.
+	// Each line is written with a single Write and ends with LF. Use TeeFunc for a callback.
+	// A write error stops the stream. Default: nil.
+	Tee io.Writer
+	// LineNumbers enables the line number and checksum protocol of GRBL and RepRap style
+	// hosts for noisy links: a line may start with an N word and end with *checksum, the
+	// XOR of the bytes before the asterisk. A line with a wrong checksum or an unexpected
+	// number is dropped and answered with "Error:reason", "Resend: n" and "ok", n is the
+	// expected number. Accepted lines are answered with "ok". M110 Nn sets the number of a
+	// line. Lines without N word are accepted. Default: false.
+	LineNumbers bool
+	// Reply receives the answers of the line number protocol, usually the link to the host.
+	// It is required for LineNumbers.
+	Reply io.Writer
+	// Progress is called after the words of each line were queued, e.g. to show the
+	// progress of a job. Default: nil.
+	Progress func(StreamProgress)
+	// StopAtEnd queues M5 after the last line, so the spindle stops when the program ends
+	// without M5, e.g. after a truncated file. It is not queued if the stream stopped with an
+	// error. Default: false.
+	StopAtEnd bool
+}
+
+// StreamProgress is passed to StreamOptions.Progress.
+type StreamProgress struct {
+	// Line is the number of the queued line, 1-based.
+	Line int
+	// Bytes is the number of bytes read up to the end of the line. Compare it with the size
+	// of the file for a percentage.
+	Bytes int64
+}
+
+// TeeFunc adapts a callback to StreamOptions.Tee. It is called with every forwarded line
+// without the line ending.
+type TeeFunc func(line string) error
+
+// Write calls f with p as line.
+func (f TeeFunc) Write(p []byte) (int, error) {
+	return len(p), f(strings.TrimSuffix(string(p), "\n"))
+}
+
+// DefaultMaxLineLength is the line length limit used if StreamOptions.MaxLineLength is not set.
+const DefaultMaxLineLength = 1024
+
+// LineError describes a rejected program line. Line and Column are 1-based,
+// Column counts bytes.
+type LineError struct {
+	Name   string
+	Line   int
+	Column int
+	Word   string
+	Reason string
+}
+
+func (e *LineError) Error() string {
+	if e.Word == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Column, e.Reason)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %q", e.Name, e.Line, e.Column, e.Reason, e.Word)
+}
+
+// StreamProgram reads a G-code program line by line and queues its spindle commands (M and S words).
+// Other words are checked for syntax but not queued. Unlike GCode, it waits for queue space.
+// Lines may end with LF, CRLF or CR. A leading UTF-8 BOM and control characters
+// (e.g. a trailing DOS end-of-file marker) are ignored. Lines longer than
+// StreamOptions.MaxLineLength and binary data are rejected.
+// The first rejected line stops the stream; the error is a *LineError in that case.
+// EStop stops the stream with ErrEStopped. The context's error is returned if it is done
+// before the program was queued, e.g. to cancel a job which waits for queue space. The
+// words queued before stay queued. Run a file:
+//
+//   file, err := os.Open("job.nc")
+//   if err != nil {
+//       return err
+//   }
+//   defer file.Close()
+//   err = handle.StreamProgram(ctx, file, vfdio.StreamOptions{Name: "job.nc", StopAtEnd: true})
+//
+func (o *HyInverter) StreamProgram(ctx context.Context, r io.Reader, opts StreamOptions) error {
+	if err := o.checkWritable(); err != nil {
+		return err
+	}
+	var numbers *lineNumbers
+	if opts.LineNumbers {
+		if opts.Reply == nil {
+			return ErrNoReply
+		}
+		numbers = &lineNumbers{reply: opts.Reply}
+	}
+	maxLineLength := opts.MaxLineLength
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	scanner := bufio.NewScanner(r)
+	var read int64
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanProgramLines(data, atEOF)
+		read += int64(advance)
+		return advance, token, err
+	})
+	// Room for the line ending and the BOM
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength+len(utf8BOM)+2)
+	lineNumber := 0
+	var lead speedLead
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lineNumber++
+		line := scanner.Text()
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		if numbers != nil {
+			body, skip, err := numbers.accept(line)
+			if err != nil {
+				return err
+			}
+			if skip {
+				continue
+			}
+			line = body
+		}
+		var words []lineWord
+		var consumed [][2]int
+		issue := checkText(line, maxLineLength)
+		if issue == nil {
+			words, consumed, issue = o.checkLine(line)
+		}
+		if issue != nil {
+			issue.Name = opts.Name
+			issue.Line = lineNumber
+			return issue
+		}
+		words = o.orderWords(words)
+		for _, word := range words {
+			if err := o.checkOpen(); err != nil {
+				return err
+			}
+			if err := o.checkEStop(word.text); err != nil {
+				return err
+			}
+			if opts.Feedforward && !word.internal && (word.text[0] == 's' || word.text[0] == 'S') {
+				word.text = o.leadWord(&lead, word.text)
+			}
+			o.Keepalive()
+			if err := o.queueContext(ctx, word, nil); err != nil {
+				return err
+			}
+		}
+		if opts.Tee != nil {
+			if rest, ok := passThrough(line, consumed); ok {
+				if _, err := io.WriteString(opts.Tee, rest+"\n"); err != nil {
+					return err
+				}
+			}
+		}
+		if numbers != nil {
+			if err := numbers.ok(); err != nil {
+				return err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(StreamProgress{Line: lineNumber, Bytes: read})
+		}
+	}
+	if scanner.Err() == bufio.ErrTooLong {
+		return &LineError{Name: opts.Name, Line: lineNumber + 1, Column: maxLineLength + 1,
+			Reason: fmt.Sprintf("line exceeds %d bytes", maxLineLength)}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if opts.StopAtEnd {
+		return o.queueContext(ctx, lineWord{text: "M5"}, nil)
+	}
+	return nil
+}
+
+// checkText rejects lines which are too long or look like binary data, e.g. if the wrong file was selected.
+func checkText(line string, maxLineLength int) *LineError {
+	if len(line) > maxLineLength {
+		return &LineError{Column: maxLineLength + 1, Reason: fmt.Sprintf("line exceeds %d bytes", maxLineLength)}
+	}
+	controls := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == 0 {
+			return &LineError{Column: i + 1, Reason: "binary data"}
+		}
+		if (c < 0x20 && c != '\t' && c != '\v' && c != '\f') || c == 0x7F {
+			controls++
+		}
+	}
+	// A few control characters are emitted by DNC software, but not a quarter of the line.
+	if controls > 2 && controls*4 > len(line) {
+		return &LineError{Column: 1, Reason: "binary data"}
+	}
+	return nil
+}
+
+// checkLine splits a program line into words and returns its spindle words and the
+// positions of the words consumed by the spindle, see passThrough.
+// Comments in parentheses and after a semicolon are skipped, see ParseLine. G4 and its P or
+// S word are returned as dwell word, see mergeDwell, and forwarded to the motion controller.
+func (o *HyInverter) checkLine(line string) (spindleWords []lineWord, consumed [][2]int, issue *LineError) {
+	if strings.TrimSpace(line) == "%" {
+		// Program start/end marker
+		return
+	}
+	t := tokenizer{line: line}
+	var dwell *Word
+	for {
+		w, ok, err := t.next()
+		if err != nil {
+			return nil, nil, &LineError{Column: err.Offset + 1, Word: err.Text, Reason: err.Reason}
+		}
+		if dwell != nil {
+			if !ok || (w.Letter != 'P' && w.Letter != 'S') {
+				return nil, nil, &LineError{Column: dwell.Offset + 1, Word: dwell.Text, Reason: "dwell without time"}
+			}
+			word := lineWord{text: dwellWordPrefix + w.Text[1:], internal: true}
+			if _, err := parseDwell(word.text); err != nil {
+				return nil, nil, &LineError{Column: w.Offset + 1, Word: w.Text, Reason: "invalid dwell time"}
+			}
+			spindleWords = append(spindleWords, word)
+			dwell = nil
+			continue
+		}
+		if !ok {
+			return
+		}
+		start, end, word, value := w.Offset, w.Offset+len(w.Text), w.Text, w.Value
+		switch w.Letter {
+		case 'G':
+			if value == 4 {
+				dwell = &w
+			}
+		case 'S':
+			if value < 0 {
+				return nil, nil, &LineError{Column: start + 1, Word: word, Reason: "negative speed"}
+			}
+			if o.checkMaxRpm(value) != nil {
+				return nil, nil, &LineError{Column: start + 1, Word: word, Reason: fmt.Sprintf("speed exceeds maximum of %d", o.MaxRpm())}
+			}
+			if minRpm, _ := o.MinRpm(); o.checkMinRpm(value) != nil {
+				return nil, nil, &LineError{Column: start + 1, Word: word, Reason: fmt.Sprintf("speed below minimum of %d", minRpm)}
+			}
+			spindleWords = append(spindleWords, lineWord{text: word})
+			consumed = append(consumed, [2]int{start, end})
+		case 'M':
+			if o.checkReverse(word) != nil {
+				return nil, nil, &LineError{Column: start + 1, Word: word, Reason: "reverse rotation locked out"}
+			}
+			spindleWords = append(spindleWords, lineWord{text: word})
+			if value == 3 || value == 4 || value == 5 {
+				consumed = append(consumed, [2]int{start, end})
+			}
+		}
+	}
+}
+
+// passThrough returns the line without the words consumed by the spindle: S words and
+// M3, M4 and M5. Other words, e.g. M30, are kept for the motion controller. Blanks after a
+// removed word are removed as well. Ok is false if nothing but spindle words was left.
+func passThrough(line string, consumed [][2]int) (rest string, ok bool) {
+	if len(consumed) == 0 {
+		return line, true
+	}
+	var b strings.Builder
+	next := 0
+	for _, span := range consumed {
+		b.WriteString(line[next:span[0]])
+		for next = span[1]; next < len(line) && (line[next] == ' ' || line[next] == '\t'); next++ {
+		}
+	}
+	b.WriteString(line[next:])
+	rest = strings.TrimRight(b.String(), " \t")
+	return rest, strings.TrimSpace(rest) != ""
+}
+
+const utf8BOM = "\xEF\xBB\xBF"
+
+// scanProgramLines is a bufio.SplitFunc which accepts LF, CRLF and CR line endings.
+func scanProgramLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, c := range data {
+		switch c {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// Request more data to decide between CR and CRLF.
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
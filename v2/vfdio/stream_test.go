@@ -0,0 +1,237 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bufio"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamProgram(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 11520
+	program := "%\n(Spindle warm-up)\nG21 G90\nM3 S3000 ; start\nG0 X10.5 Y-2\nM5\n%\n"
+	if err := hy.StreamProgram(context.Background(), strings.NewReader(program), StreamOptions{Name: "warmup.nc"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"M3", "S3000", "M5"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != expected {
+			t.Fatalf("expected %q, got %q", expected, cmd)
+		}
+	}
+	if len(hy.cmdChannel) != 0 {
+		t.Fatalf("unexpected commands left: %d", len(hy.cmdChannel))
+	}
+}
+
+func TestStreamProgramErrorPosition(t *testing.T) {
+	tests := []struct {
+		program string
+		err     LineError
+	}{
+		{"M3\nG0 X1 S20000\n", LineError{Name: "job.nc", Line: 2, Column: 7, Word: "S20000", Reason: "speed exceeds maximum of 11520"}},
+		{"M3 S100\n\nG1 X1..2\n", LineError{Name: "job.nc", Line: 3, Column: 4, Word: "X1..2", Reason: "malformed word"}},
+		{"G0 X1 # Y2\n", LineError{Name: "job.nc", Line: 1, Column: 7, Word: "#", Reason: "unexpected character"}},
+		{"G0 (comment\n", LineError{Name: "job.nc", Line: 1, Column: 4, Reason: "unterminated comment"}},
+		{"M3\nG4 X1\n", LineError{Name: "job.nc", Line: 2, Column: 1, Word: "G4", Reason: "dwell without time"}},
+		{"M3\nG4 P-1\n", LineError{Name: "job.nc", Line: 2, Column: 4, Word: "P-1", Reason: "invalid dwell time"}},
+	}
+	for _, test := range tests {
+		hy, _ := newTestInverter()
+		hy.maxRpm = 11520
+		hy.SetAboveMaximum(RejectAboveMaximum)
+		err := hy.StreamProgram(context.Background(), strings.NewReader(test.program), StreamOptions{Name: "job.nc"})
+		lineErr, ok := err.(*LineError)
+		if !ok {
+			t.Errorf("%q: expected *LineError, got %v", test.program, err)
+			continue
+		}
+		if *lineErr != test.err {
+			t.Errorf("%q: expected %+v, got %+v", test.program, test.err, *lineErr)
+		}
+	}
+}
+
+func TestStreamProgramClamped(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 11520
+	if err := hy.StreamProgram(context.Background(), strings.NewReader("M3\nG0 X1 S20000\n"), StreamOptions{}); err != nil {
+		t.Fatalf("speed not clamped: %v", err)
+	}
+	if len(hy.cmdChannel) != 2 {
+		t.Fatalf("expected 2 queued words, got %d", len(hy.cmdChannel))
+	}
+}
+
+// Post-processor output samples with their spindle commands.
+var postProcessorSamples = []struct {
+	name    string
+	program string
+	words   []string
+}{
+	{
+		"Fusion 360 (Windows line endings, BOM)",
+		"\xEF\xBB\xBF%\r\nO1001\r\n(T1  D=6. CR=0. - ZMIN=-3. - FLAT END MILL)\r\nN10 G90 G94 G17\r\nN15 G21\r\n" +
+			"N20 T1 M6\r\nN25 S12000 M3\r\nN30 G0 X10. Y-2.5\r\nN35 G1 Z-.5 F300.\r\nN40 M5\r\nN45 M30\r\n%\r\n",
+		[]string{"M6", "S12000", "M3", "M5", "M30"},
+	},
+	{
+		"Vectric (trailing whitespace, DOS end of file marker)",
+		"T1\r\nG17\t\r\nG21 \r\nG0Z5.080\r\nG0X0.000Y0.000S16000M3  \r\nG1X10.000Y0.000Z-1.000F1200.0\r\nM5\r\nM30\r\n\x1a",
+		[]string{"S16000", "M3", "M5", "M30"},
+	},
+	{
+		"Legacy Mac export (CR line endings)",
+		"(Engraving)\rM3 S9000\rG1 X1 Y1 F100\rM5\r",
+		[]string{"M3", "S9000", "M5"},
+	},
+}
+
+func TestStreamProgramPostProcessorSamples(t *testing.T) {
+	for _, sample := range postProcessorSamples {
+		hy, _ := newTestInverter()
+		hy.maxRpm = 24000
+		hy.cmdChannel = make(chan queuedCommand, 20)
+		if err := hy.StreamProgram(context.Background(), strings.NewReader(sample.program), StreamOptions{Name: sample.name}); err != nil {
+			t.Errorf("%s: %v", sample.name, err)
+			continue
+		}
+		close(hy.cmdChannel)
+		var words []string
+		for command := range hy.cmdChannel {
+			words = append(words, command.word)
+		}
+		if strings.Join(words, " ") != strings.Join(sample.words, " ") {
+			t.Errorf("%s: expected %q, got %q", sample.name, sample.words, words)
+		}
+	}
+}
+
+func TestScanProgramLines(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("a\r\nb\rc\n\nd\r"))
+	scanner.Split(scanProgramLines)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if strings.Join(lines, "|") != "a|b|c||d" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
+
+func TestStreamProgramRejectsPathologicalInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		opts    StreamOptions
+		err     LineError
+	}{
+		{"long line", "M3\n" + strings.Repeat("(x)", 1000) + "\nM5\n", StreamOptions{},
+			LineError{Line: 2, Column: 1025, Reason: "line exceeds 1024 bytes"}},
+		{"huge line", "M3\nG0 X1\n" + strings.Repeat("x", 1<<20), StreamOptions{MaxLineLength: 64},
+			LineError{Line: 3, Column: 65, Reason: "line exceeds 64 bytes"}},
+		{"NUL byte", "M3\nG0\x00X1\n", StreamOptions{},
+			LineError{Line: 2, Column: 3, Reason: "binary data"}},
+		{"binary", "\x7fELF\x02\x01\x01\x03\x04\x05\x06\x07\x08\x0e\x0f\x10\n", StreamOptions{},
+			LineError{Line: 1, Column: 1, Reason: "binary data"}},
+	}
+	for _, test := range tests {
+		hy, _ := newTestInverter()
+		err := hy.StreamProgram(context.Background(), strings.NewReader(test.program), test.opts)
+		lineErr, ok := err.(*LineError)
+		if !ok {
+			t.Errorf("%s: expected *LineError, got %v", test.name, err)
+			continue
+		}
+		if *lineErr != test.err {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.err, *lineErr)
+		}
+	}
+}
+
+func TestStreamProgramDwell(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 24000
+	var forwarded []string
+	tee := TeeFunc(func(line string) error {
+		forwarded = append(forwarded, line)
+		return nil
+	})
+	if err := hy.StreamProgram(context.Background(), strings.NewReader("M3 S12000\nG4 S0.2\nM5\n"), StreamOptions{Tee: tee}); err != nil {
+		t.Fatal(err)
+	}
+	if len(forwarded) != 1 || forwarded[0] != "G4 S0.2" {
+		t.Errorf("expected G4 to be forwarded, got %q", forwarded)
+	}
+	hy.processNext()
+	hy.processNext()
+	target := hy.TargetRpm()
+	start := time.Now()
+	hy.processNext()
+	if !hy.dwelling() {
+		t.Fatal("dwell not started")
+	}
+	for hy.QueueDepth() > 0 && time.Since(start) < 2*time.Second {
+		hy.processNext()
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("M5 sent after %v", elapsed)
+	}
+	if hy.TargetRpm() != target {
+		t.Errorf("dwell time taken as speed: target %d, expected %d", hy.TargetRpm(), target)
+	}
+}
+
+func TestStreamProgramTee(t *testing.T) {
+	hy, _ := newTestInverter()
+	var forwarded []string
+	tee := TeeFunc(func(line string) error {
+		forwarded = append(forwarded, line)
+		return nil
+	})
+	program := "%\nG21 G90\nN25 S12000 M3 (start)\nM3 S9000\nG0 X10 M8\nM05\nM30\n"
+	if err := hy.StreamProgram(context.Background(), strings.NewReader(program), StreamOptions{Tee: tee}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"%", "G21 G90", "N25 (start)", "G0 X10 M8", "M30"}
+	if strings.Join(forwarded, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected %q, got %q", expected, forwarded)
+	}
+}
+
+func TestStreamProgramCanceled(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 11520
+	program := "M3 S3000\nG0 X1\n"
+	var progress []StreamProgress
+	opts := StreamOptions{StopAtEnd: true, Progress: func(p StreamProgress) { progress = append(progress, p) }}
+	if err := hy.StreamProgram(context.Background(), strings.NewReader(program), opts); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []StreamProgress{{1, 9}, {2, 15}}; !reflect.DeepEqual(progress, expected) {
+		t.Errorf("expected progress %v, got %v", expected, progress)
+	}
+	for _, expected := range []string{"M3", "S3000", "M5"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != expected {
+			t.Fatalf("expected %q, got %q", expected, cmd)
+		}
+	}
+
+	// The queue is full, the stream waits for space until the context is done.
+	for hy.GCode("S3000") {
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hy.StreamProgram(ctx, strings.NewReader(program), opts); err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if queued := len(hy.cmdChannel); queued != DefaultQueueSize {
+		t.Errorf("unexpected queue length %d", queued)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Restart policy defaults.
+const (
+	defaultMaxRestarts  = -1
+	defaultRestartDelay = 100 * time.Millisecond
+)
+
+// PanicError is reported with a Panicked event if a goroutine of the library panicked.
+type PanicError struct {
+	// Goroutine is "parser", "processor", "poller", "reconnector" or "watchdog".
+	Goroutine string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the panic.
+	Stack []byte
+	// Restarted is false if the goroutine was not restarted, see SetRestartPolicy.
+	Restarted bool
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("vfdio: %s goroutine panicked: %v", e.Goroutine, e.Value)
+}
+
+// SetRestartPolicy configures what happens if a goroutine of the library panicked, e.g.
+// because of a malformed frame. The panic is recovered and reported with a Panicked event.
+// The goroutine is restarted after the delay, up to maxRestarts times. A negative maxRestarts
+// restarts it without limit, 0 never. A goroutine which was not restarted leaves the
+// HyInverter partially working; Close it. Default: no limit, 100 ms.
+func (o *HyInverter) SetRestartPolicy(maxRestarts int, delay time.Duration) {
+	o.stateMutex.Lock()
+	o.maxRestarts = maxRestarts
+	o.restartDelay = delay
+	o.restartConfigured = true
+	o.stateMutex.Unlock()
+}
+
+// restartPolicy returns the settings of SetRestartPolicy.
+func (o *HyInverter) restartPolicy() (maxRestarts int, delay time.Duration) {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	if !o.restartConfigured {
+		return defaultMaxRestarts, defaultRestartDelay
+	}
+	return o.maxRestarts, o.restartDelay
+}
+
+// supervise runs a goroutine of the library and restarts it after panics as configured by
+// SetRestartPolicy.
+func (o *HyInverter) supervise(name string, run func(*HyInverter)) {
+	for restarts := 0; ; restarts++ {
+		err := o.runRecovered(name, run)
+		if err == nil {
+			return
+		}
+		maxRestarts, delay := o.restartPolicy()
+		err.Restarted = !o.stopped() && (maxRestarts < 0 || restarts < maxRestarts)
+		o.emit(Event{Type: Panicked, Err: err})
+		if !err.Restarted {
+			return
+		}
+		if !o.sleep(delay) {
+			return
+		}
+	}
+}
+
+// runRecovered runs the goroutine and returns a *PanicError if it panicked.
+func (o *HyInverter) runRecovered(name string, run func(*HyInverter)) (err *PanicError) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Goroutine: name, Value: value, Stack: debug.Stack()}
+		}
+	}()
+	run(o)
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetRestartPolicy(-1, time.Millisecond)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	runs := 0
+	hy.supervise("parser", func(*HyInverter) {
+		runs++
+		if runs < 3 {
+			panic("malformed frame")
+		}
+	})
+	if runs != 3 || len(events) != 2 {
+		t.Fatalf("%d runs, events %+v", runs, events)
+	}
+	err, ok := events[0].Err.(*PanicError)
+	if events[0].Type != Panicked || !ok || err.Goroutine != "parser" || err.Value != "malformed frame" || !err.Restarted || len(err.Stack) == 0 {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+}
+
+func TestSuperviseRestartLimit(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetRestartPolicy(1, 0)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	runs := 0
+	hy.supervise("processor", func(*HyInverter) {
+		runs++
+		panic("bug")
+	})
+	if runs != 2 || len(events) != 2 || events[1].Err.(*PanicError).Restarted {
+		t.Fatalf("%d runs, events %+v", runs, events)
+	}
+}
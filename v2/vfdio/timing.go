@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimingViolation is reported with a TimingViolation event.
+var ErrTimingViolation = errors.New("vfdio: Modbus RTU timing violated")
+
+// Modbus RTU sends 11 bits per character. Above 19200 baud the specification uses fixed
+// timeouts instead of 1.5 and 3.5 character times.
+const (
+	bitsPerCharacter = 11
+	fixedTimingBaud  = 19200
+	fixedT15         = 750 * time.Microsecond
+	fixedT35         = 1750 * time.Microsecond
+)
+
+// SetStrictTiming enables the conformance mode for received frames. A frame is rejected if
+// a gap between two of its characters exceeds 1.5 character times (t1.5), or if it followed
+// the previous bytes with less than 3.5 character times (t3.5) of silence. Rejected frames
+// are logged as FrameTimingViolation, counted in Stats.TimingViolations and reported with a
+// TimingViolation event. Use it to identify flaky adapters and drives which break the
+// timing. The gaps are measured from the arrival times of the reads, so the mode is only
+// meaningful with a MinimumReadSize of 0 on a host which is not overloaded.
+// Default: false, frames are accepted whatever their timing.
+func (o *HyInverter) SetStrictTiming(enabled bool) {
+	o.stateMutex.Lock()
+	o.strictTiming = enabled
+	o.stateMutex.Unlock()
+}
+
+// StrictTiming returns true if the conformance mode is enabled.
+func (o *HyInverter) StrictTiming() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.strictTiming
+}
+
+// characterTimes returns the character time and t1.5 and t3.5 for the baud rate.
+func characterTimes(baudRate uint) (character, t15, t35 time.Duration) {
+	character = time.Duration(bitsPerCharacter * uint64(time.Second) / uint64(baudRate))
+	if baudRate > fixedTimingBaud {
+		return character, fixedT15, fixedT35
+	}
+	return character, character * 3 / 2, character * 7 / 2
+}
+
+// rxChunk is the result of a read. Start is the position of its first byte in the
+// received stream.
+type rxChunk struct {
+	start uint64
+	n     int
+	at    time.Time
+}
+
+// rxTiming records the arrival of the received bytes for the strict timing mode.
+type rxTiming struct {
+	chunks   []rxChunk
+	received uint64
+}
+
+// add records a read of n bytes which returned at the given time.
+func (t *rxTiming) add(n int, at time.Time) {
+	t.chunks = append(t.chunks, rxChunk{start: t.received, n: n, at: at})
+	t.received += uint64(n)
+}
+
+// reset forgets all chunks after a silent interval.
+func (t *rxTiming) reset() {
+	t.chunks = t.chunks[:0]
+}
+
+// check returns an error if the frame ending pending bytes before the end of the stream
+// violates the timing. The chunks in front of the frame are forgotten, except the last one.
+func (t *rxTiming) check(frameLength, pending int, baudRate uint) error {
+	end := t.received - uint64(pending)
+	start := end - uint64(frameLength)
+	for len(t.chunks) > 1 && t.chunks[1].start+uint64(t.chunks[1].n) <= start {
+		t.chunks = t.chunks[1:]
+	}
+	character, t15, t35 := characterTimes(baudRate)
+	for i := 1; i < len(t.chunks) && t.chunks[i].start < end; i++ {
+		previous, chunk := t.chunks[i-1], t.chunks[i]
+		// The read returns after the last byte of the chunk arrived.
+		gap := chunk.at.Sub(previous.at) - time.Duration(chunk.n)*character
+		if chunk.start == start && gap < t35 {
+			return fmt.Errorf("%w: silent interval of %v before the frame is shorter than t3.5 (%v)", ErrTimingViolation, gap, t35)
+		}
+		if chunk.start > start && gap > t15 {
+			return fmt.Errorf("%w: gap of %v between characters exceeds t1.5 (%v)", ErrTimingViolation, gap, t15)
+		}
+	}
+	return nil
+}
+
+// timingViolated reports a frame which violates the timing.
+func (o *HyInverter) timingViolated(frame []byte, err error) {
+	o.logFrame(Received, frame, FrameTimingViolation)
+	o.txStats.mutex.Lock()
+	o.txStats.stats.TimingViolations++
+	o.txStats.mutex.Unlock()
+	o.emit(Event{Type: TimingViolation, Err: err})
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCharacterTimes(t *testing.T) {
+	character, t15, t35 := characterTimes(9600)
+	if character != 1145833*time.Nanosecond || t15 != character*3/2 || t35 != character*7/2 {
+		t.Fatalf("9600 baud: %v %v %v", character, t15, t35)
+	}
+	if _, t15, t35 := characterTimes(38400); t15 != fixedT15 || t35 != fixedT35 {
+		t.Fatalf("38400 baud: %v %v", t15, t35)
+	}
+}
+
+func TestTimingCheck(t *testing.T) {
+	character, _, _ := characterTimes(9600)
+	start := time.Now()
+	tests := []struct {
+		name string
+		// The frame is made of the last 8 bytes of two reads. The second read returns
+		// after its transmission time plus delay.
+		first, second int
+		delay         time.Duration
+		violation     bool
+	}{
+		{"split frame without gap", 4, 4, 0, false},
+		{"split frame with gap", 4, 4, 5 * time.Millisecond, true},
+		{"frame after silent interval", 8, 8, 10 * time.Millisecond, false},
+		{"frame without silent interval", 8, 8, time.Millisecond, true},
+	}
+	for _, test := range tests {
+		var timing rxTiming
+		timing.add(test.first, start)
+		timing.add(test.second, start.Add(time.Duration(test.second)*character+test.delay))
+		err := timing.check(8, 0, 9600)
+		if violation := err != nil; violation != test.violation || (err != nil && !errors.Is(err, ErrTimingViolation)) {
+			t.Errorf("%s: unexpected result %v", test.name, err)
+		}
+	}
+}
+
+func TestTimingViolated(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.SetFrameLog(4)
+	hy.SetStrictTiming(true)
+	var events []Event
+	hy.Subscribe(func(e Event) { events = append(events, e) })
+	frame := hy.signMessage([]byte{0x01, 0x03, 0x01, 0x00})
+	hy.timingViolated(frame, ErrTimingViolation)
+	if hy.Stats().TimingViolations != 1 || len(events) != 1 || events[0].Type != TimingViolation {
+		t.Fatalf("violation not reported: %+v, events %+v", hy.Stats(), events)
+	}
+	if log := hy.FrameLog(); len(log) != 1 || log[0].Status != FrameTimingViolation {
+		t.Fatalf("violation not logged: %v", log)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Status is a snapshot of the state of the spindle, see HyInverter.Status and StatusUpdates.
+type Status struct {
+	// TargetRpm is the commanded speed.
+	TargetRpm uint16
+	// OutputRpm and OutputFrequency (0.01 Hz) are the last values reported by the VFD.
+	OutputRpm       uint16
+	OutputFrequency uint16
+	// Direction and Running are reported by the VFD, see SetRunStatePolling.
+	Direction Direction
+	Running   bool
+	Online    bool
+	// QueueDepth is the number of commands waiting to be sent.
+	QueueDepth int
+	// LastSeen is the time of the last valid message of the VFD.
+	LastSeen time.Time
+}
+
+// StatusUpdates returns a channel receiving a Status after every poll cycle answered by
+// the VFD, i.e. at the poll interval. A slow receiver gets the latest status, older ones are
+// dropped. Every call returns a new channel, it is closed by Close.
+func (o *HyInverter) StatusUpdates() <-chan Status {
+	updates := make(chan Status, 1)
+	o.eventMutex.Lock()
+	defer o.eventMutex.Unlock()
+	if o.updatesClosed {
+		close(updates)
+		return updates
+	}
+	o.statusUpdates = append(o.statusUpdates, updates)
+	return updates
+}
+
+// pollCycleEnd is queued like a status value after the values of a poll cycle.
+const pollCycleEnd StatusValue = statusValueCount + 3
+
+// startPollCycle records the start of a poll cycle. A cycle whose end is still queued
+// continues, e.g. if the poll interval is shorter than the requests take.
+func (o *HyInverter) startPollCycle() {
+	if atomic.LoadInt32(&o.pollPending[pollCycleEnd]) != 0 {
+		return
+	}
+	started := o.now()
+	o.pollMutex.Lock()
+	o.pollCycleStarted = started
+	o.pollMutex.Unlock()
+}
+
+// finishPollCycle publishes the status if the VFD answered during the poll cycle.
+func (o *HyInverter) finishPollCycle() {
+	o.pollMutex.Lock()
+	answered := !o.lastReceived.Before(o.pollCycleStarted)
+	o.pollMutex.Unlock()
+	if answered {
+		o.publishStatus(o.Status())
+	}
+}
+
+// publishStatus passes status to the channels of StatusUpdates, replacing a status which
+// was not received yet.
+func (o *HyInverter) publishStatus(status Status) {
+	o.eventMutex.Lock()
+	defer o.eventMutex.Unlock()
+	for _, updates := range o.statusUpdates {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- status
+	}
+}
+
+// closeStatusUpdates closes the channels of StatusUpdates.
+func (o *HyInverter) closeStatusUpdates() {
+	o.eventMutex.Lock()
+	defer o.eventMutex.Unlock()
+	for _, updates := range o.statusUpdates {
+		close(updates)
+	}
+	o.statusUpdates = nil
+	o.updatesClosed = true
+}
+
+// Status returns the state of the spindle captured at once, so the values belong
+// together, unlike the results of separate calls of OutputRpm, Running and so on.
+func (o *HyInverter) Status() Status {
+	o.pollMutex.Lock()
+	defer o.pollMutex.Unlock()
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	status := Status{
+		TargetRpm:       o.frequencyToRpm(o.setFrequency),
+		OutputRpm:       o.outputRpm,
+		OutputFrequency: o.outputFrequency,
+		Running:         o.controlStatus&ControlStatusRunning != 0,
+		Online:          o.clockLocked().Now().Sub(o.lastReceived) < o.onlineThresholdLocked(),
+		QueueDepth:      o.QueueDepth(),
+		LastSeen:        o.lastReceived,
+	}
+	if (o.controlStatus&ControlStatusReverse != 0) != o.invertDirection {
+		status.Direction = Backward
+	}
+	return status
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"testing"
+	"time"
+)
+
+func TestStatusUpdates(t *testing.T) {
+	vfd := simulator.New()
+	hy := NewVfd()
+	hy.SetRunStatePolling(true)
+	updates := hy.StatusUpdates()
+	if err := hy.OpenPort(vfd, WithMaxRpm(24000), WithPollInterval(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	hy.GCode("M4 S6000")
+	timeout := time.After(5 * time.Second)
+	for reached := false; !reached; {
+		select {
+		case status := <-updates:
+			if !status.Online || status.LastSeen.IsZero() {
+				t.Fatalf("status of an answered poll cycle: %+v", status)
+			}
+			reached = status.Running && status.Direction == Backward && status.TargetRpm == 6000 && status.OutputRpm == 6000
+		case <-timeout:
+			t.Fatal("speed not reported")
+		}
+	}
+	hy.Close()
+	for range updates {
+	}
+	if _, ok := <-hy.StatusUpdates(); ok {
+		t.Fatal("channel returned after Close is open")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.pollIntervalSec = 1
+	hy.SetInvertDirection(true)
+	hy.sendFrequency(10000)
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x04, 0x03, byte(StatusOutputFrequency), 0x26, 0xAC}))
+	parseModbusRTU(hy, hy.signMessage([]byte{0x01, 0x03, 0x01, byte(ControlStatusRunCommand | ControlStatusRunning)}))
+	hy.GCode("M5")
+	status := hy.Status()
+	expected := Status{
+		TargetRpm:       hy.frequencyToRpm(10000),
+		OutputRpm:       hy.frequencyToRpm(9900),
+		OutputFrequency: 9900,
+		Direction:       Backward,
+		Running:         true,
+		Online:          true,
+		QueueDepth:      1,
+		LastSeen:        status.LastSeen,
+	}
+	if status != expected {
+		t.Fatalf("expected %+v, got %+v", expected, status)
+	}
+	if time.Since(status.LastSeen) > time.Second {
+		t.Errorf("unexpected last message time %v", status.LastSeen)
+	}
+}
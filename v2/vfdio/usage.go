@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+)
+
+// maxUsageInterval limits the time counted between two output frequency readings, so
+// time offline is not counted as run time.
+const maxUsageInterval = 5 * time.Second
+
+// Usage holds the cumulative counters of the spindle, e.g. to schedule bearing maintenance.
+type Usage struct {
+	// RunTime is the time the output frequency was above 0.
+	RunTime time.Duration
+	// EnergyWh is the estimated energy in watt hours, the apparent power √3·U·I of the
+	// output integrated over time. The power factor of the motor is not known, so the real
+	// energy is lower.
+	EnergyWh float64
+}
+
+// Usage returns the cumulative counters. They are updated with every output frequency
+// reading, StatusOutputFrequency is read by default. The energy requires
+// StatusOutputCurrent and StatusACVoltage in SetPollValues as well.
+func (o *HyInverter) Usage() Usage {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.usage
+}
+
+// RestoreUsage continues counting from usage, e.g. the counters saved at the last exit.
+func (o *HyInverter) RestoreUsage(usage Usage) {
+	o.stateMutex.Lock()
+	o.usage = usage
+	o.stateMutex.Unlock()
+}
+
+// WriteUsage saves the counters as JSON, see ReadUsage.
+func (o *HyInverter) WriteUsage(w io.Writer) error {
+	return json.NewEncoder(w).Encode(o.Usage())
+}
+
+// ReadUsage restores the counters saved by WriteUsage.
+func (o *HyInverter) ReadUsage(r io.Reader) error {
+	var usage Usage
+	if err := json.NewDecoder(r).Decode(&usage); err != nil {
+		return err
+	}
+	o.RestoreUsage(usage)
+	return nil
+}
+
+// countUsage adds the interval since the last output frequency reading to the counters.
+func (o *HyInverter) countUsage(outputFrequency uint16, now time.Time) {
+	power := math.Sqrt(3) * o.OutputVoltage() * o.OutputCurrentAmps()
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	elapsed := now.Sub(o.usageSampled)
+	o.usageSampled = now
+	if outputFrequency == 0 || elapsed <= 0 || elapsed > maxUsageInterval {
+		return
+	}
+	o.usage.RunTime += elapsed
+	o.usage.EnergyWh += power * elapsed.Hours()
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUsage(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.status[StatusACVoltage] = 2200
+	hy.status[StatusOutputCurrent] = 50
+	start := time.Now()
+	hy.countUsage(0, start)
+	hy.countUsage(10000, start.Add(time.Second))
+	hy.countUsage(10000, start.Add(2*time.Second))
+	// Offline
+	hy.countUsage(10000, start.Add(time.Minute))
+	hy.countUsage(0, start.Add(time.Minute+time.Second))
+	usage := hy.Usage()
+	if usage.RunTime != 2*time.Second {
+		t.Fatalf("unexpected run time %v", usage.RunTime)
+	}
+	if expected := math.Sqrt(3) * 220 * 5 * 2 / 3600; math.Abs(usage.EnergyWh-expected) > 1e-9 {
+		t.Fatalf("expected %v Wh, got %v", expected, usage.EnergyWh)
+	}
+	var saved bytes.Buffer
+	if err := hy.WriteUsage(&saved); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := newTestInverter()
+	if err := restored.ReadUsage(&saved); err != nil || restored.Usage() != usage {
+		t.Fatalf("not restored: %v, %+v", err, restored.Usage())
+	}
+}
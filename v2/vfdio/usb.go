@@ -0,0 +1,135 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrUSBUnsupported is returned by USBPorts on systems other than Linux.
+var ErrUSBUnsupported = errors.New("vfdio: USB adapter lookup not supported on " + runtime.GOOS)
+
+// sysfsRoot is the mount point of sysfs, replaced by tests.
+var sysfsRoot = "/sys"
+
+// USBPort is a serial port of a USB adapter, see USBPorts.
+type USBPort struct {
+	PortName     string
+	VendorID     uint16
+	ProductID    uint16
+	SerialNumber string
+}
+
+// Matches returns true if id is the serial number of the adapter or its vendor and product
+// ID in hex separated by a colon, e.g. "0403:6001" for a FTDI FT232R.
+func (p USBPort) Matches(id string) bool {
+	if vendor, product, ok := strings.Cut(id, ":"); ok {
+		vendorID, vendorErr := strconv.ParseUint(vendor, 16, 16)
+		productID, productErr := strconv.ParseUint(product, 16, 16)
+		return vendorErr == nil && productErr == nil && uint16(vendorID) == p.VendorID && uint16(productID) == p.ProductID
+	}
+	return id != "" && id == p.SerialNumber
+}
+
+// USBPorts returns the serial ports of the connected USB adapters, read from sysfs. It
+// returns ErrUSBUnsupported on other systems than Linux.
+func USBPorts() (ports []USBPort, err error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrUSBUnsupported
+	}
+	ttys, err := os.ReadDir(filepath.Join(sysfsRoot, "class", "tty"))
+	if err != nil {
+		return nil, fmt.Errorf("vfdio: listing serial ports: %w", err)
+	}
+	for _, tty := range ttys {
+		device, err := filepath.EvalSymlinks(filepath.Join(sysfsRoot, "class", "tty", tty.Name(), "device"))
+		if err != nil {
+			// Virtual terminal
+			continue
+		}
+		if port, ok := readUSBDevice(device); ok {
+			port.PortName = "/dev/" + tty.Name()
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// readUSBDevice reads the IDs of the USB device which is a parent of the sysfs directory of
+// a tty. The interface of the tty is between them.
+func readUSBDevice(dir string) (port USBPort, ok bool) {
+	for ; dir != filepath.Dir(dir) && strings.HasPrefix(dir, sysfsRoot); dir = filepath.Dir(dir) {
+		vendor, err := os.ReadFile(filepath.Join(dir, "idVendor"))
+		if err != nil {
+			continue
+		}
+		product, err := os.ReadFile(filepath.Join(dir, "idProduct"))
+		if err != nil {
+			return port, false
+		}
+		vendorID, vendorErr := strconv.ParseUint(strings.TrimSpace(string(vendor)), 16, 16)
+		productID, productErr := strconv.ParseUint(strings.TrimSpace(string(product)), 16, 16)
+		if vendorErr != nil || productErr != nil {
+			return port, false
+		}
+		// Cheap adapters have no serial number.
+		serial, _ := os.ReadFile(filepath.Join(dir, "serial"))
+		return USBPort{VendorID: uint16(vendorID), ProductID: uint16(productID), SerialNumber: strings.TrimSpace(string(serial))}, true
+	}
+	return port, false
+}
+
+// FindUSBPort returns the port name of the USB adapter matching id, see USBPort.Matches. It
+// returns an error wrapping ErrPortNotFound if no adapter matches, or an error if several
+// adapters match the vendor and product ID; use the serial number in that case.
+func FindUSBPort(id string) (string, error) {
+	ports, err := USBPorts()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, port := range ports {
+		if port.Matches(id) {
+			matches = append(matches, port.PortName)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: no USB adapter %s", ErrPortNotFound, id)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("vfdio: USB adapters %s all match %s, use the serial number", strings.Join(matches, ", "), id)
+}
+
+// OpenUSB works like Open, but looks up the port of the USB adapter by its serial number or
+// vendor and product ID, see FindUSBPort. The device name of an adapter changes with the
+// order of the connected adapters, e.g. from /dev/ttyUSB0 to /dev/ttyUSB1 after a reboot.
+// Reconnects look up the adapter again. Linux only.
+//
+//   err := handle.OpenUSB("A50285BI", WithMaxRpm(24000))
+//
+func (o *HyInverter) OpenUSB(id string, opts ...Option) error {
+	return o.open(context.Background(), o.usbDial(id), true, o.settings(opts))
+}
+
+// usbDial returns the function which opens the serial port of the USB adapter.
+func (o *HyInverter) usbDial(id string) func() (io.ReadWriteCloser, error) {
+	return func() (io.ReadWriteCloser, error) {
+		portName, err := FindUSBPort(id)
+		if err != nil {
+			return nil, err
+		}
+		return o.serialDial(portName)()
+	}
+}
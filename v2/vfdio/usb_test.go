@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeUSBTty adds a tty of an USB adapter to a sysfs tree.
+func fakeUSBTty(t *testing.T, root, tty, usb, vendor, product, serial string) {
+	t.Helper()
+	device := filepath.Join(root, "devices", "pci0000:00", usb)
+	iface := filepath.Join(device, usb+":1.0", tty)
+	files := map[string]string{"idVendor": vendor + "\n", "idProduct": product + "\n"}
+	if serial != "" {
+		files["serial"] = serial + "\n"
+	}
+	if err := os.MkdirAll(iface, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(device, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	class := filepath.Join(root, "class", "tty", tty)
+	if err := os.MkdirAll(class, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(iface, filepath.Join(class, "device")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindUSBPort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		if _, err := USBPorts(); err != ErrUSBUnsupported {
+			t.Fatalf("expected ErrUSBUnsupported, got %v", err)
+		}
+		return
+	}
+	root := t.TempDir()
+	defer func(previous string) { sysfsRoot = previous }(sysfsRoot)
+	sysfsRoot = root
+	fakeUSBTty(t, root, "ttyUSB0", "1-1", "0403", "6001", "A50285BI")
+	fakeUSBTty(t, root, "ttyUSB1", "1-2", "1a86", "7523", "")
+	fakeUSBTty(t, root, "ttyUSB2", "1-3", "1a86", "7523", "")
+	// A virtual terminal without device.
+	if err := os.MkdirAll(filepath.Join(root, "class", "tty", "tty0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ports, err := USBPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := USBPort{PortName: "/dev/ttyUSB0", VendorID: 0x0403, ProductID: 0x6001, SerialNumber: "A50285BI"}
+	if len(ports) != 3 || ports[0] != expected {
+		t.Fatalf("unexpected ports %+v", ports)
+	}
+	for _, id := range []string{"A50285BI", "0403:6001", "403:6001"} {
+		if portName, err := FindUSBPort(id); err != nil || portName != "/dev/ttyUSB0" {
+			t.Errorf("%s: got %s, %v", id, portName, err)
+		}
+	}
+	if _, err := FindUSBPort("1a86:7523"); err == nil {
+		t.Error("ambiguous ID accepted")
+	}
+	for _, id := range []string{"A5028500", "0403:6010", "", "0403:"} {
+		if _, err := FindUSBPort(id); !errors.Is(err, ErrPortNotFound) {
+			t.Errorf("%q: expected ErrPortNotFound, got %v", id, err)
+		}
+	}
+	hy := NewVfd()
+	if err := hy.OpenUSB("A5028500"); !errors.Is(err, ErrPortNotFound) {
+		t.Errorf("expected ErrPortNotFound, got %v", err)
+	}
+}
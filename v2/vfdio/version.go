@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// version is set at link time, for instance:
+//
+//   go build -ldflags "-X github.com/itschleemilch/huanyango/v2/vfdio.version=v1.5.0"
+//
+var version string
+
+// modulePath is the import path prefix of the library.
+const modulePath = "github.com/itschleemilch/huanyango"
+
+// Version returns the version of the library, e.g. to include it in bug reports. It is the
+// version set with -ldflags, else the module version recorded in the build info, else
+// "devel", followed by the VCS revision if the library is built as the main module.
+func Version() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	for _, dep := range info.Deps {
+		if strings.HasPrefix(dep.Path, modulePath) {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	if strings.HasPrefix(info.Main.Path, modulePath) && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && strings.HasPrefix(info.Main.Path, modulePath) {
+			if len(setting.Value) > 12 {
+				return "devel-" + setting.Value[:12]
+			}
+			return "devel-" + setting.Value
+		}
+	}
+	return "devel"
+}
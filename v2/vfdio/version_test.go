@@ -0,0 +1,25 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if v := Version(); !strings.HasPrefix(v, "devel") && !strings.HasPrefix(v, "v") {
+		t.Fatalf("unexpected version %q", v)
+	}
+	defer func(saved string) { version = saved }(version)
+	version = "v1.5.0"
+	if v := Version(); v != "v1.5.0" {
+		t.Fatalf("version of -ldflags not used: %q", v)
+	}
+	hy, _ := newTestInverter()
+	if d := hy.Diagnostics(); d.Version != "v1.5.0" {
+		t.Fatalf("version missing in diagnostics: %+v", d)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdiotest
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"sync"
+	"time"
+)
+
+// FakeClock is a vfdio.Clock whose time only moves on Advance. Sleep blocks until the
+// clock was advanced past its end, tickers tick and timers fire on Advance, so methods
+// waiting for the VFD like ExecuteGCode check their condition only then. The gaps between
+// frames are Sleeps as well: a queued command is sent only while the clock is advanced.
+// The poller of a closed HyInverter ends with the next Advance.
+type FakeClock struct {
+	mutex    sync.Mutex
+	changed  *sync.Cond
+	now      time.Time
+	sleepers int
+	tickers  []*fakeTicker
+	timers   []*fakeTimer
+}
+
+// fakeTicker is the vfdio.Ticker of a FakeClock.
+type fakeTicker struct {
+	clock  *FakeClock
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// fakeTimer is the vfdio.Timer of a FakeClock. f is set for a timer of AfterFunc, c
+// otherwise.
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	f     func()
+	when  time.Time
+}
+
+var _ vfdio.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock starting at an arbitrary fixed time.
+func NewFakeClock() *FakeClock {
+	c := &FakeClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.changed = sync.NewCond(&c.mutex)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock was advanced by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	end := c.now.Add(d)
+	c.sleepers++
+	c.changed.Broadcast()
+	for c.now.Before(end) {
+		c.changed.Wait()
+	}
+	c.sleepers--
+	c.changed.Broadcast()
+}
+
+// NewTicker returns a ticker which ticks when the clock was advanced by d. Like a
+// time.Ticker, it drops ticks for a slow receiver. It never ticks if d is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) vfdio.Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// NewTimer returns a timer which fires when the clock was advanced by d. It fires at once
+// if d is not positive.
+func (c *FakeClock) NewTimer(d time.Duration) vfdio.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc returns a timer which calls f in its own goroutine when the clock was advanced
+// by d, like time.AfterFunc.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) vfdio.Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward and wakes the sleepers, tickers and timers whose time
+// elapsed.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for t.period > 0 && !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+	c.fireTimers()
+	c.mutex.Unlock()
+	c.changed.Broadcast()
+}
+
+// fireTimers fires and removes the timers whose time elapsed. The caller holds mutex.
+func (c *FakeClock) fireTimers() {
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+		} else if t.f != nil {
+			go t.f()
+		} else {
+			select {
+			case t.c <- t.when:
+			default:
+			}
+		}
+	}
+	c.timers = pending
+}
+
+// removeTimer removes a timer and returns true if it was pending. The caller holds mutex.
+func (c *FakeClock) removeTimer(t *fakeTimer) bool {
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Sleepers returns the number of goroutines blocked in Sleep.
+func (c *FakeClock) Sleepers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sleepers
+}
+
+// BlockUntil waits until at least n goroutines are blocked in Sleep, e.g. until the poller
+// waits for its next cycle.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.sleepers < n {
+		c.changed.Wait()
+	}
+}
+
+// C returns the channel of the ticks.
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop removes the ticker from its clock.
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// C returns the channel the time is sent on when the timer fires, nil for AfterFunc.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop prevents the timer from firing. It returns false if it already fired or was stopped.
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.removeTimer(t)
+}
+
+// Reset restarts the timer with the duration d. It returns true if the timer was pending.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pending := c.removeTimer(t)
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.fireTimers()
+	return pending
+}
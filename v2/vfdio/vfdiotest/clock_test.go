@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdiotest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+	woken := make(chan time.Time)
+	go func() {
+		clock.Sleep(time.Second)
+		woken <- clock.Now()
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-woken:
+		t.Fatal("woken too early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(500 * time.Millisecond)
+	if now := <-woken; now.Sub(start) != time.Second {
+		t.Fatalf("woken at %v", now.Sub(start))
+	}
+	if clock.Sleepers() != 0 {
+		t.Fatalf("unexpected sleepers: %d", clock.Sleepers())
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+	ticker := clock.NewTicker(100 * time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked too early")
+	default:
+	}
+	clock.Advance(300 * time.Millisecond)
+	if tick := <-ticker.C(); tick.Sub(start) != 100*time.Millisecond {
+		t.Fatalf("first tick at %v", tick.Sub(start))
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("ticks not dropped for a slow receiver: %v", tick.Sub(start))
+	default:
+	}
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked after Stop")
+	default:
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+	timer := clock.NewTimer(100 * time.Millisecond)
+	fired := make(chan struct{})
+	clock.AfterFunc(200*time.Millisecond, func() { close(fired) })
+	clock.Advance(50 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("fired early")
+	default:
+	}
+	clock.Advance(100 * time.Millisecond)
+	if when := <-timer.C(); when.Sub(start) != 100*time.Millisecond {
+		t.Fatalf("unexpected time %v", when.Sub(start))
+	}
+	if timer.Stop() {
+		t.Error("fired timer reported as pending")
+	}
+	if timer.Reset(100 * time.Millisecond) {
+		t.Error("fired timer reported as pending")
+	}
+	if !timer.Stop() {
+		t.Error("reset timer not pending")
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("fired after Stop")
+	default:
+	}
+	<-fired
+}
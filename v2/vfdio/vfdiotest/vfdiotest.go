@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package vfdiotest provides utilities for testing applications built on vfdio without a
+// VFD: a simulated drive connected to a HyInverter and a fake clock which advances the
+// polling only when the test says so.
+//
+// Example:
+//
+//   clock := vfdiotest.NewFakeClock()
+//   hy, vfd, err := vfdiotest.NewSimulated(vfdiotest.Options{Clock: clock})
+//   if err != nil {
+//       t.Fatal(err)
+//   }
+//   defer hy.Close()
+//   hy.GCode("M3 S6000")
+//   for vfd.OutputFrequency() == 0 { // the frames are sent with gaps of the clock
+//       clock.Advance(10 * time.Millisecond)
+//       time.Sleep(time.Millisecond)
+//   }
+//   clock.Advance(750 * time.Millisecond) // next polling cycle
+//
+package vfdiotest
+
+import (
+	"github.com/itschleemilch/huanyango/v2/vfdio"
+	"github.com/itschleemilch/huanyango/v2/vfdio/simulator"
+	"time"
+)
+
+// Options configures NewSimulated.
+type Options struct {
+	// MaxRpm is passed to OpenPort. Default: 24000.
+	MaxRpm uint16
+	// PollInterval is the poll interval in milliseconds. Default: 750.
+	PollInterval int64
+	// Clock replaces the time source of the poll scheduling, e.g. a FakeClock.
+	// Default: the system clock.
+	Clock vfdio.Clock
+}
+
+// NewSimulated opens a HyInverter connected to a new simulated drive. The conversion
+// factor is read from the simulator's PD144 and PD176. Close the HyInverter after the test.
+func NewSimulated(opts Options) (*vfdio.HyInverter, *simulator.Vfd, error) {
+	if opts.MaxRpm == 0 {
+		opts.MaxRpm = 24000
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 750
+	}
+	vfd := simulator.New()
+	hy := vfdio.NewVfd()
+	if opts.Clock != nil {
+		hy.SetClock(opts.Clock)
+	}
+	if err := hy.OpenPort(vfd, vfdio.WithMaxRpm(opts.MaxRpm), vfdio.WithPollInterval(time.Duration(opts.PollInterval)*time.Millisecond)); err != nil {
+		hy.Close()
+		return nil, nil, err
+	}
+	return hy, vfd, nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdiotest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSimulated(t *testing.T) {
+	clock := NewFakeClock()
+	hy, vfd, err := NewSimulated(Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hy.Close()
+	hy.GCode("M3 S6000")
+	// The gaps between the frames end when the clock is advanced.
+	clock.BlockUntil(1)
+	for i := 0; i < 60 && (vfd.OutputFrequency() != vfd.SetFrequency() || vfd.SetFrequency() == 0); i++ {
+		clock.Advance(10 * time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The output frequency is only read in the next polling cycle.
+	if hy.OutputRpm() != 0 {
+		t.Fatalf("polled before the poll interval elapsed: %d", hy.OutputRpm())
+	}
+	for i := 0; i < 100 && hy.OutputRpm() == 0; i++ {
+		clock.Advance(10 * time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hy.OutputRpm() != 6000 {
+		t.Fatalf("expected 6000 RPM, got %d", hy.OutputRpm())
+	}
+}
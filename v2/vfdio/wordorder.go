@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"strings"
+)
+
+// SetSpeedFirst selects the order in which the words of a line are executed. If enabled, S
+// words are executed before the other words and G4 dwells after them, like the RS274/NGC
+// interpreters of CNC controllers do, so "M3 S12000" starts the spindle at 12000 RPM
+// instead of starting it at the previous speed and changing it afterwards. Otherwise the
+// words are executed from left to right. It applies to all ways of queueing a line,
+// including StreamProgram. Default: false.
+func (o *HyInverter) SetSpeedFirst(enabled bool) {
+	o.stateMutex.Lock()
+	o.speedFirst = enabled
+	o.stateMutex.Unlock()
+}
+
+// SpeedFirst returns the setting of SetSpeedFirst.
+func (o *HyInverter) SpeedFirst() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.speedFirst
+}
+
+// lineWord is a command word to queue. internal is set for the words created by the
+// library instead of the caller, the dwells of G4 and the frequencies of SetFrequencyHz.
+// Words of the caller are never executed as internal words, whatever their text is.
+type lineWord struct {
+	text     string
+	internal bool
+}
+
+// wordTexts returns the texts of words.
+func wordTexts(words []lineWord) []string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.text
+	}
+	return texts
+}
+
+// lineWords separates the words of a line in the order of execution, see SetSpeedFirst.
+func (o *HyInverter) lineWords(cmd string) []lineWord {
+	return o.orderWords(splitGCode(cmd))
+}
+
+// orderWords moves the S words of a line to the front and the dwells to the end if
+// SetSpeedFirst is enabled. The order of the other words is kept.
+func (o *HyInverter) orderWords(words []lineWord) []lineWord {
+	if !o.SpeedFirst() {
+		return words
+	}
+	ordered := make([]lineWord, 0, len(words))
+	for _, word := range words {
+		if strings.HasPrefix(word.text, "s") || strings.HasPrefix(word.text, "S") {
+			ordered = append(ordered, word)
+		}
+	}
+	for _, word := range words {
+		if !strings.HasPrefix(word.text, "s") && !strings.HasPrefix(word.text, "S") && !isDwell(word) {
+			ordered = append(ordered, word)
+		}
+	}
+	for _, word := range words {
+		if isDwell(word) {
+			ordered = append(ordered, word)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpeedFirst(t *testing.T) {
+	hy, _ := newTestInverter()
+	hy.maxRpm = 24000
+	if words := wordTexts(hy.lineWords("M3 S12000")); !reflect.DeepEqual(words, []string{"M3", "S12000"}) {
+		t.Fatalf("reordered by default: %q", words)
+	}
+	hy.SetSpeedFirst(true)
+	tests := map[string][]string{
+		"M3 S12000":          {"S12000", "M3"},
+		"G4 P1 M4 ? S6000":   {"S6000", "M4", "?", "dwell1"},
+		"M5 S0":              {"S0", "M5"},
+		"F200 M3 X1 s100 M8": {"s100", "F200", "M3", "X1", "M8"},
+	}
+	for line, expected := range tests {
+		if words := wordTexts(hy.lineWords(line)); !reflect.DeepEqual(words, expected) {
+			t.Errorf("%s: expected %q, got %q", line, expected, words)
+		}
+	}
+	hy.GCode("M3 S12000")
+	for _, expected := range []string{"S12000", "M3"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != expected {
+			t.Fatalf("expected %q, got %q", expected, cmd)
+		}
+	}
+	if err := hy.StreamProgram(context.Background(), strings.NewReader("G0 M3 S9000\n"), StreamOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"S9000", "M3"} {
+		if cmd := (<-hy.cmdChannel).word; cmd != expected {
+			t.Fatalf("streamed: expected %q, got %q", expected, cmd)
+		}
+	}
+}
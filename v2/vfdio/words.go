@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedWord is wrapped by a WordError. Enqueue returns it if SetStrictWords is
+// enabled and a line holds a word without a spindle function.
+var ErrUnsupportedWord = errors.New("vfdio: unsupported word")
+
+// WordError describes a word of a line which is not executed, see WordIgnored and
+// SetStrictWords. It wraps ErrUnsupportedWord. Column is 1-based and counts bytes like in
+// LineError. Reason is "unsupported code" for a word without a spindle function, e.g. G1
+// or F200, or the reason of the SyntaxError of malformed input, e.g. "malformed word".
+type WordError struct {
+	Column int
+	Word   string
+	Reason string
+}
+
+func (e *WordError) Error() string {
+	return fmt.Sprintf("%v at column %d: %s: %q", ErrUnsupportedWord, e.Column, e.Reason, e.Word)
+}
+
+func (e *WordError) Unwrap() error {
+	return ErrUnsupportedWord
+}
+
+// SetStrictWords selects what happens to the words of a line without a spindle function,
+// e.g. G1, F200 or typos. If enabled, the whole line is rejected with a WordError, so
+// nothing of it is queued. Otherwise the word is ignored and a WordIgnored event is raised
+// for it. Lines passed to StreamProgram are meant for a motion controller as well and are
+// not checked. Default: false.
+func (o *HyInverter) SetStrictWords(enabled bool) {
+	o.stateMutex.Lock()
+	o.strictWords = enabled
+	o.stateMutex.Unlock()
+}
+
+// StrictWords returns the setting of SetStrictWords.
+func (o *HyInverter) StrictWords() bool {
+	o.stateMutex.Lock()
+	defer o.stateMutex.Unlock()
+	return o.strictWords
+}
+
+// checkGCodeLine rejects a line like checkWords and handles its ignored words, see
+// SetStrictWords. words are the words of cmd returned by lineWords.
+func (o *HyInverter) checkGCodeLine(cmd string, words []lineWord) error {
+	if err := o.checkWords(words); err != nil {
+		return err
+	}
+	ignored := ignoredWords(cmd)
+	if len(ignored) == 0 {
+		return nil
+	}
+	if o.StrictWords() {
+		return ignored[0]
+	}
+	for _, word := range ignored {
+		o.emit(Event{Type: WordIgnored, Err: word})
+	}
+	return nil
+}
+
+// ignoredWords returns the words of a line which are not executed, in the order of the line.
+func ignoredWords(cmd string) (ignored []*WordError) {
+	dwell := false
+	for _, token := range scanGCode(cmd) {
+		lower := strings.ToLower(token.text)
+		dwellTime := dwell && strings.ContainsAny(lower[:1], "ps")
+		dwell = lower == "g4" || lower == "g04"
+		if dwell || dwellTime || supportedWord(lower) {
+			continue
+		}
+		reason := "unsupported code"
+		if token.err != nil {
+			reason = token.err.Reason
+		}
+		ignored = append(ignored, &WordError{Column: token.offset + 1, Word: token.text, Reason: reason})
+	}
+	return
+}
+
+// supportedWord returns true if a normalized word of the caller has a function, see
+// execute. The texts of internal words like dwell2 are not supported, see lineWord.
+func supportedWord(word string) bool {
+	if _, ok := controlCommand(word); ok {
+		return true
+	}
+	return word == "?" || strings.HasPrefix(word, "s")
+}
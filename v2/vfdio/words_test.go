@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Sebastian Schleemilch
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package vfdio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWordIgnored(t *testing.T) {
+	hy, _ := newTestInverter()
+	var ignored []WordError
+	hy.Subscribe(func(e Event) {
+		if e.Type != WordIgnored {
+			return
+		}
+		var wordErr *WordError
+		if !errors.As(e.Err, &wordErr) || !errors.Is(e.Err, ErrUnsupportedWord) {
+			t.Fatalf("unexpected error %v", e.Err)
+		}
+		ignored = append(ignored, *wordErr)
+	})
+	if err := hy.Enqueue("N10G1 F200 M3 S6000 X#1 G4 P2 ?"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []WordError{
+		{Column: 1, Word: "N10", Reason: "unsupported code"},
+		{Column: 4, Word: "G1", Reason: "unsupported code"},
+		{Column: 7, Word: "F200", Reason: "unsupported code"},
+		{Column: 21, Word: "X#1", Reason: "malformed word"},
+	}
+	if len(ignored) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ignored)
+	}
+	for i := range expected {
+		if ignored[i] != expected[i] {
+			t.Errorf("word %d: expected %+v, got %+v", i, expected[i], ignored[i])
+		}
+	}
+	if depth := hy.QueueDepth(); depth != 7 {
+		t.Errorf("expected the whole line to be queued, got %d words", depth)
+	}
+
+	hy.SetStrictWords(true)
+	err := hy.Enqueue("M3 S6000 G1")
+	var wordErr *WordError
+	if !errors.As(err, &wordErr) || !errors.Is(err, ErrUnsupportedWord) || wordErr.Word != "G1" || wordErr.Column != 10 {
+		t.Fatalf("expected a WordError for G1, got %v", err)
+	}
+	if depth := hy.QueueDepth(); depth != 7 {
+		t.Errorf("rejected line was queued, queue depth %d", depth)
+	}
+	if _, err := hy.EncodeCommand("M3 Q1"); !errors.Is(err, ErrUnsupportedWord) {
+		t.Errorf("expected ErrUnsupportedWord, got %v", err)
+	}
+	if _, err := hy.EncodeCommand("M3 S6000 G4 S1 M5 ?"); err != nil {
+		t.Errorf("supported line rejected: %v", err)
+	}
+	// The texts of internal words are no G-code.
+	for _, line := range []string{"dwell2", "hz50"} {
+		if err := hy.Enqueue(line); !errors.Is(err, ErrUnsupportedWord) {
+			t.Errorf("%s: expected ErrUnsupportedWord, got %v", line, err)
+		}
+	}
+}
+
+func TestInternalWordsTyped(t *testing.T) {
+	hy, port := newTestInverter()
+	hy.maxRpm = 24000
+	if err := hy.Enqueue("dwell2 hz50 S6000"); err != nil {
+		t.Fatal(err)
+	}
+	for hy.QueueDepth() > 0 {
+		hy.processNext()
+	}
+	if hy.dwelling() {
+		t.Error("dwell started by a typed word")
+	}
+	expected, _ := hy.rpmToFrequency(6000)
+	if frequencies := sentFrequencies(port.Bytes()); len(frequencies) != 1 || frequencies[0] != expected {
+		t.Errorf("expected only the S word to be sent, got %v", frequencies)
+	}
+}